@@ -5,9 +5,11 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/cogna-public/azure-login/internal/commands"
+	"github.com/cogna-public/azure-login/internal/output"
 )
 
 var (
@@ -18,6 +20,10 @@ var (
 
 func main() {
 	if err := commands.Execute(version, commit, date); err != nil {
+		// --output exitcode reports a false result through the exit status alone
+		if errors.Is(err, output.ErrFalseResult) {
+			os.Exit(1)
+		}
 		_, _ = os.Stderr.WriteString("Error: " + err.Error() + "\n")
 		os.Exit(1)
 	}
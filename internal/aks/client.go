@@ -6,18 +6,26 @@ package aks
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/cogna-public/azure-login/internal/httplog"
+	"github.com/cogna-public/azure-login/internal/retry"
 	"gopkg.in/yaml.v3"
 )
 
 const (
-	// AzureManagementURL is the base URL for Azure Management API
+	// AzureManagementURL is the default (public cloud) base URL for Azure Management API
 	AzureManagementURL = "https://management.azure.com"
 	// AKSAPIVersion is the API version for AKS operations
 	AKSAPIVersion = "2023-01-01"
@@ -25,11 +33,27 @@ const (
 	RequestTimeout = 30 * time.Second
 )
 
+var (
+	// credentialPropagationAttempts bounds fetches while a new cluster's CA or server URL is empty
+	credentialPropagationAttempts = 6
+
+	// credentialPropagationDelay is the wait between such fetches
+	credentialPropagationDelay = 5 * time.Second
+)
+
+// errCredentialsNotReady indicates the returned kubeconfig has an empty server URL or
+// CA certificate, which happens transiently for newly created clusters
+var errCredentialsNotReady = errors.New("cluster credentials are not ready yet (empty server URL or CA certificate); the cluster may still be provisioning")
+
 // Client handles AKS operations
 type Client struct {
 	subscriptionID string
 	accessToken    string
+	managementURL  string
 	httpClient     *http.Client
+
+	// rateLimits are the remaining quotas reported by the most recent ARM response
+	rateLimits RateLimits
 }
 
 // NewClient creates a new AKS client
@@ -37,7 +61,27 @@ func NewClient(subscriptionID, accessToken string) *Client {
 	return &Client{
 		subscriptionID: subscriptionID,
 		accessToken:    accessToken,
-		httpClient:     &http.Client{Timeout: RequestTimeout},
+		managementURL:  AzureManagementURL,
+		httpClient:     &http.Client{Timeout: RequestTimeout, Transport: httplog.Default()},
+	}
+}
+
+// SetManagementURL sets the Azure Resource Manager endpoint (e.g. for sovereign clouds,
+// or a test server); a trailing slash is ignored
+func (c *Client) SetManagementURL(managementURL string) {
+	c.managementURL = strings.TrimRight(managementURL, "/")
+}
+
+// RateLimits returns the remaining request quotas reported by the most recent Azure
+// Resource Manager response (empty if it reported none)
+func (c *Client) RateLimits() RateLimits {
+	return c.rateLimits
+}
+
+// recordRateLimits keeps the quotas of a response that reports any
+func (c *Client) recordRateLimits(header http.Header) {
+	if limits := parseRateLimits(header); len(limits) > 0 {
+		c.rateLimits = limits
 	}
 }
 
@@ -50,6 +94,56 @@ type ClusterCredentials struct {
 	SubscriptionID string
 	TenantID       string
 	ClientID       string
+
+	// AzureAD reports whether the cluster authenticates users with Azure AD, detected
+	// from the exec (kubelogin) or azure auth-provider user of the returned kubeconfig
+	AzureAD bool
+
+	// LocalAccountsDisabled reports whether the cluster rejects local (admin) accounts
+	LocalAccountsDisabled bool
+
+	// PrivateFQDN is the private API server FQDN of a private cluster (empty otherwise)
+	PrivateFQDN string
+
+	// RateLimits are the remaining ARM request quotas after retrieving the credentials
+	RateLimits RateLimits
+
+	// Admin reports whether these are the cluster admin credentials, which carry a
+	// client certificate instead of relying on an exec plugin
+	Admin bool
+
+	// ClientCertificate and ClientKey are the admin client certificate and key (PEM)
+	ClientCertificate []byte
+	ClientKey         []byte
+}
+
+// UsePrivateFQDN points ServerURL at the cluster's private FQDN, keeping the scheme
+// and port, for private clusters reached over a VPN or private link
+func (c *ClusterCredentials) UsePrivateFQDN() error {
+	if c.PrivateFQDN == "" {
+		return fmt.Errorf("cluster %s has no private FQDN; --private only applies to private clusters", c.ClusterName)
+	}
+
+	serverURL, err := url.Parse(c.ServerURL)
+	if err != nil {
+		return fmt.Errorf("invalid server URL %q: %w", c.ServerURL, err)
+	}
+	if port := serverURL.Port(); port != "" {
+		serverURL.Host = net.JoinHostPort(c.PrivateFQDN, port)
+	} else {
+		serverURL.Host = c.PrivateFQDN
+	}
+	c.ServerURL = serverURL.String()
+	return nil
+}
+
+// clusterInfo is the connection information extracted from a returned kubeconfig
+type clusterInfo struct {
+	serverURL  string
+	caCert     []byte
+	azureAD    bool
+	clientCert []byte
+	clientKey  []byte
 }
 
 // managedClusterResponse represents the Azure API response for a managed cluster
@@ -58,10 +152,11 @@ type managedClusterResponse struct {
 	Name       string `json:"name"`
 	Location   string `json:"location"`
 	Properties struct {
-		Fqdn              string `json:"fqdn"`
-		AzurePortalFQDN   string `json:"azurePortalFQDN"`
-		PrivateFQDN       string `json:"privateFQDN"`
-		OidcIssuerProfile struct {
+		Fqdn                 string `json:"fqdn"`
+		DisableLocalAccounts bool   `json:"disableLocalAccounts"`
+		AzurePortalFQDN      string `json:"azurePortalFQDN"`
+		PrivateFQDN          string `json:"privateFQDN"`
+		OidcIssuerProfile    struct {
 			IssuerURL string `json:"issuerURL"`
 		} `json:"oidcIssuerProfile"`
 		SecurityProfile struct {
@@ -80,44 +175,134 @@ type clusterUserCredentialResponse struct {
 	} `json:"kubeconfigs"`
 }
 
+// Names of the kubeconfig entries returned by the list credential actions. Azure
+// returns one entry per action (clusterMonitoringUser for listClusterMonitoringUserCredential).
+const (
+	kubeconfigNameUser  = "clusterUser"
+	kubeconfigNameAdmin = "clusterAdmin"
+)
+
+// kubeconfig returns the base64 encoded kubeconfig entry with the given name
+func (r *clusterUserCredentialResponse) kubeconfig(name string) (string, error) {
+	if len(r.Kubeconfigs) == 0 {
+		return "", fmt.Errorf("no kubeconfig returned from Azure")
+	}
+
+	names := make([]string, 0, len(r.Kubeconfigs))
+	for _, kubeconfig := range r.Kubeconfigs {
+		if kubeconfig.Name == name {
+			return kubeconfig.Value, nil
+		}
+		names = append(names, fmt.Sprintf("%q", kubeconfig.Name))
+	}
+	return "", fmt.Errorf("no %q kubeconfig returned from Azure (got %s)", name, strings.Join(names, ", "))
+}
+
 // GetClusterCredentials retrieves AKS cluster credentials from Azure
 func (c *Client) GetClusterCredentials(ctx context.Context, resourceGroup, clusterName string) (*ClusterCredentials, error) {
+	return c.getCredentials(ctx, resourceGroup, clusterName, false)
+}
+
+// GetClusterAdminCredentials retrieves the cluster admin credentials
+// (listClusterAdminCredential), a client-certificate kubeconfig intended for
+// break-glass access. Clusters with local accounts disabled are refused.
+func (c *Client) GetClusterAdminCredentials(ctx context.Context, resourceGroup, clusterName string) (*ClusterCredentials, error) {
+	return c.getCredentials(ctx, resourceGroup, clusterName, true)
+}
+
+// getCredentials retrieves the user or admin credentials of a cluster
+func (c *Client) getCredentials(ctx context.Context, resourceGroup, clusterName string, admin bool) (*ClusterCredentials, error) {
 	// First, get the cluster information
 	clusterURL := fmt.Sprintf(
 		"%s/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerService/managedClusters/%s?api-version=%s",
-		AzureManagementURL,
+		c.managementURL,
 		c.subscriptionID,
 		resourceGroup,
 		clusterName,
 		AKSAPIVersion,
 	)
 
-	_, err := c.getClusterInfo(ctx, clusterURL)
+	cluster, err := c.getClusterInfo(ctx, clusterURL)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get the user credentials
+	action, kubeconfigName := "listClusterUserCredential", kubeconfigNameUser
+	if admin {
+		// Azure would answer with a BadRequest; explain the cluster setting instead
+		if cluster.Properties.DisableLocalAccounts {
+			return nil, fmt.Errorf("cluster %s has local accounts disabled, so admin credentials are unavailable; use Azure AD credentials instead", clusterName)
+		}
+		action, kubeconfigName = "listClusterAdminCredential", kubeconfigNameAdmin
+	}
+
+	// Get the user (or admin) credentials
 	credentialsURL := fmt.Sprintf(
-		"%s/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerService/managedClusters/%s/listClusterUserCredential?api-version=%s",
-		AzureManagementURL,
+		"%s/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerService/managedClusters/%s/%s?api-version=%s",
+		c.managementURL,
 		c.subscriptionID,
 		resourceGroup,
 		clusterName,
+		action,
 		AKSAPIVersion,
 	)
 
+	info, err := c.fetchClusterInfo(ctx, credentialsURL, kubeconfigName)
+	if err != nil {
+		return nil, err
+	}
+	if admin && (len(info.clientCert) == 0 || len(info.clientKey) == 0) {
+		return nil, fmt.Errorf("admin kubeconfig for cluster %s has no client certificate", clusterName)
+	}
+
+	return &ClusterCredentials{
+		ClusterName:           clusterName,
+		ServerURL:             info.serverURL,
+		CACertificate:         info.caCert,
+		ResourceGroup:         resourceGroup,
+		SubscriptionID:        c.subscriptionID,
+		AzureAD:               info.azureAD,
+		LocalAccountsDisabled: cluster.Properties.DisableLocalAccounts,
+		PrivateFQDN:           cluster.Properties.PrivateFQDN,
+		RateLimits:            c.rateLimits,
+		Admin:                 admin,
+		ClientCertificate:     info.clientCert,
+		ClientKey:             info.clientKey,
+	}, nil
+}
+
+// fetchClusterInfo retrieves the credentials and extracts the server URL and CA
+// certificate from the named kubeconfig entry. Newly created clusters can briefly return an empty server URL or CA;
+// that is retried (bounded) separately from HTTP-level errors.
+func (c *Client) fetchClusterInfo(ctx context.Context, credentialsURL, kubeconfigName string) (*clusterInfo, error) {
+	for attempt := 1; ; attempt++ {
+		info, err := c.fetchClusterInfoOnce(ctx, credentialsURL, kubeconfigName)
+		if !errors.Is(err, errCredentialsNotReady) || attempt >= credentialPropagationAttempts {
+			return info, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(credentialPropagationDelay):
+		}
+	}
+}
+
+// fetchClusterInfoOnce performs a single credential fetch and extraction
+func (c *Client) fetchClusterInfoOnce(ctx context.Context, credentialsURL, kubeconfigName string) (*clusterInfo, error) {
 	credentials, err := c.getClusterUserCredentials(ctx, credentialsURL)
 	if err != nil {
 		return nil, err
 	}
 
 	// Decode the kubeconfig to extract CA certificate and server URL
-	if len(credentials.Kubeconfigs) == 0 {
-		return nil, fmt.Errorf("no kubeconfig returned from Azure")
+	encoded, err := credentials.kubeconfig(kubeconfigName)
+	if err != nil {
+		return nil, err
 	}
 
-	kubeconfigData, err := base64.StdEncoding.DecodeString(credentials.Kubeconfigs[0].Value)
+	kubeconfigData, err := base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode kubeconfig: %w", err)
 	}
@@ -132,40 +317,79 @@ func (c *Client) GetClusterCredentials(ctx context.Context, resourceGroup, clust
 	if err != nil {
 		return nil, err
 	}
-
-	return &ClusterCredentials{
-		ClusterName:    clusterName,
-		ServerURL:      serverURL,
-		CACertificate:  caCert,
-		ResourceGroup:  resourceGroup,
-		SubscriptionID: c.subscriptionID,
+	clientCert, clientKey, err := extractClientCertificate(kubeconfigMap)
+	if err != nil {
+		return nil, err
+	}
+	return &clusterInfo{
+		serverURL:  serverURL,
+		caCert:     caCert,
+		azureAD:    usesAzureAD(kubeconfigMap),
+		clientCert: clientCert,
+		clientKey:  clientKey,
 	}, nil
 }
 
-func (c *Client) getClusterInfo(ctx context.Context, url string) (*managedClusterResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// extractClientCertificate returns the client certificate and key of the user
+// referenced by the current context (the first user without one) in a returned
+// kubeconfig. Only admin kubeconfigs carry them; otherwise both are nil.
+func extractClientCertificate(kubeconfigMap map[string]any) (cert, key []byte, err error) {
+	users, _ := kubeconfigMap["users"].([]any)
+	if len(users) == 0 {
+		return nil, nil, nil
+	}
+	namedUser, err := selectUser(kubeconfigMap, users)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, nil, err
 	}
+	user, _ := namedUser["user"].(map[string]any)
 
-	req.Header.Set("Authorization", "Bearer "+c.accessToken)
-	req.Header.Set("Content-Type", "application/json")
+	certData, _ := user["client-certificate-data"].(string)
+	keyData, _ := user["client-key-data"].(string)
+	if certData == "" || keyData == "" {
+		return nil, nil, nil
+	}
 
-	resp, err := c.httpClient.Do(req)
+	cert, err = base64.StdEncoding.DecodeString(certData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get cluster info: %w", err)
+		return nil, nil, fmt.Errorf("failed to decode client certificate: %w", err)
 	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
-
-	body, err := io.ReadAll(resp.Body)
+	key, err = base64.StdEncoding.DecodeString(keyData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, nil, fmt.Errorf("failed to decode client key: %w", err)
 	}
+	return cert, key, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Azure API error (status %d): %s", resp.StatusCode, string(body))
+// usesAzureAD reports whether a kubeconfig returned by listClusterUserCredential
+// authenticates with Azure AD. AAD-enabled clusters return a user with a kubelogin
+// exec plugin (or the legacy azure auth-provider) instead of a token or client
+// certificate.
+func usesAzureAD(kubeconfigMap map[string]any) bool {
+	users, _ := kubeconfigMap["users"].([]any)
+	for _, entry := range users {
+		namedUser, _ := entry.(map[string]any)
+		user, _ := namedUser["user"].(map[string]any)
+
+		if exec, ok := user["exec"].(map[string]any); ok {
+			command, _ := exec["command"].(string)
+			if strings.Contains(command, "kubelogin") {
+				return true
+			}
+		}
+		if provider, ok := user["auth-provider"].(map[string]any); ok {
+			if name, _ := provider["name"].(string); name == "azure" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *Client) getClusterInfo(ctx context.Context, url string) (*managedClusterResponse, error) {
+	body, err := c.armRequest(ctx, "GET", url, "failed to get cluster info")
+	if err != nil {
+		return nil, err
 	}
 
 	var clusterInfo managedClusterResponse
@@ -177,37 +401,127 @@ func (c *Client) getClusterInfo(ctx context.Context, url string) (*managedCluste
 }
 
 func (c *Client) getClusterUserCredentials(ctx context.Context, url string) (*clusterUserCredentialResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	body, err := c.armRequest(ctx, "POST", url, "failed to get cluster credentials")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
+	}
+
+	var credentials clusterUserCredentialResponse
+	if err := json.Unmarshal(body, &credentials); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.accessToken)
-	req.Header.Set("Content-Type", "application/json")
+	return &credentials, nil
+}
 
-	resp, err := c.httpClient.Do(req)
+// armRequest sends an authenticated Azure Resource Manager request and returns the
+// body of a 200 response. Throttling (429) and transient server errors are retried
+// like the token exchange, within the command's shared retry budget.
+func (c *Client) armRequest(ctx context.Context, method, url, failure string) ([]byte, error) {
+	retryConfig := retry.LoadConfig()
+
+	var body []byte
+	err := retryConfig.DoWithContext(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+c.accessToken)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("%s: %w", failure, err)
+		}
+		c.recordRateLimits(resp.Header)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		// Limit response body to 10MB; a cluster list page holds up to a few hundred clusters
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 10*1024*1024))
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return &retry.HTTPStatusError{
+				StatusCode: resp.StatusCode,
+				Err:        fmt.Errorf("Azure API error (status %d): %s", resp.StatusCode, string(data)),
+				RetryAfter: retry.ParseRetryAfter(resp.Header.Get("Retry-After")),
+			}
+		}
+
+		body = data
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get cluster credentials: %w", err)
+		return nil, err
 	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
+	return body, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+// selectCluster returns the cluster entry referenced by the kubeconfig's current
+// context. Responses with several clusters (e.g. behind a management proxy) need not
+// list the intended one first; without a current context the first entry is used.
+func selectCluster(kubeconfigMap map[string]any, clusters []any) (map[string]any, error) {
+	if name := currentContextCluster(kubeconfigMap); name != "" {
+		for _, entry := range clusters {
+			if cluster, ok := entry.(map[string]any); ok && cluster["name"] == name {
+				return cluster, nil
+			}
+		}
+		return nil, fmt.Errorf("cluster %q referenced by the current context not found in kubeconfig", name)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Azure API error (status %d): %s", resp.StatusCode, string(body))
+	cluster, ok := clusters[0].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid cluster format")
 	}
+	return cluster, nil
+}
 
-	var credentials clusterUserCredentialResponse
-	if err := json.Unmarshal(body, &credentials); err != nil {
-		return nil, fmt.Errorf("failed to parse credentials: %w", err)
+// selectUser returns the user entry referenced by the kubeconfig's current context,
+// like selectCluster; without a current context the first entry is used.
+func selectUser(kubeconfigMap map[string]any, users []any) (map[string]any, error) {
+	if name := currentContextField(kubeconfigMap, "user"); name != "" {
+		for _, entry := range users {
+			if user, ok := entry.(map[string]any); ok && user["name"] == name {
+				return user, nil
+			}
+		}
+		return nil, fmt.Errorf("user %q referenced by the current context not found in kubeconfig", name)
 	}
 
-	return &credentials, nil
+	user, _ := users[0].(map[string]any)
+	return user, nil
+}
+
+// currentContextCluster returns the cluster name of the kubeconfig's current context,
+// or "" if it has none
+func currentContextCluster(kubeconfigMap map[string]any) string {
+	return currentContextField(kubeconfigMap, "cluster")
+}
+
+// currentContextField returns a field (cluster or user) of the kubeconfig's current
+// context, or "" if it has none
+func currentContextField(kubeconfigMap map[string]any, field string) string {
+	currentContext, _ := kubeconfigMap["current-context"].(string)
+	if currentContext == "" {
+		return ""
+	}
+	contexts, _ := kubeconfigMap["contexts"].([]any)
+	for _, entry := range contexts {
+		kubeContext, ok := entry.(map[string]any)
+		if !ok || kubeContext["name"] != currentContext {
+			continue
+		}
+		details, _ := kubeContext["context"].(map[string]any)
+		value, _ := details[field].(string)
+		return value
+	}
+	return ""
 }
 
 func extractClusterInfo(kubeconfigMap map[string]any) (serverURL string, caCert []byte, err error) {
@@ -222,13 +536,12 @@ func extractClusterInfo(kubeconfigMap map[string]any) (serverURL string, caCert
 		return "", nil, fmt.Errorf("invalid clusters format in kubeconfig")
 	}
 
-	// Get first cluster
-	firstCluster, ok := clusters[0].(map[string]any)
-	if !ok {
-		return "", nil, fmt.Errorf("invalid cluster format")
+	cluster, err := selectCluster(kubeconfigMap, clusters)
+	if err != nil {
+		return "", nil, err
 	}
 
-	clusterData, ok := firstCluster["cluster"].(map[string]any)
+	clusterData, ok := cluster["cluster"].(map[string]any)
 	if !ok {
 		return "", nil, fmt.Errorf("invalid cluster data format")
 	}
@@ -250,5 +563,55 @@ func extractClusterInfo(kubeconfigMap map[string]any) (serverURL string, caCert
 		return "", nil, fmt.Errorf("failed to decode CA certificate: %w", err)
 	}
 
+	// Present but empty values mean the cluster's credentials have not propagated yet
+	if serverURL == "" || len(caCert) == 0 {
+		return "", nil, errCredentialsNotReady
+	}
+
+	// Refuse to write a kubeconfig kubectl cannot use
+	if err := validateServerURL(serverURL); err != nil {
+		return "", nil, err
+	}
+	if err := validateCACertificate(caCert); err != nil {
+		return "", nil, err
+	}
+
 	return serverURL, caCert, nil
 }
+
+// validateServerURL checks that the cluster server is an https URL with a host
+func validateServerURL(serverURL string) error {
+	parsed, err := url.Parse(serverURL)
+	if err != nil {
+		return fmt.Errorf("invalid cluster server URL %q: %w", serverURL, err)
+	}
+	if parsed.Scheme != "https" || parsed.Host == "" {
+		return fmt.Errorf("invalid cluster server URL %q: expected an https URL with a host", serverURL)
+	}
+	return nil
+}
+
+// validateCACertificate checks that the CA data is a PEM chain of parseable x509 certificates
+func validateCACertificate(caCert []byte) error {
+	rest := caCert
+	count := 0
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return fmt.Errorf("invalid CA certificate: %w", err)
+		}
+		count++
+	}
+
+	if count == 0 {
+		return fmt.Errorf("invalid CA certificate: no PEM-encoded certificate found")
+	}
+	return nil
+}
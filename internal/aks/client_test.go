@@ -2,21 +2,33 @@ package aks
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/retry"
 )
 
 func TestGetClusterCredentials_Success(t *testing.T) {
 	// Create mock kubeconfig YAML
-	mockKubeconfig := `apiVersion: v1
+	mockKubeconfig := fmt.Sprintf(`apiVersion: v1
 kind: Config
 clusters:
 - cluster:
-    certificate-authority-data: LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCk1JSURCVENDQWUyZ0F3SUJBZ0lJZVlLQ3RWUU1ZMHM9Ci0tLS0tRU5EIENFUlRJRklDQVRFLS0tLS0K
+    certificate-authority-data: %s
     server: https://test-cluster.hcp.eastus.azmk8s.io:443
   name: test-cluster
 contexts:
@@ -29,56 +41,36 @@ users:
 - name: clusterUser_test-rg_test-cluster
   user:
     token: mock-token
-`
+`, testCACertBase64(t))
 	base64Kubeconfig := base64.StdEncoding.EncodeToString([]byte(mockKubeconfig))
 
+	clusterPath := "/subscriptions/test-subscription/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster"
+
 	// Create mock Azure API server
 	callCount := 0
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		callCount++
 
 		// Verify authorization header
-		authHeader := r.Header.Get("Authorization")
-		if !strings.HasPrefix(authHeader, "Bearer ") {
-			t.Errorf("Expected Bearer token authorization")
-		}
-		if authHeader != "Bearer mock-access-token" {
+		if authHeader := r.Header.Get("Authorization"); authHeader != "Bearer mock-access-token" {
 			t.Errorf("Expected Bearer mock-access-token, got %s", authHeader)
 		}
+		if got := r.URL.Query().Get("api-version"); got != AKSAPIVersion {
+			t.Errorf("Expected api-version %s, got %s", AKSAPIVersion, got)
+		}
 
-		// First call: Get cluster info
-		if callCount == 1 {
-			if r.Method != "GET" {
-				t.Errorf("Expected GET request for cluster info, got %s", r.Method)
-			}
-			if !strings.Contains(r.URL.Path, "/managedClusters/test-cluster") {
-				t.Errorf("Expected path to contain /managedClusters/test-cluster, got %s", r.URL.Path)
-			}
-
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "GET" && r.URL.Path == clusterPath:
 			_, _ = fmt.Fprintf(w, `{
-				"id": "/subscriptions/test-sub/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster",
+				"id": "%s",
 				"name": "test-cluster",
 				"location": "eastus",
 				"properties": {
 					"fqdn": "test-cluster.hcp.eastus.azmk8s.io"
 				}
-			}`)
-			return
-		}
-
-		// Second call: Get cluster credentials
-		if callCount == 2 {
-			if r.Method != "POST" {
-				t.Errorf("Expected POST request for credentials, got %s", r.Method)
-			}
-			if !strings.Contains(r.URL.Path, "/listClusterUserCredential") {
-				t.Errorf("Expected path to contain /listClusterUserCredential, got %s", r.URL.Path)
-			}
-
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
+			}`, clusterPath)
+		case r.Method == "POST" && r.URL.Path == clusterPath+"/listClusterUserCredential":
 			_, _ = fmt.Fprintf(w, `{
 				"kubeconfigs": [
 					{
@@ -87,55 +79,32 @@ users:
 					}
 				]
 			}`, base64Kubeconfig)
-			return
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
 		}
 	}))
 	defer server.Close()
 
-	// Create AKS client with mock server URL
-	client := &Client{
-		subscriptionID: "test-subscription",
-		accessToken:    "mock-access-token",
-		httpClient:     &http.Client{},
-	}
-
-	// Override the base URL for testing
-	originalURL := AzureManagementURL
-	defer func() {
-		// Can't actually change the const, but this shows the test design
-		_ = originalURL
-	}()
-
-	// Get cluster credentials (using mock server URLs)
-	ctx := context.Background()
-
-	// Manually construct the URLs to use the test server
-	clusterURL := server.URL + "/subscriptions/test-subscription/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster?api-version=2023-01-01"
-	credentialsURL := server.URL + "/subscriptions/test-subscription/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster/listClusterUserCredential?api-version=2023-01-01"
+	// Point the whole flow at the mock server
+	client := NewClient("test-subscription", "mock-access-token")
+	client.SetManagementURL(server.URL + "/")
 
-	// Test individual methods
-	_, err := client.getClusterInfo(ctx, clusterURL)
+	credentials, err := client.GetClusterCredentials(context.Background(), "test-rg", "test-cluster")
 	if err != nil {
-		t.Fatalf("Failed to get cluster info: %v", err)
+		t.Fatalf("GetClusterCredentials failed: %v", err)
 	}
-
-	credentials, err := client.getClusterUserCredentials(ctx, credentialsURL)
-	if err != nil {
-		t.Fatalf("Failed to get cluster credentials: %v", err)
+	if callCount != 2 {
+		t.Errorf("Expected cluster info and credential requests, got %d requests", callCount)
 	}
-
-	if len(credentials.Kubeconfigs) == 0 {
-		t.Fatal("Expected kubeconfigs, got none")
+	if credentials.ClusterName != "test-cluster" || credentials.ResourceGroup != "test-rg" || credentials.SubscriptionID != "test-subscription" {
+		t.Errorf("Unexpected cluster identity: %+v", credentials)
 	}
-
-	// Verify we can decode and parse the kubeconfig
-	kubeconfigData, err := base64.StdEncoding.DecodeString(credentials.Kubeconfigs[0].Value)
-	if err != nil {
-		t.Fatalf("Failed to decode kubeconfig: %v", err)
+	if credentials.ServerURL != "https://test-cluster.hcp.eastus.azmk8s.io:443" {
+		t.Errorf("Expected server URL from the kubeconfig, got %s", credentials.ServerURL)
 	}
-
-	if !strings.Contains(string(kubeconfigData), "test-cluster") {
-		t.Error("Expected kubeconfig to contain cluster name")
+	if len(credentials.CACertificate) == 0 {
+		t.Error("Expected CA certificate from the kubeconfig")
 	}
 }
 
@@ -201,18 +170,112 @@ func TestGetClusterCredentials_Unauthorized(t *testing.T) {
 	}
 }
 
-func TestExtractClusterInfo_Success(t *testing.T) {
-	kubeconfigMap := map[string]any{
+func TestGetClusterInfo_RetriesThrottling(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Type", "application/json")
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = fmt.Fprintf(w, `{"error":{"code":"TooManyRequests"}}`)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"name":"test-cluster","properties":{"privateFQDN":"test.privatelink.eastus.azmk8s.io"}}`)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-subscription", "mock-access-token")
+
+	cluster, err := client.getClusterInfo(context.Background(), server.URL+"/test")
+	if err != nil {
+		t.Fatalf("Expected the 429 to be retried, got: %v", err)
+	}
+	if attempts != 2 || cluster.Name != "test-cluster" {
+		t.Errorf("Expected success on the second attempt, got %d attempts and cluster %q", attempts, cluster.Name)
+	}
+}
+
+func TestGetClusterCredentials_SharesRetryBudget(t *testing.T) {
+	clusterPath := "/subscriptions/test-subscription/resourceGroups/test-rg/providers/Microsoft.ContainerService/managedClusters/test-cluster"
+
+	infoAttempts, credentialAttempts := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "GET" && r.URL.Path == clusterPath:
+			infoAttempts++
+			if infoAttempts == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"name":"test-cluster"}`)
+		case r.Method == "POST" && r.URL.Path == clusterPath+"/listClusterUserCredential":
+			credentialAttempts++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("test-subscription", "mock-access-token")
+	client.SetManagementURL(server.URL)
+
+	// A single retry for the whole command: the cluster info call uses it, so the
+	// credentials call only gets its initial attempt
+	ctx := retry.WithBudget(context.Background(), retry.NewBudget(1, 0))
+
+	_, err := client.GetClusterCredentials(ctx, "test-rg", "test-cluster")
+	if err == nil || !strings.Contains(err.Error(), "retry budget exhausted") {
+		t.Fatalf("Expected the shared retry budget to be exhausted, got: %v", err)
+	}
+	if infoAttempts != 2 || credentialAttempts != 1 {
+		t.Errorf("Expected 2 cluster info and 1 credential attempts, got %d and %d", infoAttempts, credentialAttempts)
+	}
+}
+
+// testCACertBase64 returns a base64-encoded PEM self-signed CA certificate
+func testCACertBase64(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// clusterInfoMap builds a kubeconfig map with a single cluster
+func clusterInfoMap(server, caData string) map[string]any {
+	return map[string]any{
 		"clusters": []any{
 			map[string]any{
 				"name": "test-cluster",
 				"cluster": map[string]any{
-					"server":                     "https://test-cluster.hcp.eastus.azmk8s.io:443",
-					"certificate-authority-data": "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCk1JSURCVENDQWUyZ0F3SUJBZ0lJZVlLQ3RWUU1ZMHM9Ci0tLS0tRU5EIENFUlRJRklDQVRFLS0tLS0K",
+					"server":                     server,
+					"certificate-authority-data": caData,
 				},
 			},
 		},
 	}
+}
+
+func TestExtractClusterInfo_Success(t *testing.T) {
+	kubeconfigMap := clusterInfoMap("https://test-cluster.hcp.eastus.azmk8s.io:443", testCACertBase64(t))
 
 	serverURL, caCert, err := extractClusterInfo(kubeconfigMap)
 	if err != nil {
@@ -228,6 +291,78 @@ func TestExtractClusterInfo_Success(t *testing.T) {
 	}
 }
 
+func TestExtractClusterInfo_CurrentContextCluster(t *testing.T) {
+	caData := testCACertBase64(t)
+	kubeconfigMap := map[string]any{
+		"clusters": []any{
+			map[string]any{
+				"name":    "management-proxy",
+				"cluster": map[string]any{"server": "https://proxy.example.com:443", "certificate-authority-data": caData},
+			},
+			map[string]any{
+				"name":    "test-cluster",
+				"cluster": map[string]any{"server": "https://test-cluster.hcp.eastus.azmk8s.io:443", "certificate-authority-data": caData},
+			},
+		},
+		"contexts": []any{
+			map[string]any{"name": "proxy", "context": map[string]any{"cluster": "management-proxy"}},
+			map[string]any{"name": "test-cluster", "context": map[string]any{"cluster": "test-cluster"}},
+		},
+		"current-context": "test-cluster",
+	}
+
+	serverURL, _, err := extractClusterInfo(kubeconfigMap)
+	if err != nil {
+		t.Fatalf("Failed to extract cluster info: %v", err)
+	}
+	if serverURL != "https://test-cluster.hcp.eastus.azmk8s.io:443" {
+		t.Errorf("Expected the current context's cluster, got %s", serverURL)
+	}
+
+	kubeconfigMap["contexts"] = []any{
+		map[string]any{"name": "test-cluster", "context": map[string]any{"cluster": "missing"}},
+	}
+	if _, _, err := extractClusterInfo(kubeconfigMap); err == nil || !strings.Contains(err.Error(), `"missing"`) {
+		t.Errorf("Expected error naming the missing cluster, got: %v", err)
+	}
+}
+
+func TestExtractClientCertificate_CurrentContextUser(t *testing.T) {
+	encode := func(value string) string { return base64.StdEncoding.EncodeToString([]byte(value)) }
+	kubeconfigMap := map[string]any{
+		"users": []any{
+			map[string]any{
+				"name": "proxyUser",
+				"user": map[string]any{"client-certificate-data": encode("proxy-cert"), "client-key-data": encode("proxy-key")},
+			},
+			map[string]any{
+				"name": "clusterAdmin_test-rg_test-cluster",
+				"user": map[string]any{"client-certificate-data": encode("admin-cert"), "client-key-data": encode("admin-key")},
+			},
+		},
+		"contexts": []any{
+			map[string]any{"name": "proxy", "context": map[string]any{"cluster": "management-proxy", "user": "proxyUser"}},
+			map[string]any{"name": "test-cluster-admin", "context": map[string]any{"cluster": "test-cluster", "user": "clusterAdmin_test-rg_test-cluster"}},
+		},
+		"current-context": "test-cluster-admin",
+	}
+
+	cert, key, err := extractClientCertificate(kubeconfigMap)
+	if err != nil {
+		t.Fatalf("Failed to extract client certificate: %v", err)
+	}
+	if string(cert) != "admin-cert" || string(key) != "admin-key" {
+		t.Errorf("Expected the current context's user credentials, got %q and %q", cert, key)
+	}
+
+	kubeconfigMap["contexts"] = []any{
+		map[string]any{"name": "test-cluster-admin", "context": map[string]any{"cluster": "test-cluster", "user": "missing"}},
+	}
+	if _, _, err := extractClientCertificate(kubeconfigMap); err == nil || !strings.Contains(err.Error(), `"missing"`) {
+		t.Errorf("Expected error naming the missing user, got: %v", err)
+	}
+}
+
 func TestExtractClusterInfo_MissingClusters(t *testing.T) {
 	kubeconfigMap := map[string]any{
 		"users": []any{},
@@ -306,6 +441,120 @@ func TestExtractClusterInfo_InvalidBase64(t *testing.T) {
 	}
 }
 
+func TestExtractClusterInfo_Validation(t *testing.T) {
+	validCA := testCACertBase64(t)
+	// Base64-valid PEM whose content is not a certificate
+	bogusCA := base64.StdEncoding.EncodeToString([]byte("-----BEGIN CERTIFICATE-----\nbm90IGEgY2VydGlmaWNhdGU=\n-----END CERTIFICATE-----\n"))
+	notPEM := base64.StdEncoding.EncodeToString([]byte("just some bytes"))
+
+	tests := []struct {
+		name    string
+		server  string
+		caData  string
+		wantErr string
+	}{
+		{name: "Valid certificate", server: "https://test.example.com:443", caData: validCA},
+		{name: "Non-certificate PEM", server: "https://test.example.com:443", caData: bogusCA, wantErr: "invalid CA certificate"},
+		{name: "Not PEM", server: "https://test.example.com:443", caData: notPEM, wantErr: "no PEM-encoded certificate"},
+		{name: "Non-https server", server: "http://test.example.com:443", caData: validCA, wantErr: "expected an https URL"},
+		{name: "Server without host", server: "https://", caData: validCA, wantErr: "expected an https URL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := extractClusterInfo(clusterInfoMap(tt.server, tt.caData))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFetchClusterInfo_RetriesUntilCAPropagates(t *testing.T) {
+	originalDelay := credentialPropagationDelay
+	credentialPropagationDelay = time.Millisecond
+	defer func() { credentialPropagationDelay = originalDelay }()
+
+	validCA := testCACertBase64(t)
+	kubeconfigWithCA := func(caData string) string {
+		kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: "%s"
+    server: https://test-cluster.hcp.eastus.azmk8s.io:443
+  name: test-cluster
+`, caData)
+		return base64.StdEncoding.EncodeToString([]byte(kubeconfig))
+	}
+
+	callCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callCount++
+		caData := validCA
+		if callCount == 1 {
+			// The CA has not propagated yet for a newly created cluster
+			caData = ""
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, `{"kubeconfigs":[{"name":"clusterUser","value":"%s"}]}`, kubeconfigWithCA(caData))
+	}))
+	defer server.Close()
+
+	client := &Client{subscriptionID: "test-subscription", accessToken: "mock-access-token", httpClient: &http.Client{}}
+
+	info, err := client.fetchClusterInfo(context.Background(), server.URL, kubeconfigNameUser)
+	if err != nil {
+		t.Fatalf("Expected credentials after CA propagation, got: %v", err)
+	}
+	if callCount != 2 {
+		t.Errorf("Expected 2 fetches, got %d", callCount)
+	}
+	if info.serverURL != "https://test-cluster.hcp.eastus.azmk8s.io:443" {
+		t.Errorf("Unexpected server URL: %s", info.serverURL)
+	}
+	if len(info.caCert) == 0 {
+		t.Error("Expected CA certificate data")
+	}
+}
+
+func TestFetchClusterInfo_GivesUpAfterBoundedAttempts(t *testing.T) {
+	originalDelay := credentialPropagationDelay
+	credentialPropagationDelay = time.Millisecond
+	defer func() { credentialPropagationDelay = originalDelay }()
+
+	callCount := 0
+	emptyCA := base64.StdEncoding.EncodeToString([]byte(`clusters:
+- cluster:
+    certificate-authority-data: ""
+    server: ""
+  name: test-cluster
+`))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callCount++
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, `{"kubeconfigs":[{"name":"clusterUser","value":"%s"}]}`, emptyCA)
+	}))
+	defer server.Close()
+
+	client := &Client{subscriptionID: "test-subscription", accessToken: "mock-access-token", httpClient: &http.Client{}}
+
+	_, err := client.fetchClusterInfo(context.Background(), server.URL, kubeconfigNameUser)
+	if !errors.Is(err, errCredentialsNotReady) {
+		t.Fatalf("Expected errCredentialsNotReady, got: %v", err)
+	}
+	if callCount != credentialPropagationAttempts {
+		t.Errorf("Expected %d fetches, got %d", credentialPropagationAttempts, callCount)
+	}
+}
+
 func TestNewClient(t *testing.T) {
 	client := NewClient("test-sub", "test-token")
 
@@ -319,3 +568,239 @@ func TestNewClient(t *testing.T) {
 		t.Error("Expected httpClient to be initialized")
 	}
 }
+
+func TestGetClusterCredentials_AzureADLocalAccountsDisabled(t *testing.T) {
+	// Shape returned by listClusterUserCredential for an AAD cluster with local accounts disabled
+	aadKubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: %s
+    server: https://test-cluster.hcp.eastus.azmk8s.io:443
+  name: test-cluster
+users:
+- name: clusterUser_test-rg_test-cluster
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: kubelogin
+      args:
+      - get-token
+      - --environment
+      - AzurePublicCloud
+      - --server-id
+      - 6dae42f8-4368-4678-94ff-3960e28e3630
+      - --login
+      - devicecode
+`, testCACertBase64(t))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "GET" {
+			_, _ = w.Write([]byte(`{"name":"test-cluster","properties":{"disableLocalAccounts":true,"privateFQDN":"test-cluster.privatelink.eastus.azmk8s.io","aadProfile":{"managed":true}}}`))
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"kubeconfigs":[{"name":"clusterUser","value":"%s"}]}`, base64.StdEncoding.EncodeToString([]byte(aadKubeconfig)))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-subscription", "mock-access-token")
+	client.SetManagementURL(server.URL)
+
+	credentials, err := client.GetClusterCredentials(context.Background(), "test-rg", "test-cluster")
+	if err != nil {
+		t.Fatalf("GetClusterCredentials failed: %v", err)
+	}
+	if !credentials.AzureAD {
+		t.Error("Expected Azure AD to be detected from the kubelogin exec user")
+	}
+	if !credentials.LocalAccountsDisabled {
+		t.Error("Expected local accounts to be reported as disabled")
+	}
+	if credentials.PrivateFQDN != "test-cluster.privatelink.eastus.azmk8s.io" {
+		t.Errorf("Expected private FQDN from the cluster properties, got %q", credentials.PrivateFQDN)
+	}
+}
+
+func TestGetClusterAdminCredentials(t *testing.T) {
+	adminKubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: %s
+    server: https://test-cluster.hcp.eastus.azmk8s.io:443
+  name: test-cluster
+users:
+- name: clusterAdmin_test-rg_test-cluster
+  user:
+    client-certificate-data: %s
+    client-key-data: %s
+    token: mock-admin-token
+`, testCACertBase64(t), base64.StdEncoding.EncodeToString([]byte("admin-cert")), base64.StdEncoding.EncodeToString([]byte("admin-key")))
+
+	var credentialPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "GET" {
+			_, _ = w.Write([]byte(`{"name":"test-cluster","properties":{"disableLocalAccounts":false}}`))
+			return
+		}
+		credentialPath = r.URL.Path
+		_, _ = fmt.Fprintf(w, `{"kubeconfigs":[{"name":"clusterAdmin","value":"%s"}]}`, base64.StdEncoding.EncodeToString([]byte(adminKubeconfig)))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-subscription", "mock-access-token")
+	client.SetManagementURL(server.URL)
+
+	credentials, err := client.GetClusterAdminCredentials(context.Background(), "test-rg", "test-cluster")
+	if err != nil {
+		t.Fatalf("GetClusterAdminCredentials failed: %v", err)
+	}
+	if !strings.HasSuffix(credentialPath, "/listClusterAdminCredential") {
+		t.Errorf("Expected listClusterAdminCredential to be called, got %s", credentialPath)
+	}
+	if !credentials.Admin {
+		t.Error("Expected admin credentials")
+	}
+	if string(credentials.ClientCertificate) != "admin-cert" || string(credentials.ClientKey) != "admin-key" {
+		t.Errorf("Unexpected client certificate/key: %q / %q", credentials.ClientCertificate, credentials.ClientKey)
+	}
+}
+
+func TestGetClusterAdminCredentials_LocalAccountsDisabled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Expected no credential request, got %s %s", r.Method, r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"test-cluster","properties":{"disableLocalAccounts":true}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-subscription", "mock-access-token")
+	client.SetManagementURL(server.URL)
+
+	_, err := client.GetClusterAdminCredentials(context.Background(), "test-rg", "test-cluster")
+	if err == nil || !strings.Contains(err.Error(), "local accounts disabled") {
+		t.Errorf("Expected local accounts error, got: %v", err)
+	}
+}
+
+func TestUsesAzureAD(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     map[string]any
+		expected bool
+	}{
+		{"kubelogin exec", map[string]any{"exec": map[string]any{"command": "/usr/local/bin/kubelogin"}}, true},
+		{"azure auth-provider", map[string]any{"auth-provider": map[string]any{"name": "azure"}}, true},
+		{"static token", map[string]any{"token": "mock-token"}, false},
+		{"client certificate", map[string]any{"client-certificate-data": "data", "client-key-data": "data"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeconfigMap := map[string]any{
+				"users": []any{map[string]any{"name": "clusterUser", "user": tt.user}},
+			}
+			if got := usesAzureAD(kubeconfigMap); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestClusterCredentials_UsePrivateFQDN(t *testing.T) {
+	credentials := &ClusterCredentials{
+		ClusterName: "private-cluster",
+		ServerURL:   "https://private-cluster-dns.hcp.eastus.azmk8s.io:443",
+		PrivateFQDN: "private-cluster-dns.abc123.privatelink.eastus.azmk8s.io",
+	}
+	if err := credentials.UsePrivateFQDN(); err != nil {
+		t.Fatalf("UsePrivateFQDN failed: %v", err)
+	}
+	expected := "https://private-cluster-dns.abc123.privatelink.eastus.azmk8s.io:443"
+	if credentials.ServerURL != expected {
+		t.Errorf("Expected %s, got %s", expected, credentials.ServerURL)
+	}
+
+	public := &ClusterCredentials{ClusterName: "public-cluster", ServerURL: "https://public.example.com:443"}
+	err := public.UsePrivateFQDN()
+	if err == nil || !strings.Contains(err.Error(), "no private FQDN") {
+		t.Errorf("Expected error for a cluster without private FQDN, got: %v", err)
+	}
+	if public.ServerURL != "https://public.example.com:443" {
+		t.Errorf("Expected server URL to be unchanged, got %s", public.ServerURL)
+	}
+}
+
+func TestGetClusterCredentials_SelectsNamedKubeconfig(t *testing.T) {
+	kubeconfigFor := func(server string) string {
+		return base64.StdEncoding.EncodeToString(fmt.Appendf(nil, `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: %s
+    server: %s
+  name: test-cluster
+users:
+- name: user
+  user:
+    client-certificate-data: %s
+    client-key-data: %s
+`, testCACertBase64(t), server, base64.StdEncoding.EncodeToString([]byte("cert")), base64.StdEncoding.EncodeToString([]byte("key"))))
+	}
+
+	// The requested entry is not first, and the others must not be picked instead
+	kubeconfigs := fmt.Sprintf(`{"kubeconfigs":[
+		{"name":"clusterMonitoringUser","value":"%s"},
+		{"name":"clusterAdmin","value":"%s"},
+		{"name":"clusterUser","value":"%s"}
+	]}`, kubeconfigFor("https://monitoring.example.com:443"), kubeconfigFor("https://admin.example.com:443"), kubeconfigFor("https://user.example.com:443"))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "GET" {
+			_, _ = w.Write([]byte(`{"name":"test-cluster","properties":{}}`))
+			return
+		}
+		_, _ = w.Write([]byte(kubeconfigs))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-subscription", "mock-access-token")
+	client.SetManagementURL(server.URL)
+
+	user, err := client.GetClusterCredentials(context.Background(), "test-rg", "test-cluster")
+	if err != nil {
+		t.Fatalf("GetClusterCredentials failed: %v", err)
+	}
+	if user.ServerURL != "https://user.example.com:443" {
+		t.Errorf("Expected the clusterUser kubeconfig, got server %s", user.ServerURL)
+	}
+
+	admin, err := client.GetClusterAdminCredentials(context.Background(), "test-rg", "test-cluster")
+	if err != nil {
+		t.Fatalf("GetClusterAdminCredentials failed: %v", err)
+	}
+	if admin.ServerURL != "https://admin.example.com:443" {
+		t.Errorf("Expected the clusterAdmin kubeconfig, got server %s", admin.ServerURL)
+	}
+}
+
+func TestClusterCredentialResponse_Kubeconfig(t *testing.T) {
+	var empty clusterUserCredentialResponse
+	if _, err := empty.kubeconfig(kubeconfigNameUser); err == nil || !strings.Contains(err.Error(), "no kubeconfig returned") {
+		t.Errorf("Expected error for an empty kubeconfigs list, got: %v", err)
+	}
+
+	var monitoringOnly clusterUserCredentialResponse
+	if err := json.Unmarshal([]byte(`{"kubeconfigs":[{"name":"clusterMonitoringUser","value":"abc"}]}`), &monitoringOnly); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	_, err := monitoringOnly.kubeconfig(kubeconfigNameAdmin)
+	if err == nil || !strings.Contains(err.Error(), `no "clusterAdmin" kubeconfig`) || !strings.Contains(err.Error(), "clusterMonitoringUser") {
+		t.Errorf("Expected error naming the missing and returned entries, got: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package aks
+
+import "fmt"
+
+// Kubelogin modes supported by ConvertAzureAuthProviders
+const (
+	// ConvertModeAzureLogin authenticates via azure-login kubectl-credential
+	ConvertModeAzureLogin = "azure-login"
+
+	// ConvertModeAzureCLI authenticates via kubelogin using the Azure CLI token cache
+	ConvertModeAzureCLI = "azurecli"
+)
+
+// azureAuthProviderName is the auth-provider name written by `az aks get-credentials --format azure`
+const azureAuthProviderName = "azure"
+
+// ConvertAzureAuthProviders rewrites every user entry that uses the legacy azure
+// auth-provider to an exec configuration for the given mode, mirroring
+// `kubelogin convert-kubeconfig`. It returns the names of the converted users.
+func (k *Kubeconfig) ConvertAzureAuthProviders(mode, azureLoginPath string) ([]string, error) {
+	if mode != ConvertModeAzureLogin && mode != ConvertModeAzureCLI {
+		return nil, fmt.Errorf("unsupported kubelogin mode %q (supported: %s, %s)", mode, ConvertModeAzureLogin, ConvertModeAzureCLI)
+	}
+
+	var converted []string
+	for i, existing := range k.Users {
+		provider := existing.User.AuthProvider
+		if provider == nil || provider.Name != azureAuthProviderName {
+			continue
+		}
+
+		user := azureLoginUser(azureLoginPath, "", "")
+		if mode == ConvertModeAzureCLI {
+			serverID := provider.Config[AzureAuthProviderAPIServerID]
+			if serverID == "" {
+				return nil, fmt.Errorf("user %s has no apiserver-id in its azure auth-provider config", existing.Name)
+			}
+			user = kubeloginUser(LoginMethodAzureCLI, serverID, "", "")
+		}
+
+		k.Users[i].User = user
+		converted = append(converted, existing.Name)
+	}
+
+	return converted, nil
+}
@@ -0,0 +1,118 @@
+package aks
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const azureAuthProviderKubeconfig = `apiVersion: v1
+kind: Config
+current-context: legacy-cluster
+clusters:
+- name: legacy-cluster
+  cluster:
+    server: https://legacy.example.com
+    certificate-authority-data: dGVzdA==
+contexts:
+- name: legacy-cluster
+  context:
+    cluster: legacy-cluster
+    user: clusterUser_rg_legacy-cluster
+users:
+- name: clusterUser_rg_legacy-cluster
+  user:
+    auth-provider:
+      name: azure
+      config:
+        apiserver-id: 6dae42f8-4368-4678-94ff-3960e28e3630
+        client-id: 80faf920-1908-4b52-b5ef-a8e7bedfc67a
+        tenant-id: test-tenant
+        environment: AzurePublicCloud
+- name: other-user
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: other
+`
+
+func loadAzureAuthProviderKubeconfig(t *testing.T) *Kubeconfig {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(azureAuthProviderKubeconfig), 0600); err != nil {
+		t.Fatalf("Failed to write test kubeconfig: %v", err)
+	}
+	config, err := LoadKubeconfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load kubeconfig: %v", err)
+	}
+	return config
+}
+
+func TestConvertAzureAuthProviders_AzureLogin(t *testing.T) {
+	config := loadAzureAuthProviderKubeconfig(t)
+
+	converted, err := config.ConvertAzureAuthProviders(ConvertModeAzureLogin, "/usr/local/bin/azure-login")
+	if err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+	if len(converted) != 1 || converted[0] != "clusterUser_rg_legacy-cluster" {
+		t.Fatalf("Expected only the azure auth-provider user to be converted, got %v", converted)
+	}
+
+	user := config.Users[0].User
+	if user.AuthProvider != nil {
+		t.Error("Expected auth-provider to be removed")
+	}
+	if user.Exec == nil {
+		t.Fatal("Expected exec config")
+	}
+	if user.Exec.Command != "/usr/local/bin/azure-login" {
+		t.Errorf("Expected azure-login command, got %s", user.Exec.Command)
+	}
+	if len(user.Exec.Args) != 1 || user.Exec.Args[0] != "kubectl-credential" {
+		t.Errorf("Expected args [kubectl-credential], got %v", user.Exec.Args)
+	}
+
+	// Non-azure users are left untouched
+	if config.Users[1].User.Exec.Command != "other" {
+		t.Errorf("Expected other user to be unchanged, got %s", config.Users[1].User.Exec.Command)
+	}
+}
+
+func TestConvertAzureAuthProviders_AzureCLI(t *testing.T) {
+	config := loadAzureAuthProviderKubeconfig(t)
+
+	if _, err := config.ConvertAzureAuthProviders(ConvertModeAzureCLI, ""); err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+
+	exec := config.Users[0].User.Exec
+	if exec == nil {
+		t.Fatal("Expected exec config")
+	}
+	if exec.Command != "kubelogin" {
+		t.Errorf("Expected kubelogin command, got %s", exec.Command)
+	}
+	expected := []string{"get-token", "--login", "azurecli", "--server-id", "6dae42f8-4368-4678-94ff-3960e28e3630"}
+	if len(exec.Args) != len(expected) {
+		t.Fatalf("Expected args %v, got %v", expected, exec.Args)
+	}
+	for i := range expected {
+		if exec.Args[i] != expected[i] {
+			t.Errorf("Expected args %v, got %v", expected, exec.Args)
+			break
+		}
+	}
+}
+
+func TestConvertAzureAuthProviders_UnsupportedMode(t *testing.T) {
+	config := loadAzureAuthProviderKubeconfig(t)
+
+	if _, err := config.ConvertAzureAuthProviders("devicecode", ""); err == nil {
+		t.Fatal("Expected error for unsupported mode, got none")
+	}
+	if config.Users[0].User.AuthProvider == nil {
+		t.Error("Expected user to be unchanged after failed conversion")
+	}
+}
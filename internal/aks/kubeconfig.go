@@ -53,9 +53,26 @@ type NamedUser struct {
 
 // User represents user authentication configuration
 type User struct {
-	Exec *ExecConfig `yaml:"exec,omitempty"`
+	Exec                  *ExecConfig         `yaml:"exec,omitempty"`
+	AuthProvider          *AuthProviderConfig `yaml:"auth-provider,omitempty"`
+	ClientCertificateData string              `yaml:"client-certificate-data,omitempty"`
+	ClientKeyData         string              `yaml:"client-key-data,omitempty"`
 }
 
+// AuthProviderConfig represents a legacy auth-provider block (e.g. name "azure")
+type AuthProviderConfig struct {
+	Name   string            `yaml:"name"`
+	Config map[string]string `yaml:"config,omitempty"`
+}
+
+// Config keys of the azure auth-provider (as written by `az aks get-credentials --format azure`)
+const (
+	AzureAuthProviderAPIServerID = "apiserver-id"
+	AzureAuthProviderClientID    = "client-id"
+	AzureAuthProviderTenantID    = "tenant-id"
+	AzureAuthProviderEnvironment = "environment"
+)
+
 // ExecConfig represents exec-based authentication
 type ExecConfig struct {
 	APIVersion         string       `yaml:"apiVersion"`
@@ -102,6 +119,9 @@ func LoadKubeconfig(path string) (*Kubeconfig, error) {
 				Preferences: map[string]any{},
 			}, nil
 		}
+		if dirErr := checkKubeconfigDir(filepath.Dir(path)); dirErr != nil {
+			return nil, dirErr
+		}
 		return nil, fmt.Errorf("failed to read kubeconfig: %w", err)
 	}
 
@@ -124,10 +144,22 @@ func LoadKubeconfig(path string) (*Kubeconfig, error) {
 	return &config, nil
 }
 
+// checkKubeconfigDir returns a descriptive error if the kubeconfig directory
+// (usually ~/.kube) exists but is a regular file rather than a directory
+func checkKubeconfigDir(dir string) error {
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		return fmt.Errorf("kubeconfig directory %s exists but is not a directory; remove or rename it, or set KUBECONFIG to another path", dir)
+	}
+	return nil
+}
+
 // SaveKubeconfig saves the kubeconfig to disk atomically
 func SaveKubeconfig(path string, config *Kubeconfig) error {
 	// Ensure directory exists
 	dir := filepath.Dir(path)
+	if err := checkKubeconfigDir(dir); err != nil {
+		return err
+	}
 	if err := os.MkdirAll(dir, 0700); err != nil {
 		return fmt.Errorf("failed to create kubeconfig directory: %w", err)
 	}
@@ -152,11 +184,104 @@ func SaveKubeconfig(path string, config *Kubeconfig) error {
 	return nil
 }
 
-// MergeClusterCredentials merges AKS cluster credentials into kubeconfig
-func (k *Kubeconfig) MergeClusterCredentials(creds *ClusterCredentials, azureLoginPath string) {
+// BackupKubeconfig copies an existing kubeconfig to <path>.bak, replacing any previous
+// backup, so a bad merge can be undone. It returns the backup path, or "" if there is
+// no kubeconfig to back up.
+func BackupKubeconfig(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read kubeconfig for backup: %w", err)
+	}
+
+	backupPath := path + ".bak"
+	if err := os.WriteFile(backupPath, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to back up kubeconfig: %w", err)
+	}
+	// WriteFile keeps the mode of an existing backup; kubeconfigs hold credentials
+	if err := os.Chmod(backupPath, 0600); err != nil {
+		return "", fmt.Errorf("failed to back up kubeconfig: %w", err)
+	}
+	return backupPath, nil
+}
+
+// MergeOptions customizes how cluster credentials are merged into kubeconfig
+type MergeOptions struct {
+	// Namespace sets the context's default namespace. If empty, an existing
+	// context keeps its namespace.
+	Namespace string
+
+	// ExtraArgs are appended to the generated exec args (e.g. --environment)
+	ExtraArgs []string
+
+	// ExtraEnv are added to the generated exec environment
+	ExtraEnv []ExecEnvVar
+
+	// OverwriteExisting allows replacing a context of the same name that points at a
+	// different server. Without it such a conflict is an error.
+	OverwriteExisting bool
+
+	// KeepCurrentContext leaves current-context unchanged instead of switching to the
+	// merged context
+	KeepCurrentContext bool
+
+	// LoginMethod selects how the exec user authenticates (one of the LoginMethod
+	// constants); empty means LoginMethodAzureLogin
+	LoginMethod string
+
+	// Profile is the named token profile kubectl-credential uses (--profile); empty
+	// means the default profile
+	Profile string
+}
+
+// Login methods of the exec user written for Azure AD clusters
+const (
+	// LoginMethodAzureLogin authenticates via azure-login kubectl-credential
+	LoginMethodAzureLogin = "azure-login"
+
+	// LoginMethodAzureCLI authenticates via kubelogin using the Azure CLI token cache
+	LoginMethodAzureCLI = "azurecli"
+
+	// LoginMethodWorkloadIdentity authenticates via kubelogin with a federated token
+	// (AZURE_FEDERATED_TOKEN_FILE), as in workload identity pods and OIDC CI jobs
+	LoginMethodWorkloadIdentity = "workloadidentity"
+
+	// LoginMethodSPN authenticates via kubelogin with a service principal secret
+	// (AAD_SERVICE_PRINCIPAL_CLIENT_SECRET)
+	LoginMethodSPN = "spn"
+)
+
+// AKSServerID is the application ID of the AKS Azure AD server, the audience of the
+// tokens presented to AAD-enabled clusters
+const AKSServerID = "6dae42f8-4368-4678-94ff-3960e28e3630"
+
+// ValidateLoginMethod checks that method is a supported exec login method
+func ValidateLoginMethod(method string) error {
+	switch method {
+	case LoginMethodAzureLogin, LoginMethodAzureCLI, LoginMethodWorkloadIdentity, LoginMethodSPN:
+		return nil
+	}
+	return fmt.Errorf("unsupported login method %q (supported: %s, %s, %s, %s)", method, LoginMethodAzureLogin, LoginMethodAzureCLI, LoginMethodWorkloadIdentity, LoginMethodSPN)
+}
+
+// MergeClusterCredentials merges AKS cluster credentials into kubeconfig. Admin
+// credentials are written as a client-certificate user and a "<cluster>-admin"
+// context, following the Azure CLI naming. It returns the merged context name.
+func (k *Kubeconfig) MergeClusterCredentials(creds *ClusterCredentials, azureLoginPath string, opts MergeOptions) (string, error) {
 	clusterName := creds.ClusterName
 	contextName := clusterName
 	userName := fmt.Sprintf("clusterUser_%s_%s", creds.ResourceGroup, creds.ClusterName)
+	if creds.Admin {
+		contextName = clusterName + "-admin"
+		userName = fmt.Sprintf("clusterAdmin_%s_%s", creds.ResourceGroup, creds.ClusterName)
+	}
+
+	// Refuse to silently repoint an existing context at another cluster
+	if server, ok := k.contextServer(contextName); ok && server != creds.ServerURL && !opts.OverwriteExisting {
+		return "", fmt.Errorf("context %q already exists for server %s (not %s); pass --overwrite-existing to replace it", contextName, server, creds.ServerURL)
+	}
 
 	// Encode CA certificate to base64
 	caCertBase64 := base64.StdEncoding.EncodeToString(creds.CACertificate)
@@ -164,14 +289,39 @@ func (k *Kubeconfig) MergeClusterCredentials(creds *ClusterCredentials, azureLog
 	// Add or update cluster
 	k.upsertCluster(clusterName, creds.ServerURL, caCertBase64)
 
-	// Add or update user with Azure CLI authentication
-	k.upsertUser(userName, azureLoginPath)
+	if creds.Admin {
+		k.setUser(userName, User{
+			ClientCertificateData: base64.StdEncoding.EncodeToString(creds.ClientCertificate),
+			ClientKeyData:         base64.StdEncoding.EncodeToString(creds.ClientKey),
+		})
+	} else {
+		// Add or update user with exec (Azure AD) authentication
+		k.upsertUser(userName, azureLoginPath, creds, opts)
+	}
 
 	// Add or update context
-	k.upsertContext(contextName, clusterName, userName)
+	k.upsertContext(contextName, clusterName, userName, opts.Namespace)
 
 	// Set as current context
-	k.CurrentContext = contextName
+	if !opts.KeepCurrentContext {
+		k.CurrentContext = contextName
+	}
+	return contextName, nil
+}
+
+// contextServer returns the server URL of the named context's cluster
+func (k *Kubeconfig) contextServer(name string) (string, bool) {
+	for _, ctx := range k.Contexts {
+		if ctx.Name != name {
+			continue
+		}
+		for _, cluster := range k.Clusters {
+			if cluster.Name == ctx.Context.Cluster {
+				return cluster.Cluster.Server, true
+			}
+		}
+	}
+	return "", false
 }
 
 func (k *Kubeconfig) upsertCluster(name, server, caCert string) {
@@ -193,49 +343,91 @@ func (k *Kubeconfig) upsertCluster(name, server, caCert string) {
 	})
 }
 
-func (k *Kubeconfig) upsertUser(name, azureLoginPath string) {
+// azureLoginUser returns a user entry that authenticates via azure-login kubectl-credential
+func azureLoginUser(azureLoginPath, subscriptionID, profile string) User {
 	// Use full path if provided, otherwise fall back to "azure-login" in PATH
 	command := "azure-login"
 	if azureLoginPath != "" {
 		command = azureLoginPath
 	}
 
-	for i, user := range k.Users {
-		if user.Name == name {
-			// Update existing user with azure-login credential helper
-			k.Users[i].User = User{
-				Exec: &ExecConfig{
-					APIVersion: "client.authentication.k8s.io/v1beta1",
-					Command:    command,
-					Args: []string{
-						"kubectl-credential",
-					},
-				},
-			}
+	// The subscription is recorded so kubectl-credential can detect when the cached
+	// login belongs to a different subscription than the cluster
+	args := []string{"kubectl-credential"}
+	if profile != "" {
+		args = append(args, "--profile", profile)
+	}
+	if subscriptionID != "" {
+		args = append(args, "--subscription-id", subscriptionID)
+	}
+
+	return User{
+		Exec: &ExecConfig{
+			APIVersion: "client.authentication.k8s.io/v1beta1",
+			Command:    command,
+			Args:       args,
+		},
+	}
+}
+
+// kubeloginUser returns a user entry that authenticates via kubelogin get-token with
+// the given login method. The service principal's tenant and client are passed for
+// the spn and workloadidentity methods when known; kubelogin otherwise reads them
+// from AZURE_TENANT_ID and AZURE_CLIENT_ID.
+func kubeloginUser(method, serverID, tenantID, clientID string) User {
+	args := []string{"get-token", "--login", method, "--server-id", serverID}
+	if method == LoginMethodSPN || method == LoginMethodWorkloadIdentity {
+		if clientID != "" {
+			args = append(args, "--client-id", clientID)
+		}
+		if tenantID != "" {
+			args = append(args, "--tenant-id", tenantID)
+		}
+	}
+
+	return User{
+		Exec: &ExecConfig{
+			APIVersion: "client.authentication.k8s.io/v1beta1",
+			Command:    "kubelogin",
+			Args:       args,
+		},
+	}
+}
+
+func (k *Kubeconfig) upsertUser(name, azureLoginPath string, creds *ClusterCredentials, opts MergeOptions) {
+	user := azureLoginUser(azureLoginPath, creds.SubscriptionID, opts.Profile)
+	if opts.LoginMethod != "" && opts.LoginMethod != LoginMethodAzureLogin {
+		user = kubeloginUser(opts.LoginMethod, AKSServerID, creds.TenantID, creds.ClientID)
+	}
+	user.Exec.Args = append(user.Exec.Args, opts.ExtraArgs...)
+	user.Exec.Env = append(user.Exec.Env, opts.ExtraEnv...)
+	k.setUser(name, user)
+}
+
+// setUser replaces the named user entry, adding it if missing
+func (k *Kubeconfig) setUser(name string, user User) {
+	for i, existing := range k.Users {
+		if existing.Name == name {
+			k.Users[i].User = user
 			return
 		}
 	}
 
-	// Add new user with azure-login credential helper
 	k.Users = append(k.Users, NamedUser{
 		Name: name,
-		User: User{
-			Exec: &ExecConfig{
-				APIVersion: "client.authentication.k8s.io/v1beta1",
-				Command:    command,
-				Args: []string{
-					"kubectl-credential",
-				},
-			},
-		},
+		User: user,
 	})
 }
 
-func (k *Kubeconfig) upsertContext(name, cluster, user string) {
+func (k *Kubeconfig) upsertContext(name, cluster, user, namespace string) {
 	for i, ctx := range k.Contexts {
 		if ctx.Name == name {
 			k.Contexts[i].Context.Cluster = cluster
 			k.Contexts[i].Context.User = user
+			// Preserve a namespace the user chose unless a new one is requested
+			if namespace != "" {
+				k.Contexts[i].Context.Namespace = namespace
+			}
 			return
 		}
 	}
@@ -244,8 +436,9 @@ func (k *Kubeconfig) upsertContext(name, cluster, user string) {
 	k.Contexts = append(k.Contexts, NamedContext{
 		Name: name,
 		Context: Context{
-			Cluster: cluster,
-			User:    user,
+			Cluster:   cluster,
+			User:      user,
+			Namespace: namespace,
 		},
 	})
 }
@@ -1,8 +1,10 @@
 package aks
 
 import (
+	"encoding/base64"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -141,6 +143,33 @@ func TestSaveKubeconfig(t *testing.T) {
 	}
 }
 
+func TestSaveKubeconfig_DirIsFile(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Create a regular file where the .kube directory is expected
+	kubeDir := filepath.Join(tempDir, ".kube")
+	if err := os.WriteFile(kubeDir, []byte("not a directory"), 0600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	kubeconfigPath := filepath.Join(kubeDir, "config")
+
+	err := SaveKubeconfig(kubeconfigPath, &Kubeconfig{APIVersion: "v1", Kind: "Config"})
+	if err == nil {
+		t.Fatal("Expected error when kubeconfig directory is a file, got none")
+	}
+	if !strings.Contains(err.Error(), "exists but is not a directory") {
+		t.Errorf("Expected helpful error message, got: %v", err)
+	}
+
+	_, err = LoadKubeconfig(kubeconfigPath)
+	if err == nil {
+		t.Fatal("Expected error loading kubeconfig when directory is a file, got none")
+	}
+	if !strings.Contains(err.Error(), "exists but is not a directory") {
+		t.Errorf("Expected helpful error message, got: %v", err)
+	}
+}
+
 func TestMergeClusterCredentials_NewCluster(t *testing.T) {
 	config := &Kubeconfig{
 		APIVersion: "v1",
@@ -158,7 +187,7 @@ func TestMergeClusterCredentials_NewCluster(t *testing.T) {
 		SubscriptionID: "test-sub",
 	}
 
-	config.MergeClusterCredentials(credentials, "/usr/local/bin/azure-login")
+	config.MergeClusterCredentials(credentials, "/usr/local/bin/azure-login", MergeOptions{})
 
 	// Verify cluster was added
 	if len(config.Clusters) != 1 {
@@ -188,6 +217,10 @@ func TestMergeClusterCredentials_NewCluster(t *testing.T) {
 	if len(config.Users[0].User.Exec.Args) == 0 || config.Users[0].User.Exec.Args[0] != "kubectl-credential" {
 		t.Errorf("Expected args [kubectl-credential], got %v", config.Users[0].User.Exec.Args)
 	}
+	expectedArgs := []string{"kubectl-credential", "--subscription-id", "test-sub"}
+	if strings.Join(config.Users[0].User.Exec.Args, " ") != strings.Join(expectedArgs, " ") {
+		t.Errorf("Expected args %v, got %v", expectedArgs, config.Users[0].User.Exec.Args)
+	}
 
 	// Verify context was added
 	if len(config.Contexts) != 1 {
@@ -206,6 +239,43 @@ func TestMergeClusterCredentials_NewCluster(t *testing.T) {
 	}
 }
 
+func TestMergeClusterCredentials_Admin(t *testing.T) {
+	config := &Kubeconfig{APIVersion: "v1", Kind: "Config"}
+
+	credentials := &ClusterCredentials{
+		ClusterName:       "prod-cluster",
+		ServerURL:         "https://prod-cluster.example.com",
+		CACertificate:     []byte("test-ca-cert"),
+		ResourceGroup:     "prod-rg",
+		SubscriptionID:    "test-sub",
+		Admin:             true,
+		ClientCertificate: []byte("admin-cert"),
+		ClientKey:         []byte("admin-key"),
+	}
+
+	config.MergeClusterCredentials(credentials, "/usr/local/bin/azure-login", MergeOptions{})
+
+	if len(config.Users) != 1 || config.Users[0].Name != "clusterAdmin_prod-rg_prod-cluster" {
+		t.Fatalf("Expected clusterAdmin_prod-rg_prod-cluster user, got %+v", config.Users)
+	}
+	user := config.Users[0].User
+	if user.Exec != nil {
+		t.Error("Expected no exec config for admin credentials")
+	}
+	if user.ClientCertificateData != base64.StdEncoding.EncodeToString([]byte("admin-cert")) {
+		t.Errorf("Unexpected client-certificate-data: %s", user.ClientCertificateData)
+	}
+	if user.ClientKeyData != base64.StdEncoding.EncodeToString([]byte("admin-key")) {
+		t.Errorf("Unexpected client-key-data: %s", user.ClientKeyData)
+	}
+	if config.CurrentContext != "prod-cluster-admin" {
+		t.Errorf("Expected current-context prod-cluster-admin, got %s", config.CurrentContext)
+	}
+	if config.Contexts[0].Context.User != "clusterAdmin_prod-rg_prod-cluster" || config.Contexts[0].Context.Cluster != "prod-cluster" {
+		t.Errorf("Unexpected admin context: %+v", config.Contexts[0].Context)
+	}
+}
+
 func TestMergeClusterCredentials_UpdateExisting(t *testing.T) {
 	config := &Kubeconfig{
 		APIVersion: "v1",
@@ -244,7 +314,9 @@ func TestMergeClusterCredentials_UpdateExisting(t *testing.T) {
 		SubscriptionID: "test-sub",
 	}
 
-	config.MergeClusterCredentials(credentials, "/usr/local/bin/azure-login")
+	if _, err := config.MergeClusterCredentials(credentials, "/usr/local/bin/azure-login", MergeOptions{OverwriteExisting: true}); err != nil {
+		t.Fatalf("MergeClusterCredentials failed: %v", err)
+	}
 
 	// Verify cluster was updated (not duplicated)
 	if len(config.Clusters) != 1 {
@@ -278,6 +350,196 @@ func TestMergeClusterCredentials_UpdateExisting(t *testing.T) {
 	}
 }
 
+func TestMergeClusterCredentials_RefusesServerConflict(t *testing.T) {
+	config := &Kubeconfig{
+		CurrentContext: "other",
+		Clusters:       []NamedCluster{{Name: "prod", Cluster: Cluster{Server: "https://old-prod.example.com"}}},
+		Contexts:       []NamedContext{{Name: "prod", Context: Context{Cluster: "prod", User: "someone"}}},
+	}
+	credentials := &ClusterCredentials{
+		ClusterName:   "prod",
+		ServerURL:     "https://new-prod.example.com",
+		CACertificate: []byte("ca"),
+		ResourceGroup: "rg",
+	}
+
+	_, err := config.MergeClusterCredentials(credentials, "azure-login", MergeOptions{})
+	if err == nil || !strings.Contains(err.Error(), `context "prod"`) {
+		t.Fatalf("Expected conflict error naming the context, got: %v", err)
+	}
+	if config.Clusters[0].Cluster.Server != "https://old-prod.example.com" || config.CurrentContext != "other" {
+		t.Error("Expected kubeconfig to be unchanged after a refused merge")
+	}
+
+	// The same server is not a conflict, and the current context can be kept
+	credentials.ServerURL = "https://old-prod.example.com"
+	name, err := config.MergeClusterCredentials(credentials, "azure-login", MergeOptions{KeepCurrentContext: true})
+	if err != nil {
+		t.Fatalf("Expected merge for the same server, got: %v", err)
+	}
+	if name != "prod" || config.CurrentContext != "other" {
+		t.Errorf("Expected context prod merged with current-context kept, got %s / %s", name, config.CurrentContext)
+	}
+}
+
+func TestMergeClusterCredentials_PreservesNamespace(t *testing.T) {
+	newConfig := func() *Kubeconfig {
+		return &Kubeconfig{
+			APIVersion: "v1",
+			Kind:       "Config",
+			Contexts: []NamedContext{
+				{
+					Name: "test-cluster",
+					Context: Context{
+						Cluster:   "test-cluster",
+						User:      "old-user",
+						Namespace: "prod",
+					},
+				},
+			},
+		}
+	}
+	credentials := &ClusterCredentials{
+		ClusterName:   "test-cluster",
+		ServerURL:     "https://test.example.com",
+		CACertificate: []byte("test-ca-cert"),
+		ResourceGroup: "test-rg",
+	}
+
+	tests := []struct {
+		name          string
+		namespace     string
+		wantNamespace string
+	}{
+		{name: "Without namespace keeps existing", namespace: "", wantNamespace: "prod"},
+		{name: "With namespace overrides existing", namespace: "dev", wantNamespace: "dev"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newConfig()
+			config.MergeClusterCredentials(credentials, "/usr/local/bin/azure-login", MergeOptions{Namespace: tt.namespace})
+
+			if len(config.Contexts) != 1 {
+				t.Fatalf("Expected 1 context, got %d", len(config.Contexts))
+			}
+			if config.Contexts[0].Context.Namespace != tt.wantNamespace {
+				t.Errorf("Expected namespace %s, got %s", tt.wantNamespace, config.Contexts[0].Context.Namespace)
+			}
+		})
+	}
+}
+
+func TestMergeClusterCredentials_NamespaceOnNewContext(t *testing.T) {
+	config := &Kubeconfig{APIVersion: "v1", Kind: "Config"}
+	credentials := &ClusterCredentials{
+		ClusterName:   "test-cluster",
+		ServerURL:     "https://test.example.com",
+		CACertificate: []byte("test-ca-cert"),
+		ResourceGroup: "test-rg",
+	}
+
+	if _, err := config.MergeClusterCredentials(credentials, "/usr/local/bin/azure-login", MergeOptions{Namespace: "team-a"}); err != nil {
+		t.Fatalf("MergeClusterCredentials failed: %v", err)
+	}
+	if len(config.Contexts) != 1 || config.Contexts[0].Context.Namespace != "team-a" {
+		t.Errorf("Expected new context with namespace team-a, got %+v", config.Contexts)
+	}
+}
+
+func TestMergeClusterCredentials_ExtraExecArgsAndEnv(t *testing.T) {
+	config := &Kubeconfig{APIVersion: "v1", Kind: "Config"}
+	credentials := &ClusterCredentials{
+		ClusterName:    "test-cluster",
+		ServerURL:      "https://test.example.com",
+		CACertificate:  []byte("test-ca-cert"),
+		ResourceGroup:  "test-rg",
+		SubscriptionID: "test-sub",
+	}
+
+	config.MergeClusterCredentials(credentials, "/usr/local/bin/azure-login", MergeOptions{
+		ExtraArgs: []string{"--environment", "AzureUSGovernmentCloud"},
+		ExtraEnv:  []ExecEnvVar{{Name: "AZURE_AUTHORITY_HOST", Value: "https://login.microsoftonline.us"}},
+	})
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		t.Fatalf("Failed to marshal kubeconfig: %v", err)
+	}
+	yamlStr := string(data)
+
+	for _, want := range []string{
+		"- kubectl-credential",
+		"- --subscription-id",
+		"- --environment",
+		"- AzureUSGovernmentCloud",
+		"name: AZURE_AUTHORITY_HOST",
+		"value: https://login.microsoftonline.us",
+	} {
+		if !strings.Contains(yamlStr, want) {
+			t.Errorf("Expected serialized user to contain %q, got:\n%s", want, yamlStr)
+		}
+	}
+
+	// Custom args come after the generated ones
+	args := config.Users[0].User.Exec.Args
+	if args[0] != "kubectl-credential" || args[len(args)-1] != "AzureUSGovernmentCloud" {
+		t.Errorf("Expected custom args to be appended, got %v", args)
+	}
+}
+
+func TestMergeClusterCredentials_LoginMethod(t *testing.T) {
+	credentials := &ClusterCredentials{
+		ClusterName:    "test-cluster",
+		ServerURL:      "https://test.example.com",
+		CACertificate:  []byte("test-ca-cert"),
+		ResourceGroup:  "test-rg",
+		SubscriptionID: "test-sub",
+		TenantID:       "test-tenant",
+		ClientID:       "test-client",
+	}
+
+	tests := []struct {
+		method  string
+		command string
+		args    []string
+	}{
+		{"", "/usr/local/bin/azure-login", []string{"kubectl-credential", "--subscription-id", "test-sub"}},
+		{LoginMethodAzureLogin, "/usr/local/bin/azure-login", []string{"kubectl-credential", "--subscription-id", "test-sub"}},
+		{LoginMethodAzureCLI, "kubelogin", []string{"get-token", "--login", "azurecli", "--server-id", AKSServerID}},
+		{LoginMethodWorkloadIdentity, "kubelogin", []string{"get-token", "--login", "workloadidentity", "--server-id", AKSServerID, "--client-id", "test-client", "--tenant-id", "test-tenant"}},
+		{LoginMethodSPN, "kubelogin", []string{"get-token", "--login", "spn", "--server-id", AKSServerID, "--client-id", "test-client", "--tenant-id", "test-tenant"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			config := &Kubeconfig{APIVersion: "v1", Kind: "Config"}
+			if _, err := config.MergeClusterCredentials(credentials, "/usr/local/bin/azure-login", MergeOptions{LoginMethod: tt.method}); err != nil {
+				t.Fatalf("Merge failed: %v", err)
+			}
+
+			exec := config.Users[0].User.Exec
+			if exec.Command != tt.command {
+				t.Errorf("Expected command %s, got %s", tt.command, exec.Command)
+			}
+			if strings.Join(exec.Args, " ") != strings.Join(tt.args, " ") {
+				t.Errorf("Expected args %v, got %v", tt.args, exec.Args)
+			}
+		})
+	}
+}
+
+func TestValidateLoginMethod(t *testing.T) {
+	for _, method := range []string{LoginMethodAzureLogin, LoginMethodAzureCLI, LoginMethodWorkloadIdentity, LoginMethodSPN} {
+		if err := ValidateLoginMethod(method); err != nil {
+			t.Errorf("Expected %s to be accepted, got: %v", method, err)
+		}
+	}
+	if err := ValidateLoginMethod("devicecode"); err == nil || !strings.Contains(err.Error(), "workloadidentity") {
+		t.Errorf("Expected unsupported method error listing the methods, got: %v", err)
+	}
+}
+
 func TestGetKubeconfigPath_EnvVar(t *testing.T) {
 	// Set custom KUBECONFIG env var
 	customPath := "/custom/path/to/config"
@@ -406,3 +668,138 @@ func TestSaveKubeconfig_AtomicWrite(t *testing.T) {
 		t.Errorf("Expected kubeconfig file to exist: %v", err)
 	}
 }
+
+func TestBackupKubeconfig(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "config")
+
+	// Nothing to back up yet
+	backupPath, err := BackupKubeconfig(kubeconfigPath)
+	if err != nil || backupPath != "" {
+		t.Fatalf("Expected no backup for a missing kubeconfig, got %q (%v)", backupPath, err)
+	}
+
+	original := []byte("apiVersion: v1\nkind: Config\ncurrent-context: before\n")
+	if err := os.WriteFile(kubeconfigPath, original, 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+	if err := os.WriteFile(kubeconfigPath+".bak", []byte("stale"), 0644); err != nil {
+		t.Fatalf("Failed to write stale backup: %v", err)
+	}
+
+	backupPath, err = BackupKubeconfig(kubeconfigPath)
+	if err != nil {
+		t.Fatalf("BackupKubeconfig failed: %v", err)
+	}
+	if backupPath != kubeconfigPath+".bak" {
+		t.Errorf("Expected backup at %s.bak, got %s", kubeconfigPath, backupPath)
+	}
+
+	// Overwriting the kubeconfig leaves the prior contents in the backup
+	if err := SaveKubeconfig(kubeconfigPath, &Kubeconfig{APIVersion: "v1", Kind: "Config", CurrentContext: "after"}); err != nil {
+		t.Fatalf("Failed to save kubeconfig: %v", err)
+	}
+	backup, err := os.ReadFile(backupPath)
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if string(backup) != string(original) {
+		t.Errorf("Expected backup to hold the prior kubeconfig, got %q", backup)
+	}
+	if info, err := os.Stat(backupPath); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected backup mode 0600, got %o", info.Mode().Perm())
+	}
+}
+
+func TestAzureAuthProviderYAMLRoundTrip(t *testing.T) {
+	input := `name: azure-user
+user:
+  auth-provider:
+    name: azure
+    config:
+      apiserver-id: test-server-id
+      client-id: test-client-id
+      tenant-id: test-tenant
+      environment: AzurePublicCloud
+`
+
+	var user NamedUser
+	if err := yaml.Unmarshal([]byte(input), &user); err != nil {
+		t.Fatalf("Failed to unmarshal user: %v", err)
+	}
+
+	provider := user.User.AuthProvider
+	if provider == nil {
+		t.Fatal("Expected auth-provider to be parsed")
+	}
+	if provider.Name != "azure" {
+		t.Errorf("Expected auth-provider name azure, got %s", provider.Name)
+	}
+	expected := map[string]string{
+		AzureAuthProviderAPIServerID: "test-server-id",
+		AzureAuthProviderClientID:    "test-client-id",
+		AzureAuthProviderTenantID:    "test-tenant",
+		AzureAuthProviderEnvironment: "AzurePublicCloud",
+	}
+	for key, value := range expected {
+		if provider.Config[key] != value {
+			t.Errorf("Expected %s=%s, got %s", key, value, provider.Config[key])
+		}
+	}
+	if user.User.Exec != nil {
+		t.Error("Expected no exec config")
+	}
+
+	// Marshal back and verify the block survives unchanged
+	data, err := yaml.Marshal(&user)
+	if err != nil {
+		t.Fatalf("Failed to marshal user: %v", err)
+	}
+	yamlStr := string(data)
+	if !strings.Contains(yamlStr, "auth-provider:") {
+		t.Errorf("Expected YAML to contain auth-provider, got:\n%s", yamlStr)
+	}
+	if strings.Contains(yamlStr, "exec:") {
+		t.Errorf("Expected YAML not to contain exec, got:\n%s", yamlStr)
+	}
+
+	var roundTripped NamedUser
+	if err := yaml.Unmarshal(data, &roundTripped); err != nil {
+		t.Fatalf("Failed to unmarshal round-tripped user: %v", err)
+	}
+	if roundTripped.User.AuthProvider == nil || roundTripped.User.AuthProvider.Config[AzureAuthProviderAPIServerID] != "test-server-id" {
+		t.Errorf("Expected auth-provider to round-trip, got %+v", roundTripped.User.AuthProvider)
+	}
+}
+
+func TestSaveKubeconfig_PreservesAzureAuthProvider(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "config")
+
+	config, err := LoadKubeconfig(kubeconfigPath)
+	if err != nil {
+		t.Fatalf("Failed to load kubeconfig: %v", err)
+	}
+	config.Users = append(config.Users, NamedUser{
+		Name: "azure-user",
+		User: User{
+			AuthProvider: &AuthProviderConfig{
+				Name:   "azure",
+				Config: map[string]string{AzureAuthProviderTenantID: "test-tenant"},
+			},
+		},
+	})
+
+	if err := SaveKubeconfig(kubeconfigPath, config); err != nil {
+		t.Fatalf("Failed to save kubeconfig: %v", err)
+	}
+
+	loaded, err := LoadKubeconfig(kubeconfigPath)
+	if err != nil {
+		t.Fatalf("Failed to reload kubeconfig: %v", err)
+	}
+	if len(loaded.Users) != 1 || loaded.Users[0].User.AuthProvider == nil {
+		t.Fatalf("Expected azure auth-provider user to be preserved, got %+v", loaded.Users)
+	}
+	if loaded.Users[0].User.AuthProvider.Config[AzureAuthProviderTenantID] != "test-tenant" {
+		t.Errorf("Expected tenant-id to be preserved, got %v", loaded.Users[0].User.AuthProvider.Config)
+	}
+}
@@ -0,0 +1,116 @@
+package aks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// maxClusterListPages bounds nextLink paging so a misbehaving endpoint cannot loop forever
+const maxClusterListPages = 50
+
+// ClusterSummary describes a managed cluster in a subscription
+type ClusterSummary struct {
+	Name          string `json:"name"`
+	ResourceGroup string `json:"resourceGroup"`
+	Location      string `json:"location"`
+	PowerState    string `json:"powerState"`
+}
+
+// managedClusterListResponse is a page of the ARM managed clusters list
+type managedClusterListResponse struct {
+	Value []struct {
+		ID         string `json:"id"`
+		Name       string `json:"name"`
+		Location   string `json:"location"`
+		Properties struct {
+			PowerState struct {
+				Code string `json:"code"`
+			} `json:"powerState"`
+		} `json:"properties"`
+	} `json:"value"`
+	NextLink string `json:"nextLink"`
+}
+
+// ListClusters returns the managed clusters in the client's subscription, following
+// nextLink paging
+func (c *Client) ListClusters(ctx context.Context) ([]ClusterSummary, error) {
+	url := fmt.Sprintf(
+		"%s/subscriptions/%s/providers/Microsoft.ContainerService/managedClusters?api-version=%s",
+		c.managementURL,
+		c.subscriptionID,
+		AKSAPIVersion,
+	)
+
+	clusters := []ClusterSummary{}
+	for page := 0; url != ""; page++ {
+		if page >= maxClusterListPages {
+			return nil, fmt.Errorf("cluster list exceeded %d pages", maxClusterListPages)
+		}
+
+		response, err := c.fetchClusterListPage(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		for _, cluster := range response.Value {
+			clusters = append(clusters, ClusterSummary{
+				Name:          cluster.Name,
+				ResourceGroup: resourceGroupFromID(cluster.ID),
+				Location:      cluster.Location,
+				PowerState:    cluster.Properties.PowerState.Code,
+			})
+		}
+		if response.NextLink != "" {
+			if err := c.validateNextLink(response.NextLink); err != nil {
+				return nil, err
+			}
+		}
+		url = response.NextLink
+	}
+
+	return clusters, nil
+}
+
+// fetchClusterListPage requests a single page of the managed clusters list
+func (c *Client) fetchClusterListPage(ctx context.Context, url string) (*managedClusterListResponse, error) {
+	body, err := c.armRequest(ctx, "GET", url, "failed to list clusters")
+	if err != nil {
+		return nil, err
+	}
+
+	var response managedClusterListResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse cluster list: %w", err)
+	}
+	return &response, nil
+}
+
+// validateNextLink refuses a nextLink that leaves the management endpoint, as the
+// request would carry the ARM bearer token to another host
+func (c *Client) validateNextLink(nextLink string) error {
+	next, err := url.Parse(nextLink)
+	if err != nil {
+		return fmt.Errorf("invalid cluster list nextLink %q: %w", nextLink, err)
+	}
+	management, err := url.Parse(c.managementURL)
+	if err != nil {
+		return fmt.Errorf("invalid management URL %q: %w", c.managementURL, err)
+	}
+	if !strings.EqualFold(next.Scheme, management.Scheme) || !strings.EqualFold(next.Host, management.Host) {
+		return fmt.Errorf("refusing cluster list nextLink %q: expected a %s URL on %s", nextLink, management.Scheme, management.Host)
+	}
+	return nil
+}
+
+// resourceGroupFromID extracts the resource group name from an ARM resource ID
+func resourceGroupFromID(id string) string {
+	segments := strings.Split(id, "/")
+	for i := 0; i+1 < len(segments); i++ {
+		if strings.EqualFold(segments[i], "resourceGroups") {
+			return segments[i+1]
+		}
+	}
+	return ""
+}
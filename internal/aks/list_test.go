@@ -0,0 +1,90 @@
+package aks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestListClusters_FollowsNextLink(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer mock-access-token" {
+			t.Errorf("Expected Bearer mock-access-token, got %s", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page") == "2" {
+			_, _ = fmt.Fprintf(w, `{"value":[{"id":"/subscriptions/test-subscription/resourceGroups/dev-rg/providers/Microsoft.ContainerService/managedClusters/dev","name":"dev","location":"westeurope","properties":{"powerState":{"code":"Stopped"}}}]}`)
+			return
+		}
+		if r.URL.Path != "/subscriptions/test-subscription/providers/Microsoft.ContainerService/managedClusters" {
+			t.Errorf("Unexpected list path %s", r.URL.Path)
+		}
+		_, _ = fmt.Fprintf(w, `{"value":[{"id":"/subscriptions/test-subscription/resourceGroups/Prod-RG/providers/Microsoft.ContainerService/managedClusters/prod","name":"prod","location":"eastus","properties":{"powerState":{"code":"Running"}}}],"nextLink":"%s/next?page=2"}`, server.URL)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-subscription", "mock-access-token")
+	client.SetManagementURL(server.URL)
+
+	clusters, err := client.ListClusters(context.Background())
+	if err != nil {
+		t.Fatalf("ListClusters failed: %v", err)
+	}
+
+	expected := []ClusterSummary{
+		{Name: "prod", ResourceGroup: "Prod-RG", Location: "eastus", PowerState: "Running"},
+		{Name: "dev", ResourceGroup: "dev-rg", Location: "westeurope", PowerState: "Stopped"},
+	}
+	if len(clusters) != len(expected) {
+		t.Fatalf("Expected %d clusters across both pages, got %+v", len(expected), clusters)
+	}
+	for i := range expected {
+		if clusters[i] != expected[i] {
+			t.Errorf("Cluster %d: expected %+v, got %+v", i, expected[i], clusters[i])
+		}
+	}
+}
+
+func TestListClusters_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"error":{"code":"AuthorizationFailed"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-subscription", "mock-access-token")
+	client.SetManagementURL(server.URL)
+
+	if _, err := client.ListClusters(context.Background()); err == nil {
+		t.Fatal("Expected an error for a forbidden list")
+	}
+}
+
+func TestListClusters_RefusesForeignNextLink(t *testing.T) {
+	foreignRequests := 0
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		foreignRequests++
+	}))
+	defer foreign.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"value":[],"nextLink":"%s/steal?page=2"}`, foreign.URL)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-subscription", "mock-access-token")
+	client.SetManagementURL(server.URL)
+
+	_, err := client.ListClusters(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "refusing cluster list nextLink") {
+		t.Fatalf("Expected a nextLink on another host to be refused, got: %v", err)
+	}
+	if foreignRequests != 0 {
+		t.Errorf("Expected the bearer token never to be sent to another host, got %d requests", foreignRequests)
+	}
+}
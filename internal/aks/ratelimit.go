@@ -0,0 +1,50 @@
+package aks
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rateLimitHeaderPrefix prefixes the Azure Resource Manager headers reporting the
+// remaining request quota, e.g. x-ms-ratelimit-remaining-subscription-reads
+const rateLimitHeaderPrefix = "x-ms-ratelimit-remaining-"
+
+// RateLimits maps a quota name (the header suffix, e.g. "subscription-reads") to the
+// number of requests remaining
+type RateLimits map[string]int
+
+// parseRateLimits extracts the numeric x-ms-ratelimit-remaining-* headers of a response
+func parseRateLimits(header http.Header) RateLimits {
+	limits := RateLimits{}
+	for name, values := range header {
+		quota, ok := strings.CutPrefix(strings.ToLower(name), rateLimitHeaderPrefix)
+		if !ok || quota == "" || len(values) == 0 {
+			continue
+		}
+		remaining, err := strconv.Atoi(strings.TrimSpace(values[0]))
+		if err != nil {
+			// Resource-specific quotas use a "policy;count" format that is not surfaced
+			continue
+		}
+		limits[quota] = remaining
+	}
+	return limits
+}
+
+// String formats the limits as sorted "quota=remaining" pairs
+func (r RateLimits) String() string {
+	quotas := make([]string, 0, len(r))
+	for quota := range r {
+		quotas = append(quotas, quota)
+	}
+	sort.Strings(quotas)
+
+	pairs := make([]string, len(quotas))
+	for i, quota := range quotas {
+		pairs[i] = fmt.Sprintf("%s=%d", quota, r[quota])
+	}
+	return strings.Join(pairs, ", ")
+}
@@ -0,0 +1,68 @@
+package aks
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Ms-Ratelimit-Remaining-Subscription-Reads", "11999")
+	header.Set("x-ms-ratelimit-remaining-subscription-writes", " 1198 ")
+	header.Set("x-ms-ratelimit-remaining-resource", "Microsoft.ContainerService/ListCredentials;59")
+	header.Set("x-ms-request-id", "abc")
+
+	limits := parseRateLimits(header)
+	if len(limits) != 2 {
+		t.Fatalf("Expected 2 numeric quotas, got %v", limits)
+	}
+	if limits["subscription-reads"] != 11999 || limits["subscription-writes"] != 1198 {
+		t.Errorf("Unexpected limits: %v", limits)
+	}
+	if got := limits.String(); got != "subscription-reads=11999, subscription-writes=1198" {
+		t.Errorf("Unexpected String(): %q", got)
+	}
+}
+
+func TestGetClusterCredentials_RecordsRateLimits(t *testing.T) {
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: %s
+    server: https://test-cluster.hcp.eastus.azmk8s.io:443
+  name: test-cluster
+`, testCACertBase64(t))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "GET" {
+			w.Header().Set("x-ms-ratelimit-remaining-subscription-reads", "11999")
+			_, _ = w.Write([]byte(`{"name":"test-cluster","properties":{}}`))
+			return
+		}
+		w.Header().Set("x-ms-ratelimit-remaining-subscription-writes", "1199")
+		_, _ = fmt.Fprintf(w, `{"kubeconfigs":[{"name":"clusterUser","value":"%s"}]}`, base64.StdEncoding.EncodeToString([]byte(kubeconfig)))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-subscription", "mock-access-token")
+	client.SetManagementURL(server.URL)
+
+	credentials, err := client.GetClusterCredentials(context.Background(), "test-rg", "test-cluster")
+	if err != nil {
+		t.Fatalf("GetClusterCredentials failed: %v", err)
+	}
+
+	// The credentials call is the last ARM request, so its quotas are the current ones
+	if got := client.RateLimits()["subscription-writes"]; got != 1199 {
+		t.Errorf("Expected client to report 1199 remaining writes, got %v", client.RateLimits())
+	}
+	if got := credentials.RateLimits.String(); got != "subscription-writes=1199" {
+		t.Errorf("Expected credentials to carry the rate limits, got %q", got)
+	}
+}
@@ -15,6 +15,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cogna-public/azure-login/internal/cloud"
+	"github.com/cogna-public/azure-login/internal/httplog"
 	"github.com/cogna-public/azure-login/internal/retry"
 )
 
@@ -24,6 +26,9 @@ const (
 	// retries with exponential backoff.
 	// With 3 retries and default backoff (1s, 2s), total worst case: ~33 seconds
 	AzureTokenExchangeTimeout = 10 * time.Second
+
+	// ManagementScope is the default OAuth2 scope for Azure Resource Management
+	ManagementScope = "https://management.azure.com/.default"
 )
 
 // TokenResponse represents the response from Azure AD token endpoint
@@ -34,23 +39,38 @@ type TokenResponse struct {
 	ExtExpiresIn   int       `json:"ext_expires_in,omitempty"`
 	RefreshToken   string    `json:"refresh_token,omitempty"`
 	ExpiresOn      time.Time `json:"-"`
+	ExtExpiresOn   time.Time `json:"-"`
 	TenantID       string    `json:"-"`
 	ClientID       string    `json:"-"`
 	SubscriptionID string    `json:"-"`
+	Scope          string    `json:"-"`
+	Cloud          string    `json:"-"`
+	OIDCAudience   string    `json:"-"`
+
+	// AuthorityTenantID is the tenant whose token endpoint issued the token, when it
+	// differs from TenantID (login --authority-tenant)
+	AuthorityTenantID string `json:"-"`
+
+	// CertificatePath is the certificate file that signed the client assertion, when
+	// the token was acquired with a certificate instead of an OIDC token
+	CertificatePath string `json:"-"`
 }
 
 // Client handles Azure AD authentication
 type Client struct {
-	tenantID       string
-	clientID       string
-	subscriptionID string
-	scope          string
-	httpClient     *http.Client
+	tenantID          string
+	authorityTenantID string // tenant used for the token endpoint; defaults to tenantID
+	authorityHost     string // token endpoint host; defaults to the cloud's login endpoint
+	clientID          string
+	subscriptionID    string
+	scope             string
+	cloud             cloud.Cloud
+	httpClient        *http.Client
 }
 
 // NewClient creates a new authentication client with default scope for Azure Resource Management
 func NewClient(tenantID, clientID, subscriptionID string) *Client {
-	return NewClientWithScope(tenantID, clientID, subscriptionID, "https://management.azure.com/.default")
+	return NewClientWithScope(tenantID, clientID, subscriptionID, ManagementScope)
 }
 
 // NewClientWithScope creates a new authentication client with a custom OAuth2 scope
@@ -60,8 +80,10 @@ func NewClientWithScope(tenantID, clientID, subscriptionID, scope string) *Clien
 		clientID:       clientID,
 		subscriptionID: subscriptionID,
 		scope:          scope,
+		cloud:          cloud.AzurePublicCloud,
 		httpClient: &http.Client{
-			Timeout: AzureTokenExchangeTimeout,
+			Timeout:   AzureTokenExchangeTimeout,
+			Transport: httplog.Default(),
 			// Disable redirects for security (prevents redirect-based attacks)
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				return http.ErrUseLastResponse
@@ -70,9 +92,80 @@ func NewClientWithScope(tenantID, clientID, subscriptionID, scope string) *Clien
 	}
 }
 
+// SetAuthorityTenant sets the tenant used to build the token endpoint.
+// This allows a guest (multi-tenant) exchange while the home tenant is still
+// recorded on the returned token.
+func (c *Client) SetAuthorityTenant(tenantID string) {
+	c.authorityTenantID = tenantID
+}
+
+// SetCloud selects the Azure cloud whose login authority issues the token
+// (the public cloud by default)
+func (c *Client) SetCloud(azureCloud cloud.Cloud) {
+	c.cloud = azureCloud
+}
+
+// SetAuthorityHost overrides the host of the token endpoint (login.microsoftonline.com
+// for the public cloud), e.g. for a private sovereign cloud or a test server. A host
+// without a scheme is reached over HTTPS; an empty host restores the cloud's default.
+func (c *Client) SetAuthorityHost(host string) {
+	host = strings.TrimRight(host, "/")
+	if host != "" && !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	c.authorityHost = host
+}
+
+// tokenEndpoint returns the Azure AD token endpoint for the authority tenant
+func (c *Client) tokenEndpoint() string {
+	authority := c.authorityTenantID
+	if authority == "" {
+		authority = c.tenantID
+	}
+	host := c.authorityHost
+	if host == "" {
+		host = c.cloud.LoginEndpoint
+	}
+	return fmt.Sprintf("%s/%s/oauth2/v2.0/token", host, authority)
+}
+
+// aadstsHints maps Azure AD error codes (AADSTSnnnn) to actionable guidance.
+// %s is replaced with the client ID of the app registration.
+var aadstsHints = map[int]string{
+	7000215: "the client secret is invalid. Check that the secret value (not its ID) of app registration %s is configured, or create a new secret under Certificates & secrets",
+	700016:  "app registration %s was not found in the tenant. Check the tenant ID, and for another tenant than the app's home tenant that the app is multi-tenant and has been consented there",
+	700027:  "the client assertion signature is invalid. Check that the certificate is uploaded under Certificates & secrets for app registration %s and has not expired",
+	7000222: "the client secret has expired. Create a new secret under Certificates & secrets for app registration %s and update the stored secret",
+}
+
+// authenticationError builds the error for a failed token request. The error
+// description is never included since it may echo sensitive request data; known
+// AADSTS codes are mapped to guidance instead.
+func (c *Client) authenticationError(statusCode int, body []byte) error {
+	var errorResp struct {
+		Error      string `json:"error"`
+		ErrorCodes []int  `json:"error_codes"`
+	}
+	if err := json.Unmarshal(body, &errorResp); err != nil {
+		return fmt.Errorf("authentication failed with status %d (check credentials and network connectivity)", statusCode)
+	}
+
+	for _, code := range errorResp.ErrorCodes {
+		if hint, ok := aadstsHints[code]; ok {
+			return fmt.Errorf("authentication failed: %s (AADSTS%d): %s", errorResp.Error, code, fmt.Sprintf(hint, c.clientID))
+		}
+	}
+	return fmt.Errorf("authentication failed: %s (check credentials and federated identity configuration)", errorResp.Error)
+}
+
 // ExchangeOIDCToken exchanges a GitHub OIDC token for an Azure access token
 func (c *Client) ExchangeOIDCToken(ctx context.Context, oidcToken string) (*TokenResponse, error) {
-	tokenEndpoint := fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", c.tenantID)
+	// An expired assertion would otherwise fail with an opaque AADSTS700024
+	if err := CheckAssertionLifetime(oidcToken, time.Now()); err != nil {
+		return nil, err
+	}
+
+	tokenEndpoint := c.tokenEndpoint()
 
 	// Prepare form data for token exchange
 	data := url.Values{}
@@ -86,7 +179,7 @@ func (c *Client) ExchangeOIDCToken(ctx context.Context, oidcToken string) (*Toke
 	retryConfig := retry.LoadConfig()
 
 	var tokenResp *TokenResponse
-	err := retryConfig.Do(ctx, func() error {
+	err := retryConfig.DoWithContext(ctx, func(ctx context.Context) error {
 		// Create request
 		req, err := http.NewRequestWithContext(ctx, "POST", tokenEndpoint, strings.NewReader(data.Encode()))
 		if err != nil {
@@ -112,16 +205,11 @@ func (c *Client) ExchangeOIDCToken(ctx context.Context, oidcToken string) (*Toke
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			// Try to parse error response
-			var errorResp struct {
-				Error            string `json:"error"`
-				ErrorDescription string `json:"error_description"`
-			}
-			if err := json.Unmarshal(body, &errorResp); err == nil {
-				// Sanitize error description to avoid leaking sensitive data
-				return fmt.Errorf("authentication failed: %s (check credentials and federated identity configuration)", errorResp.Error)
+			return &retry.HTTPStatusError{
+				StatusCode: resp.StatusCode,
+				Err:        c.authenticationError(resp.StatusCode, body),
+				RetryAfter: retry.ParseRetryAfter(resp.Header.Get("Retry-After")),
 			}
-			return fmt.Errorf("authentication failed with status %d (check credentials and network connectivity)", resp.StatusCode)
 		}
 
 		// Parse successful response
@@ -131,10 +219,17 @@ func (c *Client) ExchangeOIDCToken(ctx context.Context, oidcToken string) (*Toke
 		}
 
 		// Calculate expiration time (use UTC to avoid timezone issues)
-		response.ExpiresOn = time.Now().UTC().Add(time.Duration(response.ExpiresIn) * time.Second)
+		now := time.Now().UTC()
+		response.ExpiresOn = now.Add(time.Duration(response.ExpiresIn) * time.Second)
+		// Extended validity allows the token to be used during AAD outages
+		if response.ExtExpiresIn > 0 {
+			response.ExtExpiresOn = now.Add(time.Duration(response.ExtExpiresIn) * time.Second)
+		}
 		response.TenantID = c.tenantID
 		response.ClientID = c.clientID
 		response.SubscriptionID = c.subscriptionID
+		response.Scope = c.scope
+		response.Cloud = c.cloud.Name
 
 		tokenResp = &response
 		return nil
@@ -146,3 +241,13 @@ func (c *Client) ExchangeOIDCToken(ctx context.Context, oidcToken string) (*Toke
 
 	return tokenResp, nil
 }
+
+// ExchangeCertificate authenticates with a certificate credential, sending a client
+// assertion signed with its private key in place of a federated OIDC token
+func (c *Client) ExchangeCertificate(ctx context.Context, credential *CertificateCredential) (*TokenResponse, error) {
+	assertion, err := credential.Assertion(c.clientID, c.tokenEndpoint())
+	if err != nil {
+		return nil, err
+	}
+	return c.ExchangeOIDCToken(ctx, assertion)
+}
@@ -2,12 +2,16 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/cogna-public/azure-login/internal/cloud"
+	"github.com/cogna-public/azure-login/internal/retry"
 )
 
 func TestExchangeOIDCToken_Success(t *testing.T) {
@@ -97,6 +101,9 @@ func TestExchangeOIDCToken_Success(t *testing.T) {
 func TestExchangeOIDCToken_InvalidCredentials(t *testing.T) {
 	// Create mock server that returns authentication error
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/test-tenant/oauth2/v2.0/token" {
+			t.Errorf("Expected token endpoint path, got %s", r.URL.Path)
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusUnauthorized)
 		_, _ = fmt.Fprintf(w, `{
@@ -107,17 +114,128 @@ func TestExchangeOIDCToken_InvalidCredentials(t *testing.T) {
 	defer server.Close()
 
 	client := NewClient("test-tenant", "test-client-id", "test-subscription")
+	client.SetAuthorityHost(server.URL)
+
+	_, err := client.ExchangeOIDCToken(context.Background(), "oidc-token")
+	if err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Errorf("Expected invalid_client error, got: %v", err)
+	}
+}
+
+func TestSetAuthorityHost(t *testing.T) {
+	client := NewClient("test-tenant", "test-client-id", "test-subscription")
+	client.SetCloud(cloud.AzureUSGovernment)
+
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"login.example.sovcloud/", "https://login.example.sovcloud/test-tenant/oauth2/v2.0/token"},
+		{"http://127.0.0.1:8080", "http://127.0.0.1:8080/test-tenant/oauth2/v2.0/token"},
+		{"", cloud.AzureUSGovernment.LoginEndpoint + "/test-tenant/oauth2/v2.0/token"},
+	}
+
+	for _, tt := range tests {
+		client.SetAuthorityHost(tt.host)
+		if got := client.tokenEndpoint(); got != tt.expected {
+			t.Errorf("SetAuthorityHost(%q): expected endpoint %s, got %s", tt.host, tt.expected, got)
+		}
+	}
+}
+
+func TestAuthenticationError_AADSTSHints(t *testing.T) {
+	client := NewClient("test-tenant", "test-client-id", "test-subscription")
+
+	tests := []struct {
+		name     string
+		body     string
+		contains []string
+	}{
+		{
+			name:     "Invalid client secret",
+			body:     `{"error":"invalid_client","error_description":"AADSTS7000215: Invalid client secret provided. secret-hint-abc","error_codes":[7000215]}`,
+			contains: []string{"AADSTS7000215", "secret is invalid", "test-client-id"},
+		},
+		{
+			name:     "Expired client secret",
+			body:     `{"error":"invalid_client","error_description":"AADSTS7000222: The provided client secret keys are expired. secret-hint-abc","error_codes":[7000222]}`,
+			contains: []string{"AADSTS7000222", "secret has expired", "test-client-id"},
+		},
+		{
+			name:     "App not in tenant",
+			body:     `{"error":"unauthorized_client","error_description":"AADSTS700016: Application not found in the directory","error_codes":[700016]}`,
+			contains: []string{"AADSTS700016", "not found in the tenant", "test-client-id"},
+		},
+		{
+			name:     "Unmapped code",
+			body:     `{"error":"invalid_client","error_description":"AADSTS70021: No matching federated identity record found","error_codes":[70021]}`,
+			contains: []string{"invalid_client", "federated identity configuration"},
+		},
+	}
 
-	// Since we can't easily override the token endpoint URL without modifying the production code,
-	// we'll test that the client is constructed correctly
-	if client.tenantID != "test-tenant" {
-		t.Errorf("Expected tenantID test-tenant, got %s", client.tenantID)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := client.authenticationError(http.StatusUnauthorized, []byte(tt.body))
+			for _, want := range tt.contains {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected error to contain %q, got: %v", want, err)
+				}
+			}
+			// The raw description (which may echo secret material) is never included
+			if strings.Contains(err.Error(), "secret-hint-abc") || strings.Contains(err.Error(), "error_description") {
+				t.Errorf("Expected error description to be omitted, got: %v", err)
+			}
+		})
 	}
-	if client.clientID != "test-client-id" {
-		t.Errorf("Expected clientID test-client-id, got %s", client.clientID)
+}
+
+func TestExchangeOIDCToken_RetriesThrottling(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Type", "application/json")
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = fmt.Fprintf(w, `{"error":"temporarily_unavailable"}`)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"access_token":"after-throttling","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-tenant", "test-client-id", "test-subscription")
+	client.SetCloud(cloud.Cloud{Name: "Test", LoginEndpoint: server.URL})
+
+	token, err := client.ExchangeOIDCToken(context.Background(), "oidc-token")
+	if err != nil {
+		t.Fatalf("Expected the 429 to be retried, got: %v", err)
 	}
-	if client.subscriptionID != "test-subscription" {
-		t.Errorf("Expected subscriptionID test-subscription, got %s", client.subscriptionID)
+	if attempts != 2 || token.AccessToken != "after-throttling" {
+		t.Errorf("Expected success on the second attempt, got %d attempts and token %q", attempts, token.AccessToken)
+	}
+}
+
+func TestExchangeOIDCToken_DoesNotRetryClientErrors(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(w, `{"error":"invalid_client"}`)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-tenant", "test-client-id", "test-subscription")
+	client.SetCloud(cloud.Cloud{Name: "Test", LoginEndpoint: server.URL})
+
+	_, err := client.ExchangeOIDCToken(context.Background(), "oidc-token")
+	var statusErr *retry.HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected an HTTPStatusError with status 400, got: %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected a single attempt for a 400, got %d", attempts)
 	}
 }
 
@@ -260,6 +378,72 @@ func TestNewClientWithScope(t *testing.T) {
 	}
 }
 
+func TestTokenEndpoint_AuthorityTenant(t *testing.T) {
+	tests := []struct {
+		name              string
+		tenantID          string
+		authorityTenantID string
+		expected          string
+	}{
+		{
+			name:     "Home tenant only",
+			tenantID: "home-tenant",
+			expected: "https://login.microsoftonline.com/home-tenant/oauth2/v2.0/token",
+		},
+		{
+			name:              "Same authority and home tenant",
+			tenantID:          "home-tenant",
+			authorityTenantID: "home-tenant",
+			expected:          "https://login.microsoftonline.com/home-tenant/oauth2/v2.0/token",
+		},
+		{
+			name:              "Guest authority tenant",
+			tenantID:          "home-tenant",
+			authorityTenantID: "guest-tenant",
+			expected:          "https://login.microsoftonline.com/guest-tenant/oauth2/v2.0/token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(tt.tenantID, "test-client-id", "test-subscription")
+			if tt.authorityTenantID != "" {
+				client.SetAuthorityTenant(tt.authorityTenantID)
+			}
+
+			if got := client.tokenEndpoint(); got != tt.expected {
+				t.Errorf("Expected endpoint %s, got %s", tt.expected, got)
+			}
+			// The home tenant must be preserved regardless of the authority
+			if client.tenantID != tt.tenantID {
+				t.Errorf("Expected tenantID %s, got %s", tt.tenantID, client.tenantID)
+			}
+		})
+	}
+}
+
+func TestTokenEndpoint_SovereignCloud(t *testing.T) {
+	tests := []struct {
+		cloud    cloud.Cloud
+		expected string
+	}{
+		{cloud.AzurePublicCloud, "https://login.microsoftonline.com/home-tenant/oauth2/v2.0/token"},
+		{cloud.AzureUSGovernment, "https://login.microsoftonline.us/home-tenant/oauth2/v2.0/token"},
+		{cloud.AzureChinaCloud, "https://login.chinacloudapi.cn/home-tenant/oauth2/v2.0/token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cloud.Name, func(t *testing.T) {
+			client := NewClient("home-tenant", "test-client-id", "test-subscription")
+			client.SetCloud(tt.cloud)
+
+			if got := client.tokenEndpoint(); got != tt.expected {
+				t.Errorf("Expected endpoint %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
 func TestTokenResponseFields(t *testing.T) {
 	// Test that TokenResponse structure is correct
 	now := time.Now()
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/cogna-public/azure-login/internal/retry"
+)
+
+const (
+	// AzureDevOpsCollectionURIEnvVar is set by the agent in every Azure Pipelines job
+	AzureDevOpsCollectionURIEnvVar = "SYSTEM_TEAMFOUNDATIONCOLLECTIONURI"
+
+	// AzureDevOpsOIDCRequestURIEnvVar is the endpoint that issues federation tokens
+	AzureDevOpsOIDCRequestURIEnvVar = "SYSTEM_OIDCREQUESTURI"
+
+	// AzureDevOpsAccessTokenEnvVar is the job access token; pipelines must map it
+	// explicitly (env: SYSTEM_ACCESSTOKEN: $(System.AccessToken))
+	AzureDevOpsAccessTokenEnvVar = "SYSTEM_ACCESSTOKEN"
+
+	// AzureDevOpsServiceConnectionEnvVar is the ID of the workload identity federation
+	// service connection, as exposed by the AzureCLI@2 task
+	AzureDevOpsServiceConnectionEnvVar = "AZURESUBSCRIPTION_SERVICE_CONNECTION_ID"
+
+	// azureDevOpsOIDCAPIVersion is the Azure DevOps REST API version of the OIDC request
+	azureDevOpsOIDCAPIVersion = "7.1"
+)
+
+// AzureDevOpsProvider requests a federation token for a service connection from the
+// Azure Pipelines OIDC endpoint
+type AzureDevOpsProvider struct{}
+
+// Name implements OIDCProvider
+func (AzureDevOpsProvider) Name() string {
+	return "Azure DevOps"
+}
+
+// GetToken implements OIDCProvider
+func (AzureDevOpsProvider) GetToken(ctx context.Context) (string, error) {
+	return GetAzureDevOpsOIDCToken(ctx)
+}
+
+// GetAzureDevOpsOIDCToken retrieves the federation token of the service connection
+// named by AZURESUBSCRIPTION_SERVICE_CONNECTION_ID in an Azure Pipelines job
+func GetAzureDevOpsOIDCToken(ctx context.Context) (string, error) {
+	requestURI := os.Getenv(AzureDevOpsOIDCRequestURIEnvVar)
+	if requestURI == "" {
+		return "", fmt.Errorf("%s environment variable not set. Use a recent Azure Pipelines agent; OIDC tokens are only issued to pipeline jobs", AzureDevOpsOIDCRequestURIEnvVar)
+	}
+	accessToken := os.Getenv(AzureDevOpsAccessTokenEnvVar)
+	if accessToken == "" {
+		return "", fmt.Errorf("%s environment variable not set. Map it in the step with 'env: SYSTEM_ACCESSTOKEN: $(System.AccessToken)'", AzureDevOpsAccessTokenEnvVar)
+	}
+	serviceConnectionID := os.Getenv(AzureDevOpsServiceConnectionEnvVar)
+	if serviceConnectionID == "" {
+		return "", fmt.Errorf("%s environment variable not set. Run azure-login in an AzureCLI@2 task with a workload identity federation service connection, or set it to the service connection ID", AzureDevOpsServiceConnectionEnvVar)
+	}
+
+	requestURL, err := url.Parse(requestURI)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %w", AzureDevOpsOIDCRequestURIEnvVar, err)
+	}
+	query := requestURL.Query()
+	query.Set("api-version", azureDevOpsOIDCAPIVersion)
+	query.Set("serviceConnectionId", serviceConnectionID)
+	requestURL.RawQuery = query.Encode()
+
+	retryConfig := retry.LoadConfig()
+
+	var token string
+	client := newOIDCHTTPClient()
+	err = retryConfig.DoWithContext(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, "POST", requestURL.String(), strings.NewReader("{}"))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to request OIDC token: %w", err)
+		}
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		// Limit response body to 1MB to prevent memory exhaustion
+		limitedBody := io.LimitReader(resp.Body, 1024*1024)
+
+		if resp.StatusCode != http.StatusOK {
+			return &retry.HTTPStatusError{
+				StatusCode: resp.StatusCode,
+				Err:        fmt.Errorf("failed to get OIDC token: status %d (check that the pipeline is authorized to use service connection %s)", resp.StatusCode, serviceConnectionID),
+				RetryAfter: retry.ParseRetryAfter(resp.Header.Get("Retry-After")),
+			}
+		}
+
+		var tokenResponse struct {
+			OIDCToken string `json:"oidcToken"`
+		}
+		if err := json.NewDecoder(limitedBody).Decode(&tokenResponse); err != nil {
+			return fmt.Errorf("failed to parse OIDC token response: %w", err)
+		}
+		if tokenResponse.OIDCToken == "" {
+			return fmt.Errorf("empty OIDC token received")
+		}
+
+		token = tokenResponse.OIDCToken
+		return nil
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("failed to get OIDC token: %w", err)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,290 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"time"
+)
+
+const (
+	// CertificatePathEnvVar is the PEM file with the service principal certificate and
+	// private key (same name as the Azure SDKs' EnvironmentCredential)
+	CertificatePathEnvVar = "AZURE_CLIENT_CERTIFICATE_PATH"
+
+	// CertificatePasswordEnvVar is the password of an encrypted private key
+	CertificatePasswordEnvVar = "AZURE_CLIENT_CERTIFICATE_PASSWORD"
+
+	// clientAssertionLifetime is the validity of a signed client assertion
+	clientAssertionLifetime = 10 * time.Minute
+)
+
+// CertificateCredential signs client assertions with a service principal certificate
+type CertificateCredential struct {
+	certificate *x509.Certificate
+	key         *rsa.PrivateKey
+}
+
+// LoadCertificateCredential reads a PEM file containing the certificate and its RSA
+// private key. Encrypted keys (PKCS#8 "ENCRYPTED PRIVATE KEY" with PBES2, or legacy
+// Proc-Type encrypted PEM) are decrypted with password.
+func LoadCertificateCredential(path, password string) (*CertificateCredential, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate file: %w", err)
+	}
+
+	credential := &CertificateCredential{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		switch block.Type {
+		case "CERTIFICATE":
+			// The first certificate is the leaf; any others are the chain
+			if credential.certificate == nil {
+				certificate, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse certificate in %s: %w", path, err)
+				}
+				credential.certificate = certificate
+			}
+		case "PRIVATE KEY", "RSA PRIVATE KEY", "ENCRYPTED PRIVATE KEY":
+			key, err := parsePrivateKeyBlock(block, password)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse private key in %s: %w", path, err)
+			}
+			credential.key = key
+		}
+	}
+
+	if credential.certificate == nil {
+		return nil, fmt.Errorf("no PEM-encoded certificate found in %s", path)
+	}
+	if credential.key == nil {
+		return nil, fmt.Errorf("no PEM-encoded private key found in %s", path)
+	}
+	if !credential.key.PublicKey.Equal(credential.certificate.PublicKey) {
+		return nil, fmt.Errorf("private key in %s does not match its certificate", path)
+	}
+	return credential, nil
+}
+
+// parsePrivateKeyBlock decodes an RSA private key, decrypting it if necessary
+func parsePrivateKeyBlock(block *pem.Block, password string) (*rsa.PrivateKey, error) {
+	der := block.Bytes
+	switch {
+	case block.Type == "ENCRYPTED PRIVATE KEY":
+		if password == "" {
+			return nil, fmt.Errorf("key is encrypted; set %s", CertificatePasswordEnvVar)
+		}
+		decrypted, err := decryptPKCS8(der, password)
+		if err != nil {
+			return nil, err
+		}
+		der = decrypted
+	//nolint:staticcheck // legacy encrypted PEM is still produced by older tooling
+	case x509.IsEncryptedPEMBlock(block):
+		if password == "" {
+			return nil, fmt.Errorf("key is encrypted; set %s", CertificatePasswordEnvVar)
+		}
+		//nolint:staticcheck // see above
+		decrypted, err := x509.DecryptPEMBlock(block, []byte(password))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt key (wrong password?): %w", err)
+		}
+		der = decrypted
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T: Azure AD certificate credentials require an RSA key", parsed)
+	}
+	return key, nil
+}
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// encryptedPrivateKeyInfo is the PKCS#8 EncryptedPrivateKeyInfo structure
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbes2Params are the PBES2 parameters (RFC 8018)
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params are the PBKDF2 parameters (RFC 8018); the PRF defaults to HMAC-SHA1
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// decryptPKCS8 decrypts a PBES2 (PBKDF2 + AES-CBC) encrypted PKCS#8 key, the format
+// written by current OpenSSL versions
+func decryptPKCS8(der []byte, password string) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("invalid encrypted private key: %w", err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported key encryption %s (only PBES2 is supported; re-export the key with openssl pkcs8 -topk8 -v2 aes256)", info.Algorithm.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("invalid PBES2 parameters: %w", err)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation %s (only PBKDF2 is supported)", params.KeyDerivationFunc.Algorithm)
+	}
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, fmt.Errorf("invalid PBKDF2 parameters: %w", err)
+	}
+
+	var prf func() hash.Hash
+	switch {
+	case len(kdf.PRF.Algorithm) == 0, kdf.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case kdf.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	default:
+		return nil, fmt.Errorf("unsupported PBKDF2 PRF %s", kdf.PRF.Algorithm)
+	}
+
+	var keyLength int
+	switch scheme := params.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		keyLength = 16
+	case scheme.Equal(oidAES192CBC):
+		keyLength = 24
+	case scheme.Equal(oidAES256CBC):
+		keyLength = 32
+	default:
+		return nil, fmt.Errorf("unsupported key cipher %s (only AES-CBC is supported)", scheme)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil || len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid AES-CBC parameters")
+	}
+
+	key, err := pbkdf2.Key(prf, password, kdf.Salt, kdf.IterationCount, keyLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(info.EncryptedData) == 0 || len(info.EncryptedData)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid encrypted private key length")
+	}
+
+	plaintext := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, info.EncryptedData)
+
+	// A wrong password shows up as invalid PKCS#7 padding
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, errors.New("failed to decrypt key (wrong password?)")
+	}
+	for _, b := range plaintext[len(plaintext)-padding:] {
+		if int(b) != padding {
+			return nil, errors.New("failed to decrypt key (wrong password?)")
+		}
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+// Assertion returns a signed JWT client assertion for clientID, valid for the token
+// endpoint audience. The x5t header carries the certificate's SHA-1 thumbprint, which
+// Azure AD uses to find the registered certificate.
+func (c *CertificateCredential) Assertion(clientID, audience string) (string, error) {
+	thumbprint := sha1.Sum(c.certificate.Raw)
+	header := map[string]any{
+		"alg": "RS256",
+		"typ": "JWT",
+		"x5t": base64.RawURLEncoding.EncodeToString(thumbprint[:]),
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("failed to generate assertion ID: %w", err)
+	}
+	now := time.Now().UTC()
+	claims := map[string]any{
+		"aud": audience,
+		"iss": clientID,
+		"sub": clientID,
+		"jti": hex.EncodeToString(jti),
+		"nbf": now.Unix(),
+		"iat": now.Unix(),
+		"exp": now.Add(clientAssertionLifetime).Unix(),
+	}
+
+	encodedHeader, err := encodeJWTSegment(header)
+	if err != nil {
+		return "", err
+	}
+	encodedClaims, err := encodeJWTSegment(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encodedHeader + "." + encodedClaims
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign client assertion: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// encodeJWTSegment encodes a JWT header or claims set
+func encodeJWTSegment(segment map[string]any) (string, error) {
+	data, err := json.Marshal(segment)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode client assertion: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/cloud"
+)
+
+// testCertificate generates a self-signed RSA certificate and its key
+func testCertificate(t *testing.T) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "azure-login-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return certificate, key
+}
+
+// writePEM writes the given blocks to a file in a temporary directory
+func writePEM(t *testing.T, blocks ...*pem.Block) string {
+	t.Helper()
+	var data []byte
+	for _, block := range blocks {
+		data = append(data, pem.EncodeToMemory(block)...)
+	}
+	path := filepath.Join(t.TempDir(), "sp.pem")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Failed to write PEM: %v", err)
+	}
+	return path
+}
+
+// encryptPKCS8 produces a PBES2 (PBKDF2-HMAC-SHA256, AES-256-CBC) encrypted PKCS#8
+// key, as written by `openssl pkcs8 -topk8 -v2 aes256`
+func encryptPKCS8(t *testing.T, key *rsa.PrivateKey, password string) []byte {
+	t.Helper()
+	plaintext, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	_, _ = rand.Read(salt)
+	_, _ = rand.Read(iv)
+
+	derived, err := pbkdf2.Key(sha256.New, password, salt, 2048, 32)
+	if err != nil {
+		t.Fatalf("Failed to derive key: %v", err)
+	}
+	block, _ := aes.NewCipher(derived)
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	for i := 0; i < padding; i++ {
+		plaintext = append(plaintext, byte(padding))
+	}
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+
+	mustMarshal := func(v any) []byte {
+		data, err := asn1.Marshal(v)
+		if err != nil {
+			t.Fatalf("Failed to marshal ASN.1: %v", err)
+		}
+		return data
+	}
+	kdf := pbkdf2Params{
+		Salt:           salt,
+		IterationCount: 2048,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	}
+	params := pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: mustMarshal(kdf)}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: mustMarshal(iv)}},
+	}
+	return mustMarshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: mustMarshal(params)}},
+		EncryptedData: ciphertext,
+	})
+}
+
+func TestLoadCertificateCredential(t *testing.T) {
+	certificate, key := testCertificate(t)
+	certBlock := &pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw}
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+	//nolint:staticcheck // legacy encrypted PEM is a supported input format
+	legacy, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key), []byte("secret"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatalf("Failed to encrypt legacy PEM: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		blocks   []*pem.Block
+		password string
+		wantErr  string
+	}{
+		{
+			name:   "PKCS#8 key",
+			blocks: []*pem.Block{certBlock, {Type: "PRIVATE KEY", Bytes: pkcs8}},
+		},
+		{
+			name:   "PKCS#1 key before certificate",
+			blocks: []*pem.Block{{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}, certBlock},
+		},
+		{
+			name:     "PBES2 encrypted key",
+			blocks:   []*pem.Block{certBlock, {Type: "ENCRYPTED PRIVATE KEY", Bytes: encryptPKCS8(t, key, "secret")}},
+			password: "secret",
+		},
+		{
+			name:     "PBES2 encrypted key with wrong password",
+			blocks:   []*pem.Block{certBlock, {Type: "ENCRYPTED PRIVATE KEY", Bytes: encryptPKCS8(t, key, "secret")}},
+			password: "wrong",
+			wantErr:  "wrong password",
+		},
+		{
+			name:    "Encrypted key without password",
+			blocks:  []*pem.Block{certBlock, {Type: "ENCRYPTED PRIVATE KEY", Bytes: encryptPKCS8(t, key, "secret")}},
+			wantErr: CertificatePasswordEnvVar,
+		},
+		{
+			name:     "Legacy encrypted PEM",
+			blocks:   []*pem.Block{certBlock, legacy},
+			password: "secret",
+		},
+		{
+			name:    "Missing key",
+			blocks:  []*pem.Block{certBlock},
+			wantErr: "no PEM-encoded private key",
+		},
+		{
+			name:    "Missing certificate",
+			blocks:  []*pem.Block{{Type: "PRIVATE KEY", Bytes: pkcs8}},
+			wantErr: "no PEM-encoded certificate",
+		},
+		{
+			name:    "Corrupt certificate",
+			blocks:  []*pem.Block{{Type: "CERTIFICATE", Bytes: []byte("garbage")}, {Type: "PRIVATE KEY", Bytes: pkcs8}},
+			wantErr: "failed to parse certificate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credential, err := LoadCertificateCredential(writePEM(t, tt.blocks...), tt.password)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if !credential.key.Equal(key) {
+				t.Error("Expected the loaded key to match the generated key")
+			}
+		})
+	}
+}
+
+func TestLoadCertificateCredential_MismatchedKey(t *testing.T) {
+	certificate, _ := testCertificate(t)
+	_, otherKey := testCertificate(t)
+
+	path := writePEM(t,
+		&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw},
+		&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(otherKey)},
+	)
+	_, err := LoadCertificateCredential(path, "")
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("Expected mismatch error, got: %v", err)
+	}
+}
+
+func TestCertificateCredential_Assertion(t *testing.T) {
+	certificate, key := testCertificate(t)
+	credential := &CertificateCredential{certificate: certificate, key: key}
+
+	assertion, err := credential.Assertion("test-client-id", "https://login.example/tenant/oauth2/v2.0/token")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	header, claims, err := DecodeJWT(assertion)
+	if err != nil {
+		t.Fatalf("Failed to decode assertion: %v", err)
+	}
+	thumbprint := sha1.Sum(certificate.Raw)
+	if header["alg"] != "RS256" || header["x5t"] != base64.RawURLEncoding.EncodeToString(thumbprint[:]) {
+		t.Errorf("Unexpected header: %v", header)
+	}
+	if claims["aud"] != "https://login.example/tenant/oauth2/v2.0/token" {
+		t.Errorf("Expected token endpoint audience, got %v", claims["aud"])
+	}
+	if claims["iss"] != "test-client-id" || claims["sub"] != "test-client-id" {
+		t.Errorf("Expected iss and sub to be the client ID, got %v / %v", claims["iss"], claims["sub"])
+	}
+	if claims["jti"] == "" || claims["exp"] == nil {
+		t.Errorf("Expected jti and exp claims, got %v", claims)
+	}
+
+	parts := strings.Split(assertion, ".")
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("Assertion signature does not verify: %v", err)
+	}
+}
+
+func TestExchangeCertificate(t *testing.T) {
+	certificate, key := testCertificate(t)
+	credential := &CertificateCredential{certificate: certificate, key: key}
+
+	var assertion, audience string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		assertion = r.PostForm.Get("client_assertion")
+		audience = "http://" + r.Host + r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "certificate-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient("test-tenant", "test-client-id", "test-subscription")
+	client.SetCloud(cloud.Cloud{Name: "Test", LoginEndpoint: server.URL})
+
+	token, err := client.ExchangeCertificate(context.Background(), credential)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if token.AccessToken != "certificate-token" {
+		t.Errorf("Expected certificate-token, got %s", token.AccessToken)
+	}
+
+	_, claims, err := DecodeJWT(assertion)
+	if err != nil {
+		t.Fatalf("Failed to decode client assertion: %v", err)
+	}
+	if claims["aud"] != audience {
+		t.Errorf("Expected assertion audience %s, got %v", audience, claims["aud"])
+	}
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// assertionClockSkew is the clock skew tolerated for an assertion's nbf claim, as by Azure AD
+const assertionClockSkew = 5 * time.Minute
+
+// DecodeJWT decodes the header and claims of a JWT without verifying its signature.
+// It is intended for diagnostics only; never use the result for authorization decisions.
+func DecodeJWT(token string) (header map[string]any, claims map[string]any, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, fmt.Errorf("invalid JWT: expected 3 parts, got %d", len(parts))
+	}
+
+	header, err = decodeJWTSegment(parts[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JWT header: %w", err)
+	}
+
+	claims, err = decodeJWTSegment(parts[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JWT claims: %w", err)
+	}
+
+	return header, claims, nil
+}
+
+// decodeJWTSegment decodes a base64url-encoded JSON segment of a JWT
+func decodeJWTSegment(segment string) (map[string]any, error) {
+	// JWTs use unpadded base64url, but tolerate padding
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode: %w", err)
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse: %w", err)
+	}
+
+	return result, nil
+}
+
+// CheckAssertionLifetime checks the exp and nbf claims of an OIDC assertion against now,
+// so an expired or not yet valid token fails with a clear message instead of an AADSTS
+// error. It is a sanity check only: the signature is not verified, and tokens that are
+// not JWTs or lack the claims are left for Azure AD to judge.
+func CheckAssertionLifetime(token string, now time.Time) error {
+	_, claims, err := DecodeJWT(token)
+	if err != nil {
+		return nil
+	}
+
+	if exp, ok := claims["exp"].(float64); ok {
+		expiresOn := time.Unix(int64(exp), 0).UTC()
+		if !now.Before(expiresOn) {
+			return fmt.Errorf("the provided OIDC assertion has expired (exp %s), fetch a fresh one; if it was just issued, check the runner clock", expiresOn.Format(time.RFC3339))
+		}
+	}
+	if nbf, ok := claims["nbf"].(float64); ok {
+		notBefore := time.Unix(int64(nbf), 0).UTC()
+		if now.Add(assertionClockSkew).Before(notBefore) {
+			return fmt.Errorf("the provided OIDC assertion is not valid until %s; check the runner clock", notBefore.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func makeTestJWT(header, claims string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(header)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".signature"
+}
+
+func TestDecodeJWT_Success(t *testing.T) {
+	token := makeTestJWT(`{"alg":"RS256","typ":"JWT"}`, `{"sub":"repo:org/repo:ref:refs/heads/main","iss":"https://token.actions.githubusercontent.com","aud":"api://AzureADTokenExchange"}`)
+
+	header, claims, err := DecodeJWT(token)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if header["alg"] != "RS256" {
+		t.Errorf("Expected alg RS256, got %v", header["alg"])
+	}
+	if claims["sub"] != "repo:org/repo:ref:refs/heads/main" {
+		t.Errorf("Expected sub claim, got %v", claims["sub"])
+	}
+	if claims["aud"] != "api://AzureADTokenExchange" {
+		t.Errorf("Expected aud claim, got %v", claims["aud"])
+	}
+}
+
+func TestDecodeJWT_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		error string
+	}{
+		{name: "Not a JWT", token: "not-a-jwt", error: "expected 3 parts"},
+		{name: "Invalid base64", token: "!!!.e30.sig", error: "invalid JWT header"},
+		{name: "Invalid JSON claims", token: makeTestJWT(`{}`, `not-json`), error: "invalid JWT claims"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := DecodeJWT(tt.token)
+			if err == nil {
+				t.Fatal("Expected error, got none")
+			}
+			if !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("Expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestCheckAssertionLifetime(t *testing.T) {
+	header := `{"alg":"RS256","typ":"JWT"}`
+	token := makeTestJWT(header, `{"iss":"https://token.actions.githubusercontent.com","nbf":1699999000,"exp":1700000000}`)
+
+	err := CheckAssertionLifetime(token, time.Unix(1700000600, 0))
+	if err == nil || !strings.Contains(err.Error(), "has expired (exp 2023-11-14T22:13:20Z)") || !strings.Contains(err.Error(), "runner clock") {
+		t.Errorf("Expected expired assertion error, got: %v", err)
+	}
+
+	if err := CheckAssertionLifetime(token, time.Unix(1699999500, 0)); err != nil {
+		t.Errorf("Expected valid assertion, got: %v", err)
+	}
+
+	// nbf tolerates Azure AD's clock skew, but not a clock far behind
+	if err := CheckAssertionLifetime(token, time.Unix(1699999000-60, 0)); err != nil {
+		t.Errorf("Expected small clock skew to be tolerated, got: %v", err)
+	}
+	err = CheckAssertionLifetime(token, time.Unix(1699999000-3600, 0))
+	if err == nil || !strings.Contains(err.Error(), "not valid until") {
+		t.Errorf("Expected not-yet-valid error, got: %v", err)
+	}
+
+	// Opaque tokens and tokens without the claims are passed through to Azure AD
+	if err := CheckAssertionLifetime("opaque-token", time.Now()); err != nil {
+		t.Errorf("Expected opaque token to be accepted, got: %v", err)
+	}
+	if err := CheckAssertionLifetime(makeTestJWT(header, `{"iss":"https://gitlab.com"}`), time.Now()); err != nil {
+		t.Errorf("Expected token without exp to be accepted, got: %v", err)
+	}
+}
+
+func TestExchangeOIDCToken_ExpiredAssertion(t *testing.T) {
+	client := NewClient("tenant", "client", "subscription")
+	_, err := client.ExchangeOIDCToken(context.Background(), makeTestJWT(`{"alg":"RS256"}`, `{"exp":1700000000}`))
+	if err == nil || !strings.Contains(err.Error(), "has expired") {
+		t.Errorf("Expected the exchange to fail before contacting Azure AD, got: %v", err)
+	}
+}
@@ -8,8 +8,11 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/cogna-public/azure-login/internal/httplog"
 	"github.com/cogna-public/azure-login/internal/retry"
 )
 
@@ -18,14 +21,90 @@ const (
 	// This is set relatively short to fail fast on transient issues, since
 	// the retry logic will handle retries with exponential backoff.
 	// With 3 retries and default backoff (1s, 2s), total worst case: ~18 seconds
+	// Override with AZURE_LOGIN_OIDC_TIMEOUT for slow token services.
 	OIDCRequestTimeout = 5 * time.Second
+
+	// OIDCTimeoutEnvVar sets the OIDC request timeout in seconds (1-120)
+	OIDCTimeoutEnvVar = "AZURE_LOGIN_OIDC_TIMEOUT"
+
+	// maxOIDCRequestTimeout caps AZURE_LOGIN_OIDC_TIMEOUT
+	maxOIDCRequestTimeout = 120 * time.Second
+
+	// OIDCTokenEnvFallback names an environment variable holding a comma-separated list
+	// of alternate variables to read the request token from (for customized runners)
+	OIDCTokenEnvFallback = "AZURE_LOGIN_OIDC_TOKEN_ENV"
+
+	// OIDCURLEnvFallback names an environment variable holding a comma-separated list
+	// of alternate variables to read the request URL from (for customized runners)
+	OIDCURLEnvFallback = "AZURE_LOGIN_OIDC_URL_ENV"
+
+	// DefaultOIDCAudience is the audience Azure AD federated credentials expect
+	DefaultOIDCAudience = "api://AzureADTokenExchange"
 )
 
+// LookupOIDCRequestEnv returns the GitHub Actions OIDC request token and URL. The
+// standard ACTIONS_ID_TOKEN_REQUEST_* variables are checked first, followed by any
+// alternate names listed in AZURE_LOGIN_OIDC_TOKEN_ENV and AZURE_LOGIN_OIDC_URL_ENV.
+func LookupOIDCRequestEnv() (requestToken, requestURL string) {
+	return lookupEnvWithFallback("ACTIONS_ID_TOKEN_REQUEST_TOKEN", OIDCTokenEnvFallback),
+		lookupEnvWithFallback("ACTIONS_ID_TOKEN_REQUEST_URL", OIDCURLEnvFallback)
+}
+
+// lookupEnvWithFallback returns the first non-empty value of name or of the variables
+// listed (comma-separated) in fallbackListVar
+func lookupEnvWithFallback(name, fallbackListVar string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	for _, alternate := range strings.Split(os.Getenv(fallbackListVar), ",") {
+		alternate = strings.TrimSpace(alternate)
+		if alternate == "" {
+			continue
+		}
+		if value := os.Getenv(alternate); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// oidcRequestTimeout returns the OIDC request timeout from AZURE_LOGIN_OIDC_TIMEOUT,
+// falling back to OIDCRequestTimeout for unset or out-of-range values
+func oidcRequestTimeout() time.Duration {
+	if timeoutStr := os.Getenv(OIDCTimeoutEnvVar); timeoutStr != "" {
+		if seconds, err := strconv.Atoi(timeoutStr); err == nil && seconds > 0 && time.Duration(seconds)*time.Second <= maxOIDCRequestTimeout {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return OIDCRequestTimeout
+}
+
+// newOIDCHTTPClient builds the client for OIDC token requests, with the configured
+// timeout and redirects disabled for security (prevents redirect-based attacks)
+func newOIDCHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout:   oidcRequestTimeout(),
+		Transport: httplog.Default(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
 // GetGitHubOIDCToken retrieves the OIDC token from GitHub Actions environment
 func GetGitHubOIDCToken(ctx context.Context) (string, error) {
+	return GetGitHubOIDCTokenForAudience(ctx, DefaultOIDCAudience)
+}
+
+// GetGitHubOIDCTokenForAudience retrieves a GitHub Actions OIDC token with the given
+// audience (aud claim), or DefaultOIDCAudience if audience is empty
+func GetGitHubOIDCTokenForAudience(ctx context.Context, audience string) (string, error) {
+	if audience == "" {
+		audience = DefaultOIDCAudience
+	}
+
 	// Get environment variables
-	requestToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
-	requestURL := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL")
+	requestToken, requestURL := LookupOIDCRequestEnv()
 
 	if requestToken == "" {
 		return "", fmt.Errorf("ACTIONS_ID_TOKEN_REQUEST_TOKEN environment variable not set. Are you running in GitHub Actions?")
@@ -42,22 +121,15 @@ func GetGitHubOIDCToken(ctx context.Context) (string, error) {
 
 	// Add audience query parameter
 	query := tokenURL.Query()
-	query.Set("audience", "api://AzureADTokenExchange")
+	query.Set("audience", audience)
 	tokenURL.RawQuery = query.Encode()
 
 	// Load retry configuration
 	retryConfig := retry.LoadConfig()
 
 	var token string
-	err = retryConfig.Do(ctx, func() error {
-		// Create HTTP client with timeout and disabled redirects for security
-		client := &http.Client{
-			Timeout: OIDCRequestTimeout,
-			// Disable redirects for security (prevents redirect-based attacks)
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
-			},
-		}
+	client := newOIDCHTTPClient()
+	err = retryConfig.DoWithContext(ctx, func(ctx context.Context) error {
 
 		// Create request with context for cancellation support
 		req, err := http.NewRequestWithContext(ctx, "GET", tokenURL.String(), nil)
@@ -82,7 +154,11 @@ func GetGitHubOIDCToken(ctx context.Context) (string, error) {
 		limitedBody := io.LimitReader(resp.Body, 1024*1024)
 
 		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("failed to get OIDC token: status %d (check ACTIONS_ID_TOKEN_REQUEST_TOKEN and workflow permissions)", resp.StatusCode)
+			return &retry.HTTPStatusError{
+				StatusCode: resp.StatusCode,
+				Err:        fmt.Errorf("failed to get OIDC token: status %d (check ACTIONS_ID_TOKEN_REQUEST_TOKEN and workflow permissions)", resp.StatusCode),
+				RetryAfter: retry.ParseRetryAfter(resp.Header.Get("Retry-After")),
+			}
 		}
 
 		// Parse response
@@ -239,3 +239,122 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestGetGitHubOIDCToken_AlternateEnvVars(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer proxied-request-token" {
+			t.Errorf("Expected Authorization header with proxied token, got %s", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, `{"value": "mock-oidc-token-alt"}`)
+	}))
+	defer server.Close()
+
+	// Only the alternate-named variables are set
+	_ = os.Unsetenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	_ = os.Unsetenv("ACTIONS_ID_TOKEN_REQUEST_URL")
+	t.Setenv(OIDCTokenEnvFallback, "MISSING_TOKEN_VAR, RUNNER_OIDC_TOKEN")
+	t.Setenv(OIDCURLEnvFallback, "RUNNER_OIDC_URL")
+	t.Setenv("RUNNER_OIDC_TOKEN", "proxied-request-token")
+	t.Setenv("RUNNER_OIDC_URL", server.URL)
+
+	token, err := GetGitHubOIDCToken(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if token != "mock-oidc-token-alt" {
+		t.Errorf("Expected token 'mock-oidc-token-alt', got '%s'", token)
+	}
+}
+
+func TestLookupOIDCRequestEnv_StandardTakesPrecedence(t *testing.T) {
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "standard-token")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", "https://standard.example.com")
+	t.Setenv(OIDCTokenEnvFallback, "RUNNER_OIDC_TOKEN")
+	t.Setenv(OIDCURLEnvFallback, "RUNNER_OIDC_URL")
+	t.Setenv("RUNNER_OIDC_TOKEN", "alternate-token")
+	t.Setenv("RUNNER_OIDC_URL", "https://alternate.example.com")
+
+	requestToken, requestURL := LookupOIDCRequestEnv()
+	if requestToken != "standard-token" {
+		t.Errorf("Expected standard token, got %s", requestToken)
+	}
+	if requestURL != "https://standard.example.com" {
+		t.Errorf("Expected standard URL, got %s", requestURL)
+	}
+}
+
+func TestGetGitHubOIDCToken_GHESRequestURL(t *testing.T) {
+	// GitHub Enterprise Server serves the token from its own host under a custom path
+	const requestPath = "/_services/pipelines/_apis/distributedtask/hubs/Actions/plans/plan-id/jobs/job-id/idtoken"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != requestPath {
+			t.Errorf("Expected path %s, got %s", requestPath, r.URL.Path)
+		}
+		if r.URL.Query().Get("api-version") != "2.0" {
+			t.Errorf("Expected existing api-version parameter to be kept, got %q", r.URL.Query().Get("api-version"))
+		}
+		if r.URL.Query().Get("audience") != "api://AzureADTokenExchange" {
+			t.Errorf("Expected audience parameter, got %q", r.URL.Query().Get("audience"))
+		}
+		_, _ = fmt.Fprintf(w, `{"value": "ghes-oidc-token"}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "test-request-token")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", server.URL+requestPath+"?api-version=2.0")
+
+	token, err := GetGitHubOIDCToken(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if token != "ghes-oidc-token" {
+		t.Errorf("Expected token 'ghes-oidc-token', got '%s'", token)
+	}
+}
+
+func TestOIDCRequestTimeout(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", OIDCRequestTimeout},
+		{"30", 30 * time.Second},
+		{"120", 120 * time.Second},
+		{"121", OIDCRequestTimeout},
+		{"0", OIDCRequestTimeout},
+		{"abc", OIDCRequestTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Setenv(OIDCTimeoutEnvVar, tt.value)
+		if got := newOIDCHTTPClient().Timeout; got != tt.expected {
+			t.Errorf("%s=%q: expected client timeout %v, got %v", OIDCTimeoutEnvVar, tt.value, tt.expected, got)
+		}
+	}
+}
+
+func TestGetOIDCTokenForAudience(t *testing.T) {
+	var audiences []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		audiences = append(audiences, r.URL.Query().Get("audience"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"value": "mock-oidc-token"}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("GITLAB_CI", "")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "test-request-token")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", server.URL)
+
+	for _, audience := range []string{"", "https://vault.example.com"} {
+		if _, err := GetOIDCTokenForAudience(context.Background(), audience); err != nil {
+			t.Fatalf("Expected no error for audience %q, got: %v", audience, err)
+		}
+	}
+
+	if len(audiences) != 2 || audiences[0] != DefaultOIDCAudience || audiences[1] != "https://vault.example.com" {
+		t.Errorf("Expected the default then the custom audience, got %v", audiences)
+	}
+}
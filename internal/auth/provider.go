@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// GitLabTokenEnvDefault is the variable GitLab CI exposes the ID token in when the
+	// job declares it under id_tokens (the name is chosen by the pipeline)
+	GitLabTokenEnvDefault = "GITLAB_OIDC_TOKEN"
+
+	// GitLabTokenEnvOverride names the variable to read the GitLab ID token from
+	// when the pipeline uses a name other than GITLAB_OIDC_TOKEN
+	GitLabTokenEnvOverride = "AZURE_LOGIN_GITLAB_TOKEN_ENV"
+)
+
+// OIDCProvider supplies the CI platform's OIDC token used as the client assertion
+type OIDCProvider interface {
+	// Name identifies the provider in messages (e.g. "GitHub Actions")
+	Name() string
+
+	// GetToken returns the OIDC token
+	GetToken(ctx context.Context) (string, error)
+}
+
+// GitHubProvider requests the token from the GitHub Actions OIDC endpoint
+type GitHubProvider struct {
+	// Audience is the requested aud claim (default: DefaultOIDCAudience)
+	Audience string
+}
+
+// Name implements OIDCProvider
+func (GitHubProvider) Name() string {
+	return "GitHub Actions"
+}
+
+// GetToken implements OIDCProvider
+func (p GitHubProvider) GetToken(ctx context.Context) (string, error) {
+	return GetGitHubOIDCTokenForAudience(ctx, p.Audience)
+}
+
+// GitLabProvider reads the ID token GitLab CI exposes in an environment variable
+type GitLabProvider struct {
+	// EnvVar is the variable holding the ID token
+	EnvVar string
+}
+
+// Name implements OIDCProvider
+func (GitLabProvider) Name() string {
+	return "GitLab CI"
+}
+
+// GetToken implements OIDCProvider
+func (p GitLabProvider) GetToken(ctx context.Context) (string, error) {
+	token := strings.TrimSpace(os.Getenv(p.EnvVar))
+	if token == "" {
+		return "", fmt.Errorf("%s environment variable not set. Declare it under id_tokens with aud: api://AzureADTokenExchange in .gitlab-ci.yml", p.EnvVar)
+	}
+	return token, nil
+}
+
+// gitLabTokenEnv returns the variable the GitLab ID token is read from
+func gitLabTokenEnv() string {
+	if name := strings.TrimSpace(os.Getenv(GitLabTokenEnvOverride)); name != "" {
+		return name
+	}
+	return GitLabTokenEnvDefault
+}
+
+// DetectOIDCProvider selects the OIDC provider from the environment. GitLab CI is
+// used when GITLAB_CI=true and Azure DevOps when SYSTEM_TEAMFOUNDATIONCOLLECTIONURI
+// is set; GitHub Actions is the default.
+func DetectOIDCProvider() OIDCProvider {
+	if os.Getenv("GITLAB_CI") == "true" {
+		return GitLabProvider{EnvVar: gitLabTokenEnv()}
+	}
+	if os.Getenv(AzureDevOpsCollectionURIEnvVar) != "" {
+		return AzureDevOpsProvider{}
+	}
+	return GitHubProvider{}
+}
+
+// GetOIDCToken returns an OIDC token from the provider detected in the environment
+func GetOIDCToken(ctx context.Context) (string, error) {
+	return DetectOIDCProvider().GetToken(ctx)
+}
+
+// GetOIDCTokenForAudience returns an OIDC token with the given audience from the
+// provider detected in the environment. GitLab ID tokens carry the aud declared in
+// .gitlab-ci.yml, so the audience only applies to GitHub Actions.
+func GetOIDCTokenForAudience(ctx context.Context, audience string) (string, error) {
+	provider := DetectOIDCProvider()
+	if github, ok := provider.(GitHubProvider); ok {
+		github.Audience = audience
+		provider = github
+	}
+	return provider.GetToken(ctx)
+}
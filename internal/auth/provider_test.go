@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDetectOIDCProvider(t *testing.T) {
+	t.Setenv("GITLAB_CI", "")
+	t.Setenv(AzureDevOpsCollectionURIEnvVar, "")
+	if _, ok := DetectOIDCProvider().(GitHubProvider); !ok {
+		t.Error("Expected GitHub Actions as the default provider")
+	}
+
+	t.Setenv("GITLAB_CI", "true")
+	provider, ok := DetectOIDCProvider().(GitLabProvider)
+	if !ok {
+		t.Fatal("Expected GitLab CI provider when GITLAB_CI=true")
+	}
+	if provider.EnvVar != GitLabTokenEnvDefault {
+		t.Errorf("Expected default env var %s, got %s", GitLabTokenEnvDefault, provider.EnvVar)
+	}
+
+	t.Setenv(GitLabTokenEnvOverride, "AZURE_ID_TOKEN")
+	provider, _ = DetectOIDCProvider().(GitLabProvider)
+	if provider.EnvVar != "AZURE_ID_TOKEN" {
+		t.Errorf("Expected overridden env var AZURE_ID_TOKEN, got %s", provider.EnvVar)
+	}
+
+	t.Setenv("GITLAB_CI", "")
+	t.Setenv(AzureDevOpsCollectionURIEnvVar, "https://dev.azure.com/contoso/")
+	if _, ok := DetectOIDCProvider().(AzureDevOpsProvider); !ok {
+		t.Error("Expected Azure DevOps provider when SYSTEM_TEAMFOUNDATIONCOLLECTIONURI is set")
+	}
+}
+
+func TestGetOIDCToken_GitLab(t *testing.T) {
+	t.Setenv("GITLAB_CI", "true")
+	t.Setenv(GitLabTokenEnvOverride, "")
+	t.Setenv(GitLabTokenEnvDefault, "gitlab-id-token\n")
+
+	token, err := GetOIDCToken(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if token != "gitlab-id-token" {
+		t.Errorf("Expected 'gitlab-id-token', got %q", token)
+	}
+}
+
+func TestGetOIDCToken_GitLabMissingToken(t *testing.T) {
+	t.Setenv("GITLAB_CI", "true")
+	t.Setenv(GitLabTokenEnvOverride, "")
+	t.Setenv(GitLabTokenEnvDefault, "")
+
+	_, err := GetOIDCToken(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "id_tokens") {
+		t.Errorf("Expected error explaining id_tokens, got: %v", err)
+	}
+}
+
+func TestGetOIDCToken_AzureDevOps(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Expected POST, got %s", r.Method)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer job-access-token" {
+			t.Errorf("Expected job access token, got %q", got)
+		}
+		if got := r.URL.Query().Get("serviceConnectionId"); got != "connection-id" {
+			t.Errorf("Expected serviceConnectionId=connection-id, got %q", got)
+		}
+		if got := r.URL.Query().Get("api-version"); got != azureDevOpsOIDCAPIVersion {
+			t.Errorf("Expected api-version=%s, got %q", azureDevOpsOIDCAPIVersion, got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"oidcToken":"ado-oidc-token"}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("GITLAB_CI", "")
+	t.Setenv(AzureDevOpsCollectionURIEnvVar, "https://dev.azure.com/contoso/")
+	t.Setenv(AzureDevOpsOIDCRequestURIEnvVar, server.URL+"/oidctoken")
+	t.Setenv(AzureDevOpsAccessTokenEnvVar, "job-access-token")
+	t.Setenv(AzureDevOpsServiceConnectionEnvVar, "connection-id")
+
+	token, err := GetOIDCToken(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if token != "ado-oidc-token" {
+		t.Errorf("Expected 'ado-oidc-token', got %q", token)
+	}
+}
+
+func TestGetOIDCToken_AzureDevOpsMissingEnv(t *testing.T) {
+	t.Setenv("GITLAB_CI", "")
+	t.Setenv(AzureDevOpsCollectionURIEnvVar, "https://dev.azure.com/contoso/")
+	t.Setenv(AzureDevOpsOIDCRequestURIEnvVar, "https://dev.azure.com/contoso/oidctoken")
+	t.Setenv(AzureDevOpsAccessTokenEnvVar, "")
+	t.Setenv(AzureDevOpsServiceConnectionEnvVar, "connection-id")
+
+	_, err := GetOIDCToken(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "$(System.AccessToken)") {
+		t.Errorf("Expected error explaining the System.AccessToken mapping, got: %v", err)
+	}
+
+	t.Setenv(AzureDevOpsAccessTokenEnvVar, "job-access-token")
+	t.Setenv(AzureDevOpsServiceConnectionEnvVar, "")
+	_, err = GetOIDCToken(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "service connection") {
+		t.Errorf("Expected error explaining the service connection, got: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/cogna-public/azure-login/internal/httplog"
+)
+
+// Subscription is an Azure subscription visible to the authenticated identity
+type Subscription struct {
+	SubscriptionID string `json:"subscriptionId"`
+	DisplayName    string `json:"displayName"`
+	State          string `json:"state"`
+	TenantID       string `json:"tenantId"`
+}
+
+// subscriptionListResponse is a page of the ARM subscriptions list
+type subscriptionListResponse struct {
+	Value    []Subscription `json:"value"`
+	NextLink string         `json:"nextLink"`
+}
+
+// maxSubscriptionPages bounds nextLink paging so a misbehaving endpoint cannot loop forever
+const maxSubscriptionPages = 50
+
+// ListSubscriptions returns the subscriptions the access token can see on the given
+// Azure Resource Manager endpoint, following nextLink paging
+func ListSubscriptions(ctx context.Context, managementURL, accessToken string) ([]Subscription, error) {
+	url := fmt.Sprintf("%s/subscriptions?api-version=%s", managementURL, validationAPIVersion)
+	client := &http.Client{Timeout: ValidationTimeout, Transport: httplog.Default()}
+
+	var subscriptions []Subscription
+	for page := 0; url != ""; page++ {
+		if page >= maxSubscriptionPages {
+			return nil, fmt.Errorf("subscriptions list exceeded %d pages", maxSubscriptionPages)
+		}
+
+		response, err := fetchSubscriptionPage(ctx, client, url, accessToken)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, response.Value...)
+		url = response.NextLink
+	}
+
+	return subscriptions, nil
+}
+
+// fetchSubscriptionPage requests a single page of the subscriptions list
+func fetchSubscriptionPage(ctx context.Context, client *http.Client, url, accessToken string) (*subscriptionListResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subscriptions request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	// Limit response body to 1MB to prevent memory exhaustion
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subscriptions response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, ErrTokenInvalid
+	default:
+		return nil, fmt.Errorf("failed to list subscriptions (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var response subscriptionListResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse subscriptions response: %w", err)
+	}
+	return &response, nil
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListSubscriptions_FollowsNextLink(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("Expected Bearer test-token, got %s", r.Header.Get("Authorization"))
+		}
+		if r.URL.Query().Get("page") == "2" {
+			_, _ = w.Write([]byte(`{"value":[{"subscriptionId":"sub-2","displayName":"Two","state":"Enabled","tenantId":"tenant"}]}`))
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"value":[{"subscriptionId":"sub-1","displayName":"One","state":"Enabled","tenantId":"tenant"}],"nextLink":"%s/subscriptions?page=2"}`, server.URL)
+	}))
+	defer server.Close()
+
+	subscriptions, err := ListSubscriptions(context.Background(), server.URL, "test-token")
+	if err != nil {
+		t.Fatalf("ListSubscriptions failed: %v", err)
+	}
+	if len(subscriptions) != 2 {
+		t.Fatalf("Expected 2 subscriptions, got %d", len(subscriptions))
+	}
+	if subscriptions[0].SubscriptionID != "sub-1" || subscriptions[1].DisplayName != "Two" {
+		t.Errorf("Unexpected subscriptions: %+v", subscriptions)
+	}
+}
+
+func TestListSubscriptions_Unauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	_, err := ListSubscriptions(context.Background(), server.URL, "revoked-token")
+	if !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("Expected ErrTokenInvalid, got: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/httplog"
+)
+
+const (
+	// validationAPIVersion is the ARM API version used for the validation call
+	validationAPIVersion = "2022-12-01"
+
+	// ValidationTimeout is the maximum time to wait for the validation call
+	ValidationTimeout = 10 * time.Second
+)
+
+// ErrTokenInvalid is returned when Azure rejects a cached token (e.g. revoked server-side)
+var ErrTokenInvalid = errors.New("token revoked or invalid, please re-authenticate with 'azure-login login'")
+
+// ValidateAccessToken confirms an Azure Resource Manager token is still accepted by making
+// a cheap authenticated call (GET /subscriptions/{id}, or /subscriptions without one)
+// against the given management endpoint. A 401 response yields ErrTokenInvalid; a 403
+// still proves the token is accepted.
+func ValidateAccessToken(ctx context.Context, managementURL, accessToken, subscriptionID string) error {
+	url := fmt.Sprintf("%s/subscriptions?api-version=%s", managementURL, validationAPIVersion)
+	if subscriptionID != "" {
+		url = fmt.Sprintf("%s/subscriptions/%s?api-version=%s", managementURL, subscriptionID, validationAPIVersion)
+	}
+	return validateAccessTokenAt(ctx, url, accessToken)
+}
+
+// validateAccessTokenAt performs the validation call against the given URL
+func validateAccessTokenAt(ctx context.Context, url, accessToken string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create validation request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	client := &http.Client{Timeout: ValidationTimeout, Transport: httplog.Default()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to validate token: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusForbidden:
+		// 403 means the token was accepted but lacks access to the subscription
+		return nil
+	case http.StatusUnauthorized:
+		return ErrTokenInvalid
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("token validation failed (status %d): %s", resp.StatusCode, string(body))
+	}
+}
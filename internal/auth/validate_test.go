@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidateAccessToken_Valid(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("Expected Bearer test-token, got %s", r.Header.Get("Authorization"))
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"subscriptionId":"test-subscription"}`))
+	}))
+	defer server.Close()
+
+	if err := validateAccessTokenAt(context.Background(), server.URL, "test-token"); err != nil {
+		t.Errorf("Expected valid token, got: %v", err)
+	}
+}
+
+func TestValidateAccessToken_Revoked(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":{"code":"InvalidAuthenticationToken"}}`))
+	}))
+	defer server.Close()
+
+	err := validateAccessTokenAt(context.Background(), server.URL, "revoked-token")
+	if !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("Expected ErrTokenInvalid, got: %v", err)
+	}
+}
+
+func TestValidateAccessToken_OtherError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	err := validateAccessTokenAt(context.Background(), server.URL, "test-token")
+	if err == nil {
+		t.Fatal("Expected error for 500, got none")
+	}
+	if errors.Is(err, ErrTokenInvalid) {
+		t.Error("Expected 500 not to be reported as a revoked token")
+	}
+}
+
+func TestValidateAccessToken_ForbiddenIsValid(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	if err := validateAccessTokenAt(context.Background(), server.URL, "test-token"); err != nil {
+		t.Errorf("Expected 403 to count as an accepted token, got: %v", err)
+	}
+}
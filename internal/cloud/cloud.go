@@ -0,0 +1,92 @@
+// Package cloud describes the Azure clouds (public and sovereign) azure-login can
+// authenticate against.
+//
+// Each cloud has its own Azure AD authority host and Azure Resource Manager endpoint;
+// the public cloud is the default.
+package cloud
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvironmentVariable selects the cloud when --cloud is not given
+const EnvironmentVariable = "AZURE_ENVIRONMENT"
+
+// Cloud holds the endpoints of an Azure cloud
+type Cloud struct {
+	// Name is the canonical cloud name (e.g. AzurePublicCloud)
+	Name string
+
+	// CLIName is the environment name reported by az (e.g. AzureCloud)
+	CLIName string
+
+	// LoginEndpoint is the Azure AD authority host
+	LoginEndpoint string
+
+	// ManagementEndpoint is the Azure Resource Manager endpoint
+	ManagementEndpoint string
+}
+
+// Supported clouds
+var (
+	AzurePublicCloud = Cloud{
+		Name:               "AzurePublicCloud",
+		CLIName:            "AzureCloud",
+		LoginEndpoint:      "https://login.microsoftonline.com",
+		ManagementEndpoint: "https://management.azure.com",
+	}
+
+	AzureUSGovernment = Cloud{
+		Name:               "AzureUSGovernment",
+		CLIName:            "AzureUSGovernment",
+		LoginEndpoint:      "https://login.microsoftonline.us",
+		ManagementEndpoint: "https://management.usgovcloudapi.net",
+	}
+
+	AzureChinaCloud = Cloud{
+		Name:               "AzureChinaCloud",
+		CLIName:            "AzureChinaCloud",
+		LoginEndpoint:      "https://login.chinacloudapi.cn",
+		ManagementEndpoint: "https://management.chinacloudapi.cn",
+	}
+)
+
+// aliases maps accepted (lowercased) names, including az and kubelogin spellings, to clouds
+var aliases = map[string]Cloud{
+	"azurepubliccloud":       AzurePublicCloud,
+	"azurecloud":             AzurePublicCloud,
+	"public":                 AzurePublicCloud,
+	"azureusgovernment":      AzureUSGovernment,
+	"azureusgovernmentcloud": AzureUSGovernment,
+	"usgovernment":           AzureUSGovernment,
+	"azurechinacloud":        AzureChinaCloud,
+	"china":                  AzureChinaCloud,
+}
+
+// Lookup returns the cloud with the given name (case-insensitive). An empty name
+// selects the public cloud.
+func Lookup(name string) (Cloud, error) {
+	if name == "" {
+		return AzurePublicCloud, nil
+	}
+	if c, ok := aliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return c, nil
+	}
+	return Cloud{}, fmt.Errorf("unknown cloud %q (supported: %s, %s, %s)", name, AzurePublicCloud.Name, AzureUSGovernment.Name, AzureChinaCloud.Name)
+}
+
+// Resolve returns the cloud named by the flag value, falling back to AZURE_ENVIRONMENT
+// and then the public cloud
+func Resolve(flagValue string) (Cloud, error) {
+	if flagValue != "" {
+		return Lookup(flagValue)
+	}
+	return Lookup(os.Getenv(EnvironmentVariable))
+}
+
+// ManagementScope returns the default OAuth2 scope for Azure Resource Manager in this cloud
+func (c Cloud) ManagementScope() string {
+	return c.ManagementEndpoint + "/.default"
+}
@@ -0,0 +1,66 @@
+package cloud
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Cloud
+		wantErr  bool
+	}{
+		{name: "", expected: AzurePublicCloud},
+		{name: "AzurePublicCloud", expected: AzurePublicCloud},
+		{name: "AzureCloud", expected: AzurePublicCloud},
+		{name: "azureusgovernment", expected: AzureUSGovernment},
+		{name: "AzureUSGovernmentCloud", expected: AzureUSGovernment},
+		{name: "AzureChinaCloud", expected: AzureChinaCloud},
+		{name: "AzureGermanCloud", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Lookup(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected.Name, got.Name)
+			}
+		})
+	}
+}
+
+func TestResolve_Precedence(t *testing.T) {
+	t.Setenv(EnvironmentVariable, "AzureChinaCloud")
+
+	fromEnv, err := Resolve("")
+	if err != nil || fromEnv != AzureChinaCloud {
+		t.Errorf("Expected AzureChinaCloud from env, got %s (%v)", fromEnv.Name, err)
+	}
+
+	fromFlag, err := Resolve("AzureUSGovernment")
+	if err != nil || fromFlag != AzureUSGovernment {
+		t.Errorf("Expected flag to take precedence, got %s (%v)", fromFlag.Name, err)
+	}
+}
+
+func TestResolve_TerraformEnvironmentNames(t *testing.T) {
+	t.Setenv(EnvironmentVariable, "usgovernment")
+
+	got, err := Resolve("")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if got.LoginEndpoint != "https://login.microsoftonline.us" || got.ManagementEndpoint != "https://management.usgovcloudapi.net" {
+		t.Errorf("Expected US Government endpoints, got %s and %s", got.LoginEndpoint, got.ManagementEndpoint)
+	}
+}
+
+func TestManagementScope(t *testing.T) {
+	if AzureUSGovernment.ManagementScope() != "https://management.usgovcloudapi.net/.default" {
+		t.Errorf("Unexpected scope: %s", AzureUSGovernment.ManagementScope())
+	}
+	if AzurePublicCloud.ManagementScope() != "https://management.azure.com/.default" {
+		t.Errorf("Unexpected scope: %s", AzurePublicCloud.ManagementScope())
+	}
+}
@@ -1,19 +1,79 @@
 package commands
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/cogna-public/azure-login/internal/auth"
+	"github.com/cogna-public/azure-login/internal/cloud"
 	"github.com/cogna-public/azure-login/internal/output"
 	"github.com/cogna-public/azure-login/pkg/config"
 	"github.com/spf13/cobra"
 )
 
+// tokenExpirationBuffer is how far ahead of expiry a cached token is treated as expired
+// (5 minute buffer for clock skew and API latency)
+const tokenExpirationBuffer = 5 * time.Minute
+
 var (
 	outputFormat string
 	queryString  string
+
+	// expiryThreshold is the minimum remaining validity required (--expiry-threshold)
+	expiryThreshold = tokenExpirationBuffer
+
+	// allowExtendedValidity serves tokens within ext_expires_in during AAD outages
+	allowExtendedValidity bool
+
+	// accessTokenScope and accessTokenResource request a token for another resource
+	// than the one cached at login (--scope, --resource)
+	accessTokenScope    string
+	accessTokenResource string
+
+	// accessTokenTenantID requests a token from another tenant than the saved one (--tenant)
+	accessTokenTenantID string
+
+	// accessTokenOutputFile receives the raw access token instead of stdout (--output-file)
+	accessTokenOutputFile string
+
+	// accessTokenAsSecret prints the token as a Kubernetes Secret manifest (--as-k8s-secret,
+	// --secret-name, --namespace, --secret-key)
+	accessTokenAsSecret bool
+	secretName          string
+	secretNamespace     string
+	secretKey           = defaultSecretKey
+
+	// noRefresh fails instead of re-exchanging a fresh OIDC token for an expiring one (--no-refresh)
+	noRefresh bool
+
+	// validateToken confirms the cached token is still accepted by Azure (--validate)
+	validateToken bool
+
+	// tokenDryRun reports how the token would be served without serving it (--dry-run)
+	tokenDryRun bool
+
+	// includeFingerprint adds a non-reversible tokenFingerprint field (--fingerprint)
+	includeFingerprint bool
+
+	// clearAll and clearDryRun select what account clear deletes (--all, --dry-run)
+	clearAll    bool
+	clearDryRun bool
+
+	// refreshSubscriptions bypasses the cached subscriptions list (--refresh/--no-cache)
+	refreshSubscriptions bool
+
+	// subscriptionsCacheTTL is how long a cached subscriptions list is reused (--cache-ttl)
+	subscriptionsCacheTTL = config.DefaultSubscriptionsCacheTTL
 )
 
+// tokenFingerprintLength is the number of hex characters of the SHA-256 digest shown
+const tokenFingerprintLength = 16
+
 var accountCmd = &cobra.Command{
 	Use:   "account",
 	Short: "Manage Azure account and authentication",
@@ -26,23 +86,122 @@ var accountShowCmd = &cobra.Command{
 	RunE:  runAccountShow,
 }
 
+var accountListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the subscriptions available to the authenticated identity",
+	Long: `List the Azure subscriptions the cached token can access.
+The list is cached in the config directory for a short time so repeated
+commands in a pipeline do not re-query Azure Resource Manager.`,
+	RunE: runAccountList,
+}
+
+var accountWhoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the identity claims of the cached access token",
+	Long: `Decode the cached access token and show the claims Azure AD issued it with:
+oid (object ID of the service principal), appid (client ID), tid (tenant), aud,
+iss and exp (with expiresOn as a timestamp). Useful when debugging federation
+failures. The token is decoded locally and its signature is not verified; the raw
+token is never printed.`,
+	RunE: runAccountWhoami,
+}
+
+var accountListProfilesCmd = &cobra.Command{
+	Use:   "list-profiles",
+	Short: "List the token profiles cached in the config directory",
+	Long: `List the named token profiles (selected with --profile or AZURE_LOGIN_PROFILE)
+that have a cached token in the config directory, with the identity and expiry
+of each. The "default" profile is the token cached without --profile.`,
+	RunE: runAccountListProfiles,
+}
+
+var accountClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete expired cached tokens",
+	Long: `Delete the token files of the active profile (--profile or AZURE_LOGIN_PROFILE)
+whose token has expired, for every scope, and report how many were removed.
+Long-lived self-hosted runners can run it to avoid keeping stale credentials on disk.
+
+--all deletes every cached token file of every profile, including valid and
+unreadable ones.
+--dry-run lists the files that would be deleted without deleting them.`,
+	RunE: runAccountClear,
+}
+
+var accountDumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Print the cached token exactly as stored (debug)",
+	Long: `Print the complete cached token record of the selected profile as it is stored
+in the config directory, for debugging token cache serialization and migration
+issues. It contains the access token, so --show-secrets is required.`,
+	RunE: runAccountDump,
+}
+
 var accountGetAccessTokenCmd = &cobra.Command{
 	Use:   "get-access-token",
 	Short: "Get an access token for Azure resource access",
 	Long: `Get an Azure access token that can be used to authenticate to Azure resources.
-The token is automatically refreshed if it has expired.`,
+The token is automatically refreshed with a fresh GitHub OIDC token if it has
+expired or expires within --expiry-threshold; use --no-refresh to fail instead.`,
 	RunE: runGetAccessToken,
 }
 
 func init() {
 	accountCmd.AddCommand(accountShowCmd)
 	accountCmd.AddCommand(accountGetAccessTokenCmd)
+	accountCmd.AddCommand(accountListCmd)
+	accountCmd.AddCommand(accountWhoamiCmd)
+	accountCmd.AddCommand(accountListProfilesCmd)
+	accountCmd.AddCommand(accountClearCmd)
+	accountCmd.AddCommand(accountDumpCmd)
 
 	// Add flags for output formatting
-	accountShowCmd.Flags().StringVarP(&outputFormat, "output", "o", "json", "Output format: json, tsv, table")
+	accountShowCmd.Flags().StringVarP(&outputFormat, "output", "o", "json", "Output format: json, ndjson, yaml, tsv, table, detail, value, exitcode")
+	accountShowCmd.Flags().StringVar(&queryString, "query", "", "JMESPath query string")
+	accountShowCmd.Flags().BoolVar(&showSecrets, "show-secrets", false, "Show sensitive fields instead of redacting them")
+	accountShowCmd.Flags().BoolVar(&includeFingerprint, "fingerprint", false, "Include a SHA-256 fingerprint of the access token as tokenFingerprint")
+
+	accountWhoamiCmd.Flags().StringVarP(&outputFormat, "output", "o", "json", "Output format: json, ndjson, yaml, tsv, table, detail, value, exitcode")
+	accountWhoamiCmd.Flags().StringVar(&queryString, "query", "", "JMESPath query string")
+
+	accountDumpCmd.Flags().StringVarP(&outputFormat, "output", "o", "json", "Output format: json, ndjson, yaml, tsv, table, detail, value, exitcode")
+	accountDumpCmd.Flags().StringVar(&queryString, "query", "", "JMESPath query string")
+	accountDumpCmd.Flags().BoolVar(&showSecrets, "show-secrets", false, "Required: acknowledge that the output contains the access token")
+
+	accountClearCmd.Flags().BoolVar(&clearAll, "all", false, "Delete all cached token files of every profile, not only the expired ones of the active profile")
+	accountClearCmd.Flags().BoolVar(&clearDryRun, "dry-run", false, "List the token files that would be deleted without deleting them")
 
-	accountGetAccessTokenCmd.Flags().StringVarP(&outputFormat, "output", "o", "json", "Output format: json, tsv, table")
+	accountListProfilesCmd.Flags().StringVarP(&outputFormat, "output", "o", "json", "Output format: json, ndjson, yaml, tsv, table, detail, value, exitcode")
+	accountListProfilesCmd.Flags().StringVar(&queryString, "query", "", "JMESPath query string")
+
+	accountListCmd.Flags().StringVarP(&outputFormat, "output", "o", "json", "Output format: json, ndjson, yaml, tsv, table, detail, value, exitcode")
+	accountListCmd.Flags().StringVar(&queryString, "query", "", "JMESPath query string")
+	accountListCmd.Flags().BoolVar(&refreshSubscriptions, "refresh", false, "Query Azure instead of using the cached subscriptions list")
+	accountListCmd.Flags().BoolVar(&refreshSubscriptions, "no-cache", false, "Alias for --refresh")
+	accountListCmd.Flags().DurationVar(&subscriptionsCacheTTL, "cache-ttl", config.DefaultSubscriptionsCacheTTL, "How long a cached subscriptions list is reused")
+
+	accountGetAccessTokenCmd.Flags().StringVarP(&outputFormat, "output", "o", "json", "Output format: json, ndjson, yaml, tsv, table, detail, value, exitcode")
 	accountGetAccessTokenCmd.Flags().StringVar(&queryString, "query", "", "JMESPath query string")
+	accountGetAccessTokenCmd.Flags().BoolVar(&strictQuery, "strict-query", false, "Fail if the query returns no result")
+	accountGetAccessTokenCmd.Flags().DurationVar(&expiryThreshold, "expiry-threshold", tokenExpirationBuffer, "Minimum remaining token validity (e.g. 20m)")
+	accountGetAccessTokenCmd.Flags().StringVar(&accessTokenScope, "scope", "", "OAuth2 scope to get a token for, e.g. https://vault.azure.net/.default (default: the scope used at login)")
+	accountGetAccessTokenCmd.Flags().StringVar(&accessTokenOutputFile, "output-file", "", "Write the raw access token to this file (0600) instead of printing it; stdout shows the details with the token redacted")
+	accountGetAccessTokenCmd.Flags().StringVar(&accessTokenResource, "resource", "", "Resource to get a token for; shorthand for --scope <resource>/.default")
+	accountGetAccessTokenCmd.MarkFlagsMutuallyExclusive("scope", "resource")
+	accountGetAccessTokenCmd.Flags().StringVar(&accessTokenTenantID, "tenant", "", "Tenant ID to get a token from when it differs from the login tenant; exchanged fresh with the saved client ID and not cached")
+	accountGetAccessTokenCmd.Flags().BoolVar(&noRefresh, "no-refresh", false, "Fail instead of refreshing an expired or expiring token")
+	accountGetAccessTokenCmd.Flags().BoolVar(&allowExtendedValidity, "allow-extended-validity", false, "Refresh an expired token, falling back to its extended validity if Azure AD is unreachable")
+	accountGetAccessTokenCmd.Flags().BoolVar(&validateToken, "validate", false, "Confirm the token has not been revoked with an authenticated Azure call")
+	accountGetAccessTokenCmd.Flags().BoolVar(&includeFingerprint, "fingerprint", false, "Include a SHA-256 fingerprint of the access token as tokenFingerprint")
+	accountGetAccessTokenCmd.Flags().BoolVar(&tokenDryRun, "dry-run", false, "Report whether the cached token would be used, refreshed or rejected (action: cache, refresh, fail) without network calls")
+	accountGetAccessTokenCmd.MarkFlagsMutuallyExclusive("tenant", "dry-run")
+	accountGetAccessTokenCmd.Flags().BoolVar(&accessTokenAsSecret, "as-k8s-secret", false, "Print the token as a Kubernetes Secret manifest (YAML unless -o is given) for GitOps and CD pipelines")
+	accountGetAccessTokenCmd.Flags().StringVar(&secretName, "secret-name", "", "Name of the Secret printed by --as-k8s-secret")
+	accountGetAccessTokenCmd.Flags().StringVar(&secretNamespace, "namespace", "", "Namespace of the Secret printed by --as-k8s-secret (default: none, i.e. the namespace it is applied to)")
+	accountGetAccessTokenCmd.Flags().StringVar(&secretKey, "secret-key", defaultSecretKey, "Data key holding the base64-encoded token in the Secret")
+	accountGetAccessTokenCmd.MarkFlagsMutuallyExclusive("as-k8s-secret", "output-file")
+	accountGetAccessTokenCmd.MarkFlagsMutuallyExclusive("as-k8s-secret", "dry-run")
+	accountGetAccessTokenCmd.Flags().BoolVar(&githubActionsMode, "github-actions", false, "Mask the token and write access_token/expires_on to $GITHUB_OUTPUT")
 }
 
 func runAccountShow(cmd *cobra.Command, args []string) error {
@@ -52,32 +211,318 @@ func runAccountShow(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("not authenticated. Run 'azure-login login' first")
 	}
 
+	// "id"/"tenantId" match az; "subscriptionId"/"homeTenantId" are explicit aliases for queries
 	accountInfo := map[string]any{
-		"environmentName": "AzureCloud",
+		"environmentName": tokenCloud(token).CLIName,
 		"id":              token.SubscriptionID,
+		"subscriptionId":  token.SubscriptionID,
 		"name":            "Azure Subscription",
-		"tenantId":        token.TenantID,
+		"tenantId":        accessTokenTenant(token),
+		"homeTenantId":    token.TenantID,
 		"user": map[string]string{
 			"name": token.ClientID,
 			"type": "servicePrincipal",
 		},
 	}
+	// Expiry lets scripts decide whether to re-login without get-access-token
+	now := time.Now().UTC()
+	accountInfo["expiresOn"] = token.ExpiresOn.UTC().Format(time.RFC3339)
+	accountInfo["expiresIn"] = max(int64(token.ExpiresOn.Sub(now).Seconds()), 0)
+	accountInfo["expired"] = !now.Before(token.ExpiresOn)
+	if includeFingerprint {
+		accountInfo["tokenFingerprint"] = tokenFingerprint(token.AccessToken)
+	}
+
+	return output.PrintWithOptions(accountInfo, outputFormat, queryString, informationalOutputOptions())
+}
+
+// whoamiClaims are the access token claims shown by account whoami
+var whoamiClaims = []string{"oid", "appid", "tid", "aud", "iss", "exp"}
+
+func runAccountWhoami(cmd *cobra.Command, args []string) error {
+	cfg := config.NewConfig()
+	token, err := cfg.LoadToken()
+	if err != nil {
+		return fmt.Errorf("not authenticated. Run 'azure-login login' first")
+	}
+
+	info, err := tokenIdentity(token.AccessToken)
+	if err != nil {
+		return err
+	}
+	return output.PrintWithOptions(info, outputFormat, queryString, informationalOutputOptions())
+}
+
+// tokenIdentity returns the whoami claims of an access token. Missing claims are null.
+func tokenIdentity(accessToken string) (map[string]any, error) {
+	_, claims, err := auth.DecodeJWT(accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("cached access token is not a well-formed JWT (re-authenticate with 'azure-login login'): %w", err)
+	}
+
+	info := make(map[string]any, len(whoamiClaims)+1)
+	for _, name := range whoamiClaims {
+		info[name] = claims[name]
+	}
+	// v2.0 tokens carry the client ID as azp instead of appid
+	if info["appid"] == nil {
+		info["appid"] = claims["azp"]
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		info["expiresOn"] = time.Unix(int64(exp), 0).UTC().Format(time.RFC3339)
+	}
+	return info, nil
+}
+
+func runAccountListProfiles(cmd *cobra.Command, args []string) error {
+	profiles, err := config.NewConfig().ListProfiles()
+	if err != nil {
+		return err
+	}
+
+	active := config.ActiveProfile()
+	result := make([]any, 0, len(profiles))
+	for _, profile := range profiles {
+		entry := map[string]any{
+			"name":   profile.Name,
+			"active": profile.Name == active,
+			"path":   profile.Path,
+		}
+		// An unreadable token file is still listed so it can be found and removed
+		if token := profile.Token; token != nil {
+			entry["tenantId"] = token.TenantID
+			entry["clientId"] = token.ClientID
+			entry["subscriptionId"] = token.SubscriptionID
+			entry["expiresOn"] = token.ExpiresOn.UTC().Format(time.RFC3339)
+		}
+		result = append(result, entry)
+	}
+
+	return output.PrintWithOptions(result, outputFormat, queryString, informationalOutputOptions())
+}
+
+func runAccountDump(cmd *cobra.Command, args []string) error {
+	if !showSecrets {
+		return fmt.Errorf("account dump prints the access token; pass --show-secrets to confirm")
+	}
+
+	token, err := config.NewConfig().LoadToken()
+	if err != nil {
+		return err
+	}
+	return output.PrintWithOptions(token, outputFormat, queryString, outputOptions())
+}
+
+func runAccountClear(cmd *cobra.Command, args []string) error {
+	cfg := config.NewConfig()
+
+	// Only the active profile is pruned unless --all is given
+	var files []config.TokenFile
+	var err error
+	if clearAll {
+		files, err = cfg.TokenFiles()
+	} else {
+		files, err = cfg.ProfileTokenFiles()
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var stale []config.TokenFile
+	for _, file := range files {
+		// Unreadable files are only removed with --all, as their expiry is unknown
+		expired := file.Token != nil && !now.Before(file.Token.ExpiresOn)
+		if clearAll || expired {
+			stale = append(stale, file)
+		}
+	}
+	return removeTokenFiles(cfg, stale, clearDryRun)
+}
+
+// removeTokenFiles deletes token cache files, reporting each on stderr, or only
+// lists them when dryRun is set
+func removeTokenFiles(cfg *config.Config, files []config.TokenFile, dryRun bool) error {
+	for _, file := range files {
+		if dryRun {
+			_, _ = fmt.Fprintf(os.Stderr, "Would remove %s\n", file.Path)
+			continue
+		}
+		if err := cfg.RemoveTokenFile(file); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Removed %s\n", file.Path)
+	}
+
+	if dryRun {
+		_, _ = fmt.Fprintf(os.Stderr, "Would remove %d token file(s)\n", len(files))
+	} else {
+		_, _ = fmt.Fprintf(os.Stderr, "Removed %d token file(s)\n", len(files))
+	}
+	return nil
+}
+
+// listSubscriptions queries Azure for the visible subscriptions. It is a variable so
+// tests can count network calls.
+var listSubscriptions = auth.ListSubscriptions
+
+func runAccountList(cmd *cobra.Command, args []string) error {
+	cfg := config.NewConfig()
+	token, err := cfg.LoadToken()
+	if err != nil {
+		return fmt.Errorf("not authenticated. Run 'azure-login login' first")
+	}
+
+	subscriptions, err := availableSubscriptions(commandContext(cmd), cfg, token)
+	if err != nil {
+		return err
+	}
+
+	// Match the shape of az account list
+	azureCloud := tokenCloud(token)
+	accounts := make([]any, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		accounts = append(accounts, map[string]any{
+			"cloudName": azureCloud.CLIName,
+			"id":        subscription.SubscriptionID,
+			"isDefault": subscription.SubscriptionID == token.SubscriptionID,
+			"name":      subscription.DisplayName,
+			"state":     subscription.State,
+			"tenantId":  subscription.TenantID,
+			"user": map[string]string{
+				"name": token.ClientID,
+				"type": "servicePrincipal",
+			},
+		})
+	}
 
-	return output.Print(accountInfo, outputFormat, queryString)
+	return output.PrintWithOptions(accounts, outputFormat, queryString, informationalOutputOptions())
+}
+
+// availableSubscriptions returns the subscriptions visible to the token, served from the
+// config dir cache when it is fresh unless --refresh/--no-cache is set
+func availableSubscriptions(ctx context.Context, cfg *config.Config, token *config.SavedToken) ([]auth.Subscription, error) {
+	if subscriptionsCacheTTL < 0 {
+		return nil, fmt.Errorf("--cache-ttl must not be negative")
+	}
+	if !refreshSubscriptions {
+		if subscriptions, ok := cfg.LoadSubscriptions(token, subscriptionsCacheTTL); ok {
+			return subscriptions, nil
+		}
+	}
+
+	subscriptions, err := listSubscriptions(ctx, tokenCloud(token).ManagementEndpoint, token.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed cache write only costs a repeated request next time
+	if err := cfg.SaveSubscriptions(token, subscriptions); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return subscriptions, nil
 }
 
 func runGetAccessToken(cmd *cobra.Command, args []string) error {
+	if expiryThreshold < 0 {
+		return fmt.Errorf("--expiry-threshold must not be negative")
+	}
+	if accessTokenTenantID != "" && !isValidUUID(accessTokenTenantID) {
+		return fmt.Errorf("tenant must be a valid UUID")
+	}
+
+	if accessTokenAsSecret {
+		if err := validateKubernetesSecret(secretName, secretNamespace, secretKey); err != nil {
+			return err
+		}
+	}
+
 	cfg := config.NewConfig()
+	if tokenDryRun {
+		return printTokenDryRun(cfg)
+	}
+	if accessTokenAsSecret && secretsToFileOnly {
+		// --output-file cannot be combined with --as-k8s-secret, so suggest the file-based route
+		return fmt.Errorf("refusing to print a Kubernetes Secret manifest to stdout with --secrets-to-file-only; write the token with --output-file instead and create the Secret from it (kubectl create secret generic --from-file)")
+	}
+	if accessTokenOutputFile == "" {
+		if err := checkSecretToStdout(cmd); err != nil {
+			return err
+		}
+	}
+
 	token, err := cfg.LoadToken()
 	if err != nil {
 		return fmt.Errorf("not authenticated. Run 'azure-login login' first")
 	}
 
-	// Check if token is expired or expiring soon (5 minute buffer for clock skew and API latency)
-	// Use UTC to avoid timezone-related issues
-	const tokenExpirationBuffer = 5 * time.Minute
-	if time.Now().UTC().Add(tokenExpirationBuffer).After(token.ExpiresOn) {
-		return fmt.Errorf("token expired or expiring soon. Please re-authenticate with 'azure-login login'")
+	scope := requestedScope()
+	if scope != "" && scope != primaryScope(token) {
+		if err := validateClientCredentialsScope(scope); err != nil {
+			return err
+		}
+	}
+	if validateToken {
+		effectiveScope := scope
+		if effectiveScope == "" {
+			effectiveScope = primaryScope(token)
+		}
+		if err := checkValidateScope(token, effectiveScope); err != nil {
+			return err
+		}
+	}
+
+	if accessTokenTenantID != "" && !strings.EqualFold(accessTokenTenantID, token.TenantID) {
+		// Tokens for another tenant are always exchanged fresh
+		token, err = tenantAccessToken(commandContext(cmd), token, accessTokenTenantID, scope)
+		if err != nil {
+			return err
+		}
+	} else if scope != "" && scope != primaryScope(token) {
+		// Tokens for other resources are exchanged with the saved identity and cached per scope
+		token, err = scopedAccessToken(commandContext(cmd), cfg, token, scope)
+		if err != nil {
+			return err
+		}
+	} else if action := decideTokenAction(token, time.Now(), expiryThreshold, noRefresh); action != tokenActionCache {
+		// The cached token is expired or expiring soon; a refresh targets its own tenant
+		if action == tokenActionRefresh {
+			if err := checkRefreshTenant(token, accessTokenTenantID); err != nil {
+				return err
+			}
+		}
+		switch {
+		case action == tokenActionFail:
+			return fmt.Errorf("token expired or expiring soon. Please re-authenticate with 'azure-login login'")
+		case allowExtendedValidity:
+			token, err = refreshOrExtend(commandContext(cmd), cfg, token)
+		default:
+			token, err = refreshExpiredToken(commandContext(cmd), cfg, token)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if validateToken {
+		if err := validateCachedToken(commandContext(cmd), token); err != nil {
+			return err
+		}
+	}
+
+	if githubActionsMode {
+		if err := emitGitHubActionsCommands(os.Stderr, token.AccessToken, token.ExpiresOn); err != nil {
+			return err
+		}
+	}
+
+	if accessTokenAsSecret {
+		format := "yaml"
+		if cmd.Flags().Changed("output") {
+			format = outputFormat
+		}
+		manifest := kubernetesSecret(secretName, secretNamespace, secretKey, token.AccessToken)
+		return output.PrintWithOptions(manifest, format, queryString, outputOptions())
 	}
 
 	// Create response matching Azure CLI format
@@ -85,9 +530,227 @@ func runGetAccessToken(cmd *cobra.Command, args []string) error {
 		"accessToken":  token.AccessToken,
 		"expiresOn":    token.ExpiresOn.Format("2006-01-02 15:04:05.000000"),
 		"subscription": token.SubscriptionID,
-		"tenant":       token.TenantID,
+		"tenant":       accessTokenTenant(token),
 		"tokenType":    "Bearer",
 	}
+	if includeFingerprint {
+		tokenInfo["tokenFingerprint"] = tokenFingerprint(token.AccessToken)
+	}
+
+	// With --output-file only the file holds the token; stdout gets the redacted details
+	opts := outputOptions()
+	if accessTokenOutputFile != "" {
+		if err := writeSecretFile(accessTokenOutputFile, token.AccessToken); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Wrote access token to %s\n", accessTokenOutputFile)
+		opts.RedactSecrets = true
+	}
+	return output.PrintWithOptions(tokenInfo, outputFormat, queryString, opts)
+}
+
+// requestedScope returns the scope selected by --scope or --resource, if any
+func requestedScope() string {
+	if accessTokenResource != "" {
+		return strings.TrimSuffix(accessTokenResource, "/") + "/.default"
+	}
+	return accessTokenScope
+}
+
+// primaryScope returns the scope of the token cached at login
+func primaryScope(token *config.SavedToken) string {
+	if token.Scope != "" {
+		return token.Scope
+	}
+	return tokenCloud(token).ManagementScope()
+}
+
+// scopedAccessToken returns a token for scope, reusing the per-scope cache while it is
+// valid and otherwise exchanging a fresh OIDC token for the saved identity
+func scopedAccessToken(ctx context.Context, cfg *config.Config, token *config.SavedToken, scope string) (*config.SavedToken, error) {
+	cached := loadScopedToken(cfg, scope)
+	switch decideTokenAction(cached, time.Now(), expiryThreshold, noRefresh) {
+	case tokenActionCache:
+		return cached, nil
+	case tokenActionFail:
+		return nil, fmt.Errorf("no valid cached token for scope %s. Run 'azure-login login --scope %s'", scope, scope)
+	}
+
+	identity := *token
+	identity.Scope = scope
+	exchanged, err := refreshAccessToken(ctx, &identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token for scope %s: %w", scope, err)
+	}
+	if err := cfg.SaveTokenForScope(exchanged); err != nil {
+		return nil, fmt.Errorf("failed to save token: %w", err)
+	}
+	return cfg.LoadTokenForScope(scope)
+}
+
+// tenantAccessToken exchanges a fresh assertion for a token issued by tenantID, using
+// the saved client ID. The result is not cached: the token files hold one tenant.
+func tenantAccessToken(ctx context.Context, token *config.SavedToken, tenantID, scope string) (*config.SavedToken, error) {
+	identity := *token
+	identity.TenantID = tenantID
+	identity.AuthorityTenantID = "" // the requested tenant issues the token
+	if scope != "" {
+		identity.Scope = scope
+	}
+
+	exchanged, err := refreshAccessToken(ctx, &identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token for tenant %s (app registration %s must be available in that tenant, with a federated credential or certificate it accepts): %w",
+			tenantID, token.ClientID, err)
+	}
+	return &config.SavedToken{
+		AccessToken:    exchanged.AccessToken,
+		TokenType:      exchanged.TokenType,
+		ExpiresOn:      exchanged.ExpiresOn,
+		ExtExpiresOn:   exchanged.ExtExpiresOn,
+		TenantID:       tenantID,
+		ClientID:       token.ClientID,
+		SubscriptionID: token.SubscriptionID,
+		Scope:          identity.Scope,
+		Cloud:          token.Cloud,
+		OIDCAudience:   token.OIDCAudience,
+	}, nil
+}
+
+// loadScopedToken returns the token cached for scope, or nil if there is none
+func loadScopedToken(cfg *config.Config, scope string) *config.SavedToken {
+	cached, err := cfg.LoadTokenForScope(scope)
+	if err != nil {
+		return nil
+	}
+	return cached
+}
+
+// tokenAction is how get-access-token serves a request
+type tokenAction string
+
+const (
+	// tokenActionCache serves the cached token as is
+	tokenActionCache tokenAction = "cache"
+
+	// tokenActionRefresh exchanges a fresh assertion for a new token
+	tokenActionRefresh tokenAction = "refresh"
+
+	// tokenActionFail rejects the request (--no-refresh with no usable cached token)
+	tokenActionFail tokenAction = "fail"
+)
+
+// decideTokenAction decides how a cached token (nil if none is cached) is served at
+// time now. It has no side effects, so --dry-run reports exactly what the real path does.
+func decideTokenAction(token *config.SavedToken, now time.Time, threshold time.Duration, noRefresh bool) tokenAction {
+	switch {
+	case token != nil && !now.UTC().Add(threshold).After(token.ExpiresOn):
+		return tokenActionCache
+	case noRefresh:
+		return tokenActionFail
+	default:
+		return tokenActionRefresh
+	}
+}
+
+// printTokenDryRun prints the action get-access-token would take, reading only the
+// local token cache
+func printTokenDryRun(cfg *config.Config) error {
+	result := map[string]any{}
+
+	token, err := cfg.LoadToken()
+	if err != nil {
+		result["action"] = tokenActionFail
+		result["reason"] = "not authenticated"
+		return output.PrintWithOptions(result, outputFormat, queryString, outputOptions())
+	}
+
+	scope := primaryScope(token)
+	cached := token
+	if requested := requestedScope(); requested != "" && requested != scope {
+		scope = requested
+		cached = loadScopedToken(cfg, scope)
+	}
+	if validateToken {
+		if err := checkValidateScope(token, scope); err != nil {
+			return err
+		}
+	}
+
+	action := decideTokenAction(cached, time.Now(), expiryThreshold, noRefresh)
+	result["action"] = action
+	result["scope"] = scope
+	if cached != nil {
+		result["expiresOn"] = cached.ExpiresOn.Format("2006-01-02 15:04:05.000000")
+	}
+	switch {
+	case action == tokenActionCache:
+		result["reason"] = "cached token is valid beyond the expiry threshold"
+	case cached == nil:
+		result["reason"] = "no cached token for scope"
+	default:
+		result["reason"] = "cached token expires within the expiry threshold"
+	}
+	return output.PrintWithOptions(result, outputFormat, queryString, outputOptions())
+}
+
+// validateAccessToken checks a token against Azure. It is a variable so tests can
+// simulate a revoked token without network access.
+var validateAccessToken = auth.ValidateAccessToken
+
+// validateCachedToken confirms an Azure Resource Manager token is still accepted
+func validateCachedToken(ctx context.Context, token *config.SavedToken) error {
+	if err := checkValidateScope(token, primaryScope(token)); err != nil {
+		return err
+	}
+	azureCloud := tokenCloud(token)
+	return validateAccessToken(ctx, azureCloud.ManagementEndpoint, token.AccessToken, token.SubscriptionID)
+}
+
+// checkValidateScope fails unless scope is the Azure Resource Manager scope of the
+// token's cloud, the only scope --validate can check. Callers run it before any
+// exchange, so an unsupported scope does not acquire and cache a token first.
+func checkValidateScope(token *config.SavedToken, scope string) error {
+	if scope != tokenCloud(token).ManagementScope() {
+		return fmt.Errorf("--validate only supports Azure Resource Manager tokens (scope: %s)", scope)
+	}
+	return nil
+}
+
+// accessTokenTenant returns the tenant that issued the access token, read from its tid
+// claim, so the output matches the token even when it was acquired from another
+// tenant than the saved one (e.g. --authority-tenant). Tokens that cannot be decoded
+// fall back to the saved tenant.
+func accessTokenTenant(token *config.SavedToken) string {
+	_, claims, err := auth.DecodeJWT(token.AccessToken)
+	if err != nil {
+		return token.TenantID
+	}
+	if tid, ok := claims["tid"].(string); ok && tid != "" {
+		return tid
+	}
+	return token.TenantID
+}
+
+// tokenCloud returns the cloud that issued a cached token. Tokens cached before
+// the cloud was recorded belong to the public cloud.
+func tokenCloud(token *config.SavedToken) cloud.Cloud {
+	azureCloud, err := cloud.Lookup(token.Cloud)
+	if err != nil {
+		return cloud.AzurePublicCloud
+	}
+	return azureCloud
+}
+
+// tokenFingerprint returns a non-reversible fingerprint (SHA-256 hex prefix) of an
+// access token, so tokens can be compared across steps without logging them
+func tokenFingerprint(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(sum[:])[:tokenFingerprintLength]
+}
 
-	return output.Print(tokenInfo, outputFormat, queryString)
+// tokenExpiresWithin reports whether a token expires within the given threshold.
+// Use UTC to avoid timezone-related issues.
+func tokenExpiresWithin(expiresOn time.Time, threshold time.Duration) bool {
+	return time.Now().UTC().Add(threshold).After(expiresOn)
 }
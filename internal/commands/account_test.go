@@ -1,13 +1,22 @@
 package commands
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/cogna-public/azure-login/internal/auth"
+	"github.com/cogna-public/azure-login/internal/output"
 	"github.com/cogna-public/azure-login/pkg/config"
+	"gopkg.in/yaml.v3"
 )
 
 func setupTestConfig(t *testing.T) string {
@@ -134,6 +143,9 @@ func TestRunGetAccessToken_ExpiredToken(t *testing.T) {
 		t.Fatalf("Failed to save test token: %v", err)
 	}
 
+	noRefresh = true
+	defer func() { noRefresh = false }()
+
 	// Running get-access-token should fail with expiration error
 	cmd := accountGetAccessTokenCmd
 	err = cmd.RunE(cmd, []string{})
@@ -165,6 +177,9 @@ func TestRunGetAccessToken_ExpiringSoonToken(t *testing.T) {
 		t.Fatalf("Failed to save test token: %v", err)
 	}
 
+	noRefresh = true
+	defer func() { noRefresh = false }()
+
 	// Running get-access-token should fail due to expiration buffer
 	cmd := accountGetAccessTokenCmd
 	err = cmd.RunE(cmd, []string{})
@@ -241,3 +256,1051 @@ func TestRunGetAccessToken_DifferentFormats(t *testing.T) {
 		})
 	}
 }
+
+func TestRunGetAccessToken_StrictQuery(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	cfg := config.NewConfig()
+	testToken := &auth.TokenResponse{
+		AccessToken:    "test-token",
+		TokenType:      "Bearer",
+		ExpiresIn:      3600,
+		ExpiresOn:      time.Now().Add(1 * time.Hour),
+		TenantID:       "test-tenant",
+		ClientID:       "test-client",
+		SubscriptionID: "test-subscription",
+	}
+	if err := cfg.SaveToken(testToken); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	cmd := accountGetAccessTokenCmd
+	outputFormat = "tsv"
+	queryString = "acessToken" // typo
+	defer func() { queryString = "" }()
+
+	// Lenient by default
+	if err := cmd.RunE(cmd, []string{}); err != nil {
+		t.Errorf("Expected no error without --strict-query, got: %v", err)
+	}
+
+	strictQuery = true
+	defer func() { strictQuery = false }()
+	if err := cmd.RunE(cmd, []string{}); err == nil {
+		t.Error("Expected error with --strict-query on a missing field, got none")
+	}
+}
+
+func TestTokenExpiresWithin_Boundaries(t *testing.T) {
+	threshold := 20 * time.Minute
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		expected  bool
+	}{
+		{name: "Just above threshold", expiresIn: threshold + time.Minute, expected: false},
+		{name: "Just below threshold", expiresIn: threshold - time.Minute, expected: true},
+		{name: "Already expired", expiresIn: -time.Minute, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expiresOn := time.Now().UTC().Add(tt.expiresIn)
+			if got := tokenExpiresWithin(expiresOn, threshold); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDecideTokenAction(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		token     *config.SavedToken
+		noRefresh bool
+		expected  tokenAction
+	}{
+		{"valid", &config.SavedToken{ExpiresOn: now.Add(time.Hour)}, false, tokenActionCache},
+		{"valid with --no-refresh", &config.SavedToken{ExpiresOn: now.Add(time.Hour)}, true, tokenActionCache},
+		{"expiring", &config.SavedToken{ExpiresOn: now.Add(2 * time.Minute)}, false, tokenActionRefresh},
+		{"expired", &config.SavedToken{ExpiresOn: now.Add(-time.Minute)}, false, tokenActionRefresh},
+		{"expired with --no-refresh", &config.SavedToken{ExpiresOn: now.Add(-time.Minute)}, true, tokenActionFail},
+		{"not cached", nil, false, tokenActionRefresh},
+		{"not cached with --no-refresh", nil, true, tokenActionFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decideTokenAction(tt.token, now, tokenExpirationBuffer, tt.noRefresh); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRunGetAccessToken_DryRun(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	original := refreshAccessToken
+	refreshAccessToken = func(ctx context.Context, token *config.SavedToken) (*auth.TokenResponse, error) {
+		t.Error("Expected no refresh with --dry-run")
+		return nil, fmt.Errorf("unexpected refresh")
+	}
+	defer func() { refreshAccessToken = original }()
+
+	tokenDryRun = true
+	outputFormat = "json"
+	queryString = "action"
+	defer func() {
+		tokenDryRun = false
+		noRefresh = false
+		queryString = ""
+	}()
+
+	cmd := accountGetAccessTokenCmd
+	run := func() string {
+		t.Helper()
+		var runErr error
+		out := captureStdout(t, func() {
+			runErr = cmd.RunE(cmd, []string{})
+		})
+		if runErr != nil {
+			t.Fatalf("--dry-run failed: %v", runErr)
+		}
+		return strings.TrimSpace(out)
+	}
+
+	if out := run(); out != `"fail"` {
+		t.Errorf("Expected fail when not authenticated, got %s", out)
+	}
+
+	save := func(expiresIn time.Duration) {
+		t.Helper()
+		if err := config.NewConfig().SaveToken(&auth.TokenResponse{
+			AccessToken: "test-token",
+			ExpiresOn:   time.Now().Add(expiresIn),
+		}); err != nil {
+			t.Fatalf("Failed to save token: %v", err)
+		}
+	}
+
+	save(time.Hour)
+	if out := run(); out != `"cache"` {
+		t.Errorf("Expected cache for a valid token, got %s", out)
+	}
+
+	save(-time.Minute)
+	if out := run(); out != `"refresh"` {
+		t.Errorf("Expected refresh for an expired token, got %s", out)
+	}
+
+	noRefresh = true
+	if out := run(); out != `"fail"` {
+		t.Errorf("Expected fail for an expired token with --no-refresh, got %s", out)
+	}
+}
+
+func TestRunGetAccessToken_ExpiryThreshold(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	// Token valid for 15 minutes: fine with the default buffer, not with a 20 minute threshold
+	cfg := config.NewConfig()
+	testToken := &auth.TokenResponse{
+		AccessToken:    "test-token",
+		TokenType:      "Bearer",
+		ExpiresOn:      time.Now().Add(15 * time.Minute),
+		TenantID:       "test-tenant",
+		ClientID:       "test-client",
+		SubscriptionID: "test-subscription",
+	}
+	if err := cfg.SaveToken(testToken); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	cmd := accountGetAccessTokenCmd
+	outputFormat = "json"
+	queryString = ""
+	if err := cmd.RunE(cmd, []string{}); err != nil {
+		t.Errorf("Expected no error with default threshold, got: %v", err)
+	}
+
+	expiryThreshold = 20 * time.Minute
+	defer func() { expiryThreshold = tokenExpirationBuffer }()
+	if err := cmd.RunE(cmd, []string{}); err == nil {
+		t.Error("Expected error with 20 minute threshold, got none")
+	}
+
+	// Zero is the smallest threshold; anything below is rejected before the cache is read
+	expiryThreshold = 0
+	if err := cmd.RunE(cmd, []string{}); err != nil {
+		t.Errorf("Expected no error with zero threshold, got: %v", err)
+	}
+	expiryThreshold = -time.Nanosecond
+	if err := cmd.RunE(cmd, []string{}); err == nil || !strings.Contains(err.Error(), "--expiry-threshold must not be negative") {
+		t.Errorf("Expected negative threshold error, got: %v", err)
+	}
+}
+
+func TestRunAccountShow_SubscriptionAndTenantAliases(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	cfg := config.NewConfig()
+	testToken := &auth.TokenResponse{
+		AccessToken:    "test-token",
+		TokenType:      "Bearer",
+		ExpiresOn:      time.Now().Add(1 * time.Hour),
+		TenantID:       "test-tenant",
+		ClientID:       "test-client",
+		SubscriptionID: "test-subscription",
+	}
+	if err := cfg.SaveToken(testToken); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	cmd := accountShowCmd
+	outputFormat = "tsv"
+	defer func() { queryString = "" }()
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{query: "id", expected: "test-subscription"},
+		{query: "subscriptionId", expected: "test-subscription"},
+		{query: "tenantId", expected: "test-tenant"},
+		{query: "homeTenantId", expected: "test-tenant"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			queryString = tt.query
+			var runErr error
+			out := captureStdout(t, func() {
+				runErr = cmd.RunE(cmd, []string{})
+			})
+			if runErr != nil {
+				t.Fatalf("account show failed: %v", runErr)
+			}
+			if strings.TrimSpace(out) != tt.expected {
+				t.Errorf("Expected %s, got %q", tt.expected, out)
+			}
+		})
+	}
+}
+
+func TestRunGetAccessToken_ValidateRevokedToken(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	cfg := config.NewConfig()
+	testToken := &auth.TokenResponse{
+		AccessToken:    "revoked-token",
+		TokenType:      "Bearer",
+		ExpiresOn:      time.Now().Add(1 * time.Hour),
+		SubscriptionID: "test-subscription",
+	}
+	if err := cfg.SaveToken(testToken); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	// Simulate Azure rejecting the token (401 from the validation call)
+	originalValidate := validateAccessToken
+	validateAccessToken = func(ctx context.Context, managementURL, accessToken, subscriptionID string) error {
+		if subscriptionID != "test-subscription" {
+			t.Errorf("Expected subscription test-subscription, got %s", subscriptionID)
+		}
+		return auth.ErrTokenInvalid
+	}
+	validateToken = true
+	outputFormat = "json"
+	queryString = ""
+	defer func() {
+		validateAccessToken = originalValidate
+		validateToken = false
+	}()
+
+	err := accountGetAccessTokenCmd.RunE(accountGetAccessTokenCmd, []string{})
+	if !errors.Is(err, auth.ErrTokenInvalid) {
+		t.Fatalf("Expected revoked token error, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "re-authenticate") {
+		t.Errorf("Expected re-authenticate hint, got: %v", err)
+	}
+}
+
+func TestRunGetAccessToken_ValidateNonARMScope(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	if err := config.NewConfig().SaveToken(&auth.TokenResponse{
+		AccessToken:    "test-token",
+		TokenType:      "Bearer",
+		ExpiresOn:      time.Now().Add(1 * time.Hour),
+		SubscriptionID: "test-subscription",
+	}); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	exchanged := false
+	original := refreshAccessToken
+	refreshAccessToken = func(ctx context.Context, token *config.SavedToken) (*auth.TokenResponse, error) {
+		exchanged = true
+		return &auth.TokenResponse{AccessToken: "vault-token", ExpiresOn: time.Now().Add(1 * time.Hour), Scope: token.Scope}, nil
+	}
+	validateToken = true
+	accessTokenScope = "https://vault.azure.net/.default"
+	outputFormat = "json"
+	queryString = ""
+	defer func() {
+		refreshAccessToken = original
+		validateToken = false
+		accessTokenScope = ""
+		tokenDryRun = false
+	}()
+
+	// The scope is refused before a token is exchanged or cached for it
+	err := accountGetAccessTokenCmd.RunE(accountGetAccessTokenCmd, []string{})
+	if err == nil || !strings.Contains(err.Error(), "--validate only supports Azure Resource Manager tokens") {
+		t.Fatalf("Expected --validate scope error, got: %v", err)
+	}
+	if exchanged {
+		t.Error("Expected no token exchange for an unsupported --validate scope")
+	}
+	if cached := loadScopedToken(config.NewConfig(), accessTokenScope); cached != nil {
+		t.Errorf("Expected no token cached for the scope, got %+v", cached)
+	}
+
+	// --dry-run reports the same error
+	tokenDryRun = true
+	var dryRunErr error
+	out := captureStdout(t, func() {
+		dryRunErr = accountGetAccessTokenCmd.RunE(accountGetAccessTokenCmd, []string{})
+	})
+	if dryRunErr == nil || !strings.Contains(dryRunErr.Error(), "--validate only supports Azure Resource Manager tokens") {
+		t.Errorf("Expected --validate scope error in dry run, got: %v", dryRunErr)
+	}
+	if out != "" {
+		t.Errorf("Expected no dry-run output, got %q", out)
+	}
+}
+
+func TestTokenFingerprint(t *testing.T) {
+	first := tokenFingerprint("test-token-a")
+	if len(first) != tokenFingerprintLength {
+		t.Errorf("Expected fingerprint length %d, got %d", tokenFingerprintLength, len(first))
+	}
+	if tokenFingerprint("test-token-a") != first {
+		t.Error("Expected fingerprint to be stable for the same token")
+	}
+	if tokenFingerprint("test-token-b") == first {
+		t.Error("Expected fingerprint to differ for another token")
+	}
+	if strings.Contains(first, "test-token") {
+		t.Error("Expected fingerprint not to contain the token")
+	}
+}
+
+func TestRunGetAccessToken_Fingerprint(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	cfg := config.NewConfig()
+	testToken := &auth.TokenResponse{
+		AccessToken:    "test-token",
+		TokenType:      "Bearer",
+		ExpiresOn:      time.Now().Add(1 * time.Hour),
+		SubscriptionID: "test-subscription",
+	}
+	if err := cfg.SaveToken(testToken); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	outputFormat = "tsv"
+	queryString = "tokenFingerprint"
+	includeFingerprint = true
+	defer func() {
+		queryString = ""
+		includeFingerprint = false
+	}()
+
+	var runErr error
+	out := captureStdout(t, func() {
+		runErr = accountGetAccessTokenCmd.RunE(accountGetAccessTokenCmd, []string{})
+	})
+	if runErr != nil {
+		t.Fatalf("get-access-token failed: %v", runErr)
+	}
+	if strings.TrimSpace(out) != tokenFingerprint("test-token") {
+		t.Errorf("Expected fingerprint %s, got %q", tokenFingerprint("test-token"), out)
+	}
+}
+
+func TestRunGetAccessToken_SecretsToFileOnly(t *testing.T) {
+	tmpDir := setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	cfg := config.NewConfig()
+	if err := cfg.SaveToken(&auth.TokenResponse{
+		AccessToken: "test-token",
+		TokenType:   "Bearer",
+		ExpiresOn:   time.Now().Add(1 * time.Hour),
+	}); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	outputFormat = "json"
+	queryString = ""
+	secretsToFileOnly = true
+	defer func() {
+		secretsToFileOnly = false
+		accessTokenOutputFile = ""
+	}()
+
+	var runErr error
+	out := captureStdout(t, func() {
+		runErr = accountGetAccessTokenCmd.RunE(accountGetAccessTokenCmd, []string{})
+	})
+	if runErr == nil || !strings.Contains(runErr.Error(), "--output-file") {
+		t.Errorf("Expected stdout token printing to be refused, got: %v", runErr)
+	}
+	if strings.Contains(out, "test-token") {
+		t.Errorf("Expected no token on stdout, got %q", out)
+	}
+
+	accessTokenOutputFile = filepath.Join(tmpDir, "token")
+	out = captureStdout(t, func() {
+		runErr = accountGetAccessTokenCmd.RunE(accountGetAccessTokenCmd, []string{})
+	})
+	if runErr != nil {
+		t.Fatalf("get-access-token --output-file failed: %v", runErr)
+	}
+	if strings.Contains(out, "test-token") || !strings.Contains(out, output.RedactedValue) {
+		t.Errorf("Expected redacted details on stdout, got %q", out)
+	}
+	data, err := os.ReadFile(accessTokenOutputFile)
+	if err != nil || string(data) != "test-token" {
+		t.Errorf("Expected raw token in output file, got %q (%v)", data, err)
+	}
+	if info, err := os.Stat(accessTokenOutputFile); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected 0600 permissions, got %o", info.Mode().Perm())
+	}
+
+	// oidc get-token without --output-file is refused too
+	if err := oidcGetTokenCmd.RunE(oidcGetTokenCmd, []string{}); err == nil || !strings.Contains(err.Error(), "--secrets-to-file-only") {
+		t.Errorf("Expected oidc get-token to be refused, got: %v", err)
+	}
+
+	// --as-k8s-secret cannot use --output-file, so it is not suggested as the fix
+	accessTokenOutputFile = ""
+	accessTokenAsSecret = true
+	secretName = "azure-token"
+	defer func() {
+		accessTokenAsSecret = false
+		secretName = ""
+	}()
+	runErr = accountGetAccessTokenCmd.RunE(accountGetAccessTokenCmd, []string{})
+	if runErr == nil || !strings.Contains(runErr.Error(), "Kubernetes Secret manifest") || !strings.Contains(runErr.Error(), "--from-file") {
+		t.Errorf("Expected --as-k8s-secret to be refused with a file-based alternative, got: %v", runErr)
+	}
+}
+
+func TestRunGetAccessToken_K8sSecret(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	if err := config.NewConfig().SaveToken(&auth.TokenResponse{
+		AccessToken: "test-token",
+		TokenType:   "Bearer",
+		ExpiresOn:   time.Now().Add(1 * time.Hour),
+	}); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	outputFormat = "json"
+	queryString = ""
+	accessTokenAsSecret = true
+	secretName = "azure-token"
+	secretNamespace = "ci"
+	secretKey = "accessToken"
+	defer func() {
+		accessTokenAsSecret = false
+		secretName = ""
+		secretNamespace = ""
+		secretKey = defaultSecretKey
+	}()
+
+	var runErr error
+	out := captureStdout(t, func() {
+		runErr = accountGetAccessTokenCmd.RunE(accountGetAccessTokenCmd, []string{})
+	})
+	if runErr != nil {
+		t.Fatalf("get-access-token --as-k8s-secret failed: %v", runErr)
+	}
+
+	var manifest struct {
+		APIVersion string            `yaml:"apiVersion"`
+		Kind       string            `yaml:"kind"`
+		Type       string            `yaml:"type"`
+		Metadata   map[string]string `yaml:"metadata"`
+		Data       map[string]string `yaml:"data"`
+	}
+	if err := yaml.Unmarshal([]byte(out), &manifest); err != nil {
+		t.Fatalf("Expected YAML manifest, got %q: %v", out, err)
+	}
+	if manifest.APIVersion != "v1" || manifest.Kind != "Secret" || manifest.Type != "Opaque" {
+		t.Errorf("Unexpected manifest header: %+v", manifest)
+	}
+	if manifest.Metadata["name"] != "azure-token" || manifest.Metadata["namespace"] != "ci" {
+		t.Errorf("Unexpected metadata: %v", manifest.Metadata)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(manifest.Data["accessToken"])
+	if err != nil || string(decoded) != "test-token" {
+		t.Errorf("Expected base64-encoded token under accessToken, got %q (%v)", manifest.Data["accessToken"], err)
+	}
+
+	secretName = "Not_Valid"
+	if err := accountGetAccessTokenCmd.RunE(accountGetAccessTokenCmd, []string{}); err == nil || !strings.Contains(err.Error(), "--secret-name") {
+		t.Errorf("Expected invalid secret name error, got: %v", err)
+	}
+}
+
+func TestRunAccountList_CachesSubscriptions(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	cfg := config.NewConfig()
+	testToken := &auth.TokenResponse{
+		AccessToken:    "test-token",
+		TokenType:      "Bearer",
+		ExpiresOn:      time.Now().Add(1 * time.Hour),
+		TenantID:       "test-tenant",
+		ClientID:       "test-client",
+		SubscriptionID: "sub-1",
+	}
+	if err := cfg.SaveToken(testToken); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	calls := 0
+	originalList := listSubscriptions
+	listSubscriptions = func(ctx context.Context, managementURL, accessToken string) ([]auth.Subscription, error) {
+		calls++
+		return []auth.Subscription{{SubscriptionID: "sub-1", DisplayName: "One", State: "Enabled", TenantID: "test-tenant"}}, nil
+	}
+	outputFormat = "json"
+	queryString = ""
+	defer func() {
+		listSubscriptions = originalList
+		refreshSubscriptions = false
+	}()
+
+	for i := 0; i < 2; i++ {
+		if err := accountListCmd.RunE(accountListCmd, []string{}); err != nil {
+			t.Fatalf("account list failed: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected the second call within TTL to use the cache, got %d network calls", calls)
+	}
+
+	refreshSubscriptions = true
+	if err := accountListCmd.RunE(accountListCmd, []string{}); err != nil {
+		t.Fatalf("account list --refresh failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected --refresh to bypass the cache, got %d network calls", calls)
+	}
+}
+
+func TestRunGetAccessToken_Scope(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	cfg := config.NewConfig()
+	testToken := &auth.TokenResponse{
+		AccessToken:    "management-token",
+		TokenType:      "Bearer",
+		ExpiresOn:      time.Now().Add(1 * time.Hour),
+		TenantID:       "test-tenant",
+		ClientID:       "test-client",
+		SubscriptionID: "test-subscription",
+		Scope:          auth.ManagementScope,
+	}
+	if err := cfg.SaveToken(testToken); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	exchanges := 0
+	original := refreshAccessToken
+	refreshAccessToken = func(ctx context.Context, token *config.SavedToken) (*auth.TokenResponse, error) {
+		exchanges++
+		if token.Scope != "https://vault.azure.net/.default" {
+			t.Errorf("Expected Key Vault scope, got %s", token.Scope)
+		}
+		if token.ClientID != "test-client" || token.TenantID != "test-tenant" {
+			t.Errorf("Expected saved identity, got client %s tenant %s", token.ClientID, token.TenantID)
+		}
+		return &auth.TokenResponse{
+			AccessToken:    "vault-token",
+			TokenType:      "Bearer",
+			ExpiresOn:      time.Now().Add(1 * time.Hour),
+			TenantID:       token.TenantID,
+			ClientID:       token.ClientID,
+			SubscriptionID: token.SubscriptionID,
+			Scope:          token.Scope,
+		}, nil
+	}
+	outputFormat = "json"
+	queryString = ""
+	accessTokenResource = "https://vault.azure.net/"
+	defer func() {
+		refreshAccessToken = original
+		accessTokenResource = ""
+	}()
+
+	for i := 0; i < 2; i++ {
+		var runErr error
+		out := captureStdout(t, func() {
+			runErr = accountGetAccessTokenCmd.RunE(accountGetAccessTokenCmd, []string{})
+		})
+		if runErr != nil {
+			t.Fatalf("get-access-token --resource failed: %v", runErr)
+		}
+		if !strings.Contains(out, "vault-token") || !strings.Contains(out, "expiresOn") {
+			t.Errorf("Expected Key Vault token in Azure CLI shape, got: %s", out)
+		}
+	}
+	if exchanges != 1 {
+		t.Errorf("Expected the scoped token to be cached after one exchange, got %d exchanges", exchanges)
+	}
+
+	// The login-time token is left untouched
+	primary, err := cfg.LoadToken()
+	if err != nil || primary.AccessToken != "management-token" {
+		t.Errorf("Expected primary token to be unchanged, got %+v (%v)", primary, err)
+	}
+}
+
+func TestRunGetAccessToken_TenantFromToken(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	// Acquired through a guest (authority) tenant: the token's tid differs from the saved home tenant
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"tid":"guest-tenant","aud":"https://management.azure.com"}`))
+	accessToken := header + "." + claims + ".c2lnbmF0dXJl"
+
+	cfg := config.NewConfig()
+	testToken := &auth.TokenResponse{
+		AccessToken:    accessToken,
+		TokenType:      "Bearer",
+		ExpiresOn:      time.Now().Add(1 * time.Hour),
+		TenantID:       "home-tenant",
+		ClientID:       "test-client",
+		SubscriptionID: "test-subscription",
+	}
+	if err := cfg.SaveToken(testToken); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	outputFormat = "tsv"
+	queryString = "tenant"
+	defer func() {
+		outputFormat = "json"
+		queryString = ""
+	}()
+
+	var runErr error
+	out := captureStdout(t, func() {
+		runErr = accountGetAccessTokenCmd.RunE(accountGetAccessTokenCmd, []string{})
+	})
+	if runErr != nil {
+		t.Fatalf("get-access-token failed: %v", runErr)
+	}
+	if strings.TrimSpace(out) != "guest-tenant" {
+		t.Errorf("Expected tenant from the token's tid claim, got %q", out)
+	}
+
+	// Opaque (non-JWT) tokens fall back to the saved tenant
+	if got := accessTokenTenant(&config.SavedToken{AccessToken: "opaque", TenantID: "home-tenant"}); got != "home-tenant" {
+		t.Errorf("Expected saved tenant fallback, got %s", got)
+	}
+}
+
+func TestRunGetAccessToken_Tenant(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	const homeTenant = "11111111-1111-1111-1111-111111111111"
+	const otherTenant = "22222222-2222-2222-2222-222222222222"
+	if err := config.NewConfig().SaveToken(&auth.TokenResponse{
+		AccessToken: "home-token",
+		ExpiresOn:   time.Now().Add(time.Hour),
+		TenantID:    homeTenant,
+		ClientID:    "test-client-id",
+	}); err != nil {
+		t.Fatalf("Failed to save token: %v", err)
+	}
+
+	var exchangedTenant string
+	original := refreshAccessToken
+	refreshAccessToken = func(ctx context.Context, token *config.SavedToken) (*auth.TokenResponse, error) {
+		exchangedTenant = token.TenantID
+		if token.ClientID != "test-client-id" {
+			t.Errorf("Expected the saved client ID, got %s", token.ClientID)
+		}
+		return &auth.TokenResponse{AccessToken: "other-token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+	}
+	defer func() { refreshAccessToken = original }()
+
+	outputFormat = "json"
+	queryString = "[accessToken, tenant]"
+	defer func() {
+		accessTokenTenantID = ""
+		queryString = ""
+	}()
+
+	cmd := accountGetAccessTokenCmd
+	accessTokenTenantID = "not-a-uuid"
+	if err := cmd.RunE(cmd, []string{}); err == nil || !strings.Contains(err.Error(), "valid UUID") {
+		t.Errorf("Expected UUID validation error, got: %v", err)
+	}
+
+	accessTokenTenantID = otherTenant
+	var runErr error
+	out := captureStdout(t, func() { runErr = cmd.RunE(cmd, []string{}) })
+	if runErr != nil {
+		t.Fatalf("--tenant failed: %v", runErr)
+	}
+	if exchangedTenant != otherTenant {
+		t.Errorf("Expected an exchange against %s, got %q", otherTenant, exchangedTenant)
+	}
+	if !strings.Contains(out, "other-token") || !strings.Contains(out, otherTenant) {
+		t.Errorf("Expected the other tenant's token, got %s", out)
+	}
+
+	// The saved login is left untouched
+	saved, err := config.NewConfig().LoadToken()
+	if err != nil || saved.AccessToken != "home-token" {
+		t.Errorf("Expected the saved token to be unchanged, got %v (%v)", saved, err)
+	}
+
+	refreshAccessToken = func(ctx context.Context, token *config.SavedToken) (*auth.TokenResponse, error) {
+		return nil, fmt.Errorf("authentication failed: unauthorized_client (AADSTS700016)")
+	}
+	err = cmd.RunE(cmd, []string{})
+	if err == nil || !strings.Contains(err.Error(), "tenant "+otherTenant) || !strings.Contains(err.Error(), "test-client-id") {
+		t.Errorf("Expected a tenant-specific error, got: %v", err)
+	}
+}
+
+func TestRunAccountShow_ExitCode(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	if err := config.NewConfig().SaveToken(&auth.TokenResponse{
+		AccessToken:    "test-token",
+		ExpiresOn:      time.Now().Add(time.Hour),
+		TenantID:       "test-tenant",
+		SubscriptionID: "test-subscription",
+	}); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	cmd := accountShowCmd
+	outputFormat = "exitcode"
+	defer func() {
+		outputFormat = "json"
+		queryString = ""
+	}()
+
+	queryString = "tenantId==`\"test-tenant\"`"
+	if err := cmd.RunE(cmd, []string{}); err != nil {
+		t.Errorf("Expected a true query to succeed, got: %v", err)
+	}
+
+	queryString = "tenantId==`\"other-tenant\"`"
+	if err := cmd.RunE(cmd, []string{}); !errors.Is(err, output.ErrFalseResult) {
+		t.Errorf("Expected ErrFalseResult for a false query, got: %v", err)
+	}
+}
+
+func TestRunAccountWhoami(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"oid":"sp-object-id","azp":"test-client","tid":"test-tenant","aud":"https://management.azure.com","iss":"https://sts.windows.net/test-tenant/","exp":1760000000,"xms_extra":"hidden"}`))
+	accessToken := header + "." + claims + ".signature"
+	if err := config.NewConfig().SaveToken(&auth.TokenResponse{
+		AccessToken: accessToken,
+		ExpiresOn:   time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("Failed to save token: %v", err)
+	}
+
+	cmd := accountWhoamiCmd
+	outputFormat = "json"
+	queryString = ""
+	var runErr error
+	out := captureStdout(t, func() { runErr = cmd.RunE(cmd, []string{}) })
+	if runErr != nil {
+		t.Fatalf("whoami failed: %v", runErr)
+	}
+
+	var info map[string]any
+	if err := json.Unmarshal([]byte(out), &info); err != nil {
+		t.Fatalf("Failed to parse output %q: %v", out, err)
+	}
+	if info["oid"] != "sp-object-id" || info["appid"] != "test-client" || info["tid"] != "test-tenant" {
+		t.Errorf("Unexpected identity claims: %v", info)
+	}
+	if info["expiresOn"] != "2025-10-09T08:53:20Z" {
+		t.Errorf("Expected expiresOn from exp, got %v", info["expiresOn"])
+	}
+	if _, ok := info["xms_extra"]; ok {
+		t.Error("Expected only the selected claims")
+	}
+	if strings.Contains(out, accessToken) || strings.Contains(out, "signature") {
+		t.Error("Expected the raw token never to be printed")
+	}
+
+	// A cached token that is not a JWT fails clearly
+	if err := config.NewConfig().SaveToken(&auth.TokenResponse{AccessToken: "opaque-token", ExpiresOn: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("Failed to save token: %v", err)
+	}
+	err := cmd.RunE(cmd, []string{})
+	if err == nil || !strings.Contains(err.Error(), "not a well-formed JWT") {
+		t.Errorf("Expected a malformed JWT error, got: %v", err)
+	}
+}
+
+func TestRunAccountShow_Expiry(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	cmd := accountShowCmd
+	outputFormat = "json"
+	queryString = "{expired: expired, expiresIn: expiresIn, expiresOn: expiresOn}"
+	defer func() { queryString = "" }()
+
+	show := func(expiresOn time.Time) map[string]any {
+		t.Helper()
+		if err := config.NewConfig().SaveToken(&auth.TokenResponse{AccessToken: "test-token", ExpiresOn: expiresOn}); err != nil {
+			t.Fatalf("Failed to save token: %v", err)
+		}
+		var runErr error
+		out := captureStdout(t, func() { runErr = cmd.RunE(cmd, []string{}) })
+		if runErr != nil {
+			t.Fatalf("account show failed: %v", runErr)
+		}
+		var info map[string]any
+		if err := json.Unmarshal([]byte(out), &info); err != nil {
+			t.Fatalf("Failed to parse output %q: %v", out, err)
+		}
+		return info
+	}
+
+	expiresOn := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	info := show(expiresOn)
+	if info["expired"] != false || info["expiresOn"] != expiresOn.Format(time.RFC3339) {
+		t.Errorf("Unexpected expiry for a valid token: %v", info)
+	}
+	if expiresIn, _ := info["expiresIn"].(float64); expiresIn < 3500 || expiresIn > 3600 {
+		t.Errorf("Expected expiresIn close to 3600, got %v", info["expiresIn"])
+	}
+
+	info = show(time.Now().Add(-time.Minute))
+	if info["expired"] != true || info["expiresIn"] != float64(0) {
+		t.Errorf("Expected an expired token with expiresIn 0, got %v", info)
+	}
+}
+
+func TestPrimaryScope(t *testing.T) {
+	legacy := &config.SavedToken{AccessToken: "token"}
+	if got := primaryScope(legacy); got != "https://management.azure.com/.default" {
+		t.Errorf("Expected a token without scope to default to the management scope, got %s", got)
+	}
+
+	gov := &config.SavedToken{AccessToken: "token", Cloud: "AzureUSGovernment"}
+	if got := primaryScope(gov); got != "https://management.usgovcloudapi.net/.default" {
+		t.Errorf("Expected the cloud's management scope, got %s", got)
+	}
+
+	scoped := &config.SavedToken{AccessToken: "token", Scope: "api://my-app/.default"}
+	if got := primaryScope(scoped); got != "api://my-app/.default" {
+		t.Errorf("Expected the persisted scope, got %s", got)
+	}
+}
+
+func TestRunAccountListProfiles(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	t.Setenv(config.ProfileEnvVar, "")
+
+	expiresOn := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := config.NewConfig().SaveToken(&auth.TokenResponse{AccessToken: "default-token", ExpiresOn: expiresOn, TenantID: "tenant-a"}); err != nil {
+		t.Fatalf("Failed to save token: %v", err)
+	}
+	config.SetProfile("ci")
+	defer config.SetProfile("")
+	if err := config.NewConfig().SaveToken(&auth.TokenResponse{AccessToken: "ci-token", ExpiresOn: expiresOn, TenantID: "tenant-b"}); err != nil {
+		t.Fatalf("Failed to save token: %v", err)
+	}
+
+	cmd := accountListProfilesCmd
+	outputFormat = "json"
+	queryString = ""
+	var runErr error
+	out := captureStdout(t, func() { runErr = cmd.RunE(cmd, []string{}) })
+	if runErr != nil {
+		t.Fatalf("list-profiles failed: %v", runErr)
+	}
+
+	var profiles []map[string]any
+	if err := json.Unmarshal([]byte(out), &profiles); err != nil {
+		t.Fatalf("Failed to parse output %q: %v", out, err)
+	}
+	if len(profiles) != 2 {
+		t.Fatalf("Expected 2 profiles, got %v", profiles)
+	}
+	if profiles[0]["name"] != "ci" || profiles[0]["active"] != true || profiles[0]["tenantId"] != "tenant-b" {
+		t.Errorf("Unexpected active profile entry: %v", profiles[0])
+	}
+	if profiles[1]["name"] != "default" || profiles[1]["active"] != false || profiles[1]["expiresOn"] != "2030-01-02T03:04:05Z" {
+		t.Errorf("Unexpected default profile entry: %v", profiles[1])
+	}
+	if strings.Contains(out, "ci-token") {
+		t.Error("Expected access tokens never to be printed")
+	}
+}
+
+func TestRunAccountClear(t *testing.T) {
+	tmpDir := setupTestConfig(t)
+	defer cleanupTestConfig()
+	t.Setenv(config.ProfileEnvVar, "")
+	defer func() {
+		clearAll = false
+		clearDryRun = false
+		config.SetProfile("")
+	}()
+
+	save := func(profile, accessToken string, expiresOn time.Time) {
+		t.Helper()
+		config.SetProfile(profile)
+		if err := config.NewConfig().SaveToken(&auth.TokenResponse{AccessToken: accessToken, ExpiresOn: expiresOn}); err != nil {
+			t.Fatalf("Failed to save token: %v", err)
+		}
+	}
+	save("", "valid-token", time.Now().Add(time.Hour))
+	save("old", "expired-token", time.Now().Add(-time.Hour))
+	config.SetProfile("")
+	if err := config.NewConfig().SaveTokenForScope(&auth.TokenResponse{AccessToken: "expired-scoped", Scope: "api://app/.default", ExpiresOn: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatalf("Failed to save scoped token: %v", err)
+	}
+	countFiles := func() int {
+		files, err := config.NewConfig().TokenFiles()
+		if err != nil {
+			t.Fatalf("TokenFiles failed: %v", err)
+		}
+		return len(files)
+	}
+
+	clearDryRun = true
+	out := captureStderr(t, func() {
+		if err := accountClearCmd.RunE(accountClearCmd, []string{}); err != nil {
+			t.Fatalf("clear --dry-run failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "Would remove 1 token file(s)") || countFiles() != 3 {
+		t.Errorf("Expected a preview of 1 file and nothing deleted, got %q with %d files left", out, countFiles())
+	}
+
+	// Only the expired files of the active profile are removed
+	clearDryRun = false
+	out = captureStderr(t, func() {
+		if err := accountClearCmd.RunE(accountClearCmd, []string{}); err != nil {
+			t.Fatalf("clear failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "Removed 1 token file(s)") || strings.Contains(out, "azure-login-token-old.json") {
+		t.Errorf("Expected only the default profile's expired file to be removed, got %q", out)
+	}
+	if _, err := config.NewConfig().LoadToken(); err != nil {
+		t.Errorf("Expected the valid token to be kept: %v", err)
+	}
+
+	config.SetProfile("old")
+	out = captureStderr(t, func() {
+		if err := accountClearCmd.RunE(accountClearCmd, []string{}); err != nil {
+			t.Fatalf("clear --profile old failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "Removed 1 token file(s)") || !strings.Contains(out, filepath.Join(tmpDir, "azure-login-token-old.json")) {
+		t.Errorf("Expected the expired profile file to be reported, got %q", out)
+	}
+
+	// --all removes every file of every profile
+	clearAll = true
+	out = captureStderr(t, func() {
+		if err := accountClearCmd.RunE(accountClearCmd, []string{}); err != nil {
+			t.Fatalf("clear --all failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "Removed 1 token file(s)") || countFiles() != 0 {
+		t.Errorf("Expected --all to remove the remaining file, got %q with %d files left", out, countFiles())
+	}
+}
+
+func TestRunAccountDump(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	expiresOn := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := config.NewConfig().SaveToken(&auth.TokenResponse{
+		AccessToken:  "test-token",
+		TokenType:    "Bearer",
+		ExpiresOn:    expiresOn,
+		TenantID:     "test-tenant",
+		Scope:        "api://my-app/.default",
+		OIDCAudience: "custom-audience",
+	}); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	cmd := accountDumpCmd
+	outputFormat = "json"
+	queryString = ""
+	showSecrets = false
+	defer func() { showSecrets = false }()
+
+	var runErr error
+	out := captureStdout(t, func() { runErr = cmd.RunE(cmd, []string{}) })
+	if runErr == nil || !strings.Contains(runErr.Error(), "--show-secrets") {
+		t.Errorf("Expected dump to require --show-secrets, got: %v", runErr)
+	}
+	if out != "" {
+		t.Errorf("Expected nothing printed without --show-secrets, got %q", out)
+	}
+
+	showSecrets = true
+	out = captureStdout(t, func() { runErr = cmd.RunE(cmd, []string{}) })
+	if runErr != nil {
+		t.Fatalf("dump failed: %v", runErr)
+	}
+	var dumped map[string]any
+	if err := json.Unmarshal([]byte(out), &dumped); err != nil {
+		t.Fatalf("Failed to parse output %q: %v", out, err)
+	}
+	if dumped["access_token"] != "test-token" || dumped["tenant_id"] != "test-tenant" || dumped["oidc_audience"] != "custom-audience" {
+		t.Errorf("Expected the stored token fields, got %v", dumped)
+	}
+	if dumped["expires_on"] != "2030-01-02T03:04:05Z" || dumped["scope"] != "api://my-app/.default" {
+		t.Errorf("Expected stored expiry and scope, got %v", dumped)
+	}
+}
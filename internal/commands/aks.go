@@ -2,11 +2,14 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/cogna-public/azure-login/internal/aks"
+	"github.com/cogna-public/azure-login/internal/output"
 	"github.com/cogna-public/azure-login/pkg/config"
 	"github.com/spf13/cobra"
 )
@@ -14,6 +17,40 @@ import (
 var (
 	resourceGroup string
 	clusterName   string
+	aksNamespace  string
+	aksAdmin      bool
+	aksLast       bool
+	aksPrivate    bool
+
+	// aksShowRateLimits prints the remaining ARM request quotas (--show-rate-limits)
+	aksShowRateLimits bool
+
+	// aksOverwriteExisting and aksNoSetCurrent control how an existing kubeconfig is updated
+	aksOverwriteExisting bool
+	aksNoSetCurrent      bool
+
+	// aksLogin runs login before fetching the credentials (--login)
+	aksLogin bool
+
+	// aksOutputFormat and aksQueryString print the merge result to stdout (--output, --query)
+	aksOutputFormat string
+	aksQueryString  string
+
+	// aksKubeconfigFile merges into this kubeconfig instead of KUBECONFIG or ~/.kube/config (--file)
+	aksKubeconfigFile string
+
+	// aksBackup copies the kubeconfig to <path>.bak before it is rewritten (--backup)
+	aksBackup bool
+
+	// aksLoginMethod selects how the generated exec user authenticates (--login-method)
+	aksLoginMethod string
+
+	// kubeloginArgs and kubeloginEnv customize the generated exec config
+	kubeloginArgs []string
+	kubeloginEnv  []string
+
+	convertKubeconfigPath string
+	convertKubeloginMode  string
 )
 
 var aksCmd = &cobra.Command{
@@ -29,75 +66,371 @@ var aksGetCredentialsCmd = &cobra.Command{
 
 This command retrieves the cluster credentials from Azure and merges them into
 your kubeconfig file. The cluster will be configured to use Azure CLI authentication
-via kubelogin.`,
+via kubelogin.
+
+With --admin, the cluster admin credentials (listClusterAdminCredential) are
+merged instead: a client-certificate user named clusterAdmin_<rg>_<cluster> and
+a "<cluster>-admin" context, as the Azure CLI writes them. This is intended for
+break-glass access and fails on clusters with local accounts disabled.
+
+The resource group and cluster name default to the AZURE_RESOURCE_GROUP and
+AZURE_AKS_CLUSTER (or AKS_CLUSTER_NAME) environment variables when the flags are omitted. Each
+successful run is remembered, and --last re-fetches that cluster (in its
+subscription) without repeating the flags.
+
+With --login, the command first logs in as 'azure-login login' would (identity
+from AZURE_CLIENT_ID, AZURE_TENANT_ID and AZURE_SUBSCRIPTION_ID), so a simple
+pipeline needs a single command.`,
 	RunE: runGetCredentials,
 }
 
+var aksConvertKubeconfigCmd = &cobra.Command{
+	Use:   "convert-kubeconfig",
+	Short: "Convert azure auth-provider users in kubeconfig to exec authentication",
+	Long: `Rewrite kubeconfig users that use the legacy azure auth-provider (as written by
+'az aks get-credentials --format azure') to exec-based authentication, without
+re-downloading cluster credentials. This mirrors 'kubelogin convert-kubeconfig'.
+
+Supported modes:
+  azure-login  Use 'azure-login kubectl-credential' (default)
+  azurecli     Use 'kubelogin get-token --login azurecli'`,
+	RunE: runConvertKubeconfig,
+}
+
+var aksListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the AKS clusters in the subscription",
+	Long: `List the managed Kubernetes clusters in the subscription of the cached login,
+with their resource group, location and power state.`,
+	RunE: runAKSList,
+}
+
 func init() {
 	aksCmd.AddCommand(aksGetCredentialsCmd)
+	aksCmd.AddCommand(aksListCmd)
+	aksCmd.AddCommand(aksConvertKubeconfigCmd)
 
 	// Add flags for get-credentials
-	aksGetCredentialsCmd.Flags().StringVarP(&resourceGroup, "resource-group", "g", "", "Resource group name (required)")
-	aksGetCredentialsCmd.Flags().StringVarP(&clusterName, "name", "n", "", "Cluster name (required)")
-	_ = aksGetCredentialsCmd.MarkFlagRequired("resource-group")
-	_ = aksGetCredentialsCmd.MarkFlagRequired("name")
+	aksGetCredentialsCmd.Flags().StringVarP(&resourceGroup, "resource-group", "g", "", "Resource group name (required unless AZURE_RESOURCE_GROUP is set)")
+	aksGetCredentialsCmd.Flags().StringVarP(&clusterName, "name", "n", "", "Cluster name (required unless AZURE_AKS_CLUSTER or AKS_CLUSTER_NAME is set)")
+	aksGetCredentialsCmd.Flags().StringVar(&aksNamespace, "namespace", "", "Default namespace for the context (an existing context keeps its namespace if omitted)")
+	aksGetCredentialsCmd.Flags().BoolVar(&aksAdmin, "admin", false, "Get the cluster admin (client certificate) credentials instead of Azure AD user credentials")
+	aksGetCredentialsCmd.Flags().BoolVar(&aksLogin, "login", false, "Log in first (as 'azure-login login' with AZURE_CLIENT_ID, AZURE_TENANT_ID and AZURE_SUBSCRIPTION_ID), then fetch the credentials with the new token")
+	aksGetCredentialsCmd.Flags().BoolVar(&aksLast, "last", false, "Re-fetch the credentials of the last successful get-credentials target")
+	aksGetCredentialsCmd.MarkFlagsMutuallyExclusive("last", "resource-group")
+	aksGetCredentialsCmd.MarkFlagsMutuallyExclusive("last", "name")
+	aksGetCredentialsCmd.Flags().BoolVar(&aksPrivate, "private", false, "Use the private FQDN of a private cluster as the server URL (for access over VPN or private link)")
+	aksGetCredentialsCmd.Flags().BoolVar(&aksShowRateLimits, "show-rate-limits", false, "Print the remaining Azure Resource Manager request quotas (x-ms-ratelimit-remaining-*) to stderr")
+	aksGetCredentialsCmd.Flags().BoolVar(&aksOverwriteExisting, "overwrite-existing", false, "Replace an existing context of the same name that points at a different server")
+	aksGetCredentialsCmd.Flags().StringVarP(&aksOutputFormat, "output", "o", "", "Print the merged context, kubeconfig path and cluster to stdout: json, ndjson, yaml, tsv, table, detail, value (default: none)")
+	aksGetCredentialsCmd.Flags().StringVar(&aksQueryString, "query", "", "JMESPath query string for --output")
+	aksGetCredentialsCmd.Flags().StringVarP(&aksKubeconfigFile, "file", "f", "", "Kubeconfig file to update (default: KUBECONFIG or ~/.kube/config); created with its directory if missing")
+	aksGetCredentialsCmd.Flags().BoolVar(&aksBackup, "backup", false, "Copy the existing kubeconfig to <path>.bak before merging (replaces the previous backup)")
+	aksGetCredentialsCmd.Flags().BoolVar(&aksNoSetCurrent, "no-set-current", false, "Merge the credentials without switching the current context")
+	aksGetCredentialsCmd.Flags().StringVar(&aksLoginMethod, "login-method", aks.LoginMethodAzureLogin, "How kubectl authenticates: azure-login (azure-login kubectl-credential), or kubelogin with azurecli, workloadidentity or spn")
+	aksGetCredentialsCmd.Flags().StringArrayVar(&kubeloginArgs, "kubelogin-arg", nil, "Extra argument appended to the kubeconfig exec command (repeatable)")
+	aksGetCredentialsCmd.Flags().StringArrayVar(&kubeloginEnv, "kubelogin-env", nil, "Extra NAME=VALUE environment variable for the kubeconfig exec command (repeatable)")
+
+	// Add flags for list
+	aksListCmd.Flags().StringVarP(&outputFormat, "output", "o", "json", "Output format: json, ndjson, yaml, tsv, table, detail, value, exitcode")
+	aksListCmd.Flags().StringVar(&queryString, "query", "", "JMESPath query string")
+
+	// Add flags for convert-kubeconfig
+	aksConvertKubeconfigCmd.Flags().StringVar(&convertKubeconfigPath, "kubeconfig", "", "Path to kubeconfig (default: KUBECONFIG or ~/.kube/config)")
+	aksConvertKubeconfigCmd.Flags().StringVar(&convertKubeloginMode, "kubelogin-mode", aks.ConvertModeAzureLogin, "Exec mode: azure-login, azurecli")
+}
+
+// loginBeforeCredentials performs the login of get-credentials --login. It is a
+// variable so tests can run the combined flow without a CI OIDC provider.
+var loginBeforeCredentials = runLogin
+
+// fetchClusterCredentials retrieves the cluster credentials with the cached token.
+// It is a variable so tests can run get-credentials without Azure.
+var fetchClusterCredentials = func(ctx context.Context, token *config.SavedToken, subscriptionID, resourceGroup, name string, admin bool) (*aks.ClusterCredentials, error) {
+	aksClient := aks.NewClient(subscriptionID, token.AccessToken)
+	aksClient.SetManagementURL(tokenCloud(token).ManagementEndpoint)
+	if admin {
+		return aksClient.GetClusterAdminCredentials(ctx, resourceGroup, name)
+	}
+	return aksClient.GetClusterCredentials(ctx, resourceGroup, name)
+}
+
+// listClusters lists the clusters in a subscription with the cached token.
+// It is a variable so tests can run aks list without Azure.
+var listClusters = func(ctx context.Context, token *config.SavedToken, subscriptionID string) ([]aks.ClusterSummary, error) {
+	aksClient := aks.NewClient(subscriptionID, token.AccessToken)
+	aksClient.SetManagementURL(tokenCloud(token).ManagementEndpoint)
+	return aksClient.ListClusters(ctx)
+}
+
+func runAKSList(cmd *cobra.Command, args []string) error {
+	token, err := config.NewConfig().LoadToken()
+	if err != nil {
+		return fmt.Errorf("not authenticated. Run 'azure-login login' first")
+	}
+	if token.SubscriptionID == "" {
+		return fmt.Errorf("no subscription configured. Run 'azure-login login' with --subscription-id")
+	}
+
+	clusters, err := listClusters(commandContext(cmd), token, token.SubscriptionID)
+	if err != nil {
+		return fmt.Errorf("failed to list clusters: %w", err)
+	}
+
+	return output.PrintWithOptions(clusters, outputFormat, queryString, informationalOutputOptions())
 }
 
 func runGetCredentials(cmd *cobra.Command, args []string) error {
-	// Load authentication token
 	cfg := config.NewConfig()
+
+	// --last replaces the flags and environment defaults with the recorded target
+	var lastSubscriptionID string
+	if aksLast {
+		if resourceGroup != "" || clusterName != "" {
+			return fmt.Errorf("--last cannot be combined with --resource-group or --name")
+		}
+		last, err := cfg.LoadLastCluster()
+		if errors.Is(err, config.ErrNoLastCluster) {
+			return fmt.Errorf("no previous cluster to reuse; run 'azure-login aks get-credentials --resource-group <RG> --name <CLUSTER>' first")
+		}
+		if err != nil {
+			return err
+		}
+		resourceGroup = last.ResourceGroup
+		clusterName = last.Name
+		lastSubscriptionID = last.SubscriptionID
+	}
+
+	// Apply environment variable defaults if flags not provided
+	// CLI flags take precedence over environment variables
+	if resourceGroup == "" {
+		resourceGroup = os.Getenv("AZURE_RESOURCE_GROUP")
+	}
+	if clusterName == "" {
+		clusterName = os.Getenv("AZURE_AKS_CLUSTER")
+	}
+	if clusterName == "" {
+		clusterName = os.Getenv("AKS_CLUSTER_NAME")
+	}
+
+	if resourceGroup == "" {
+		return fmt.Errorf("resource-group is required (or set AZURE_RESOURCE_GROUP)")
+	}
+	if clusterName == "" {
+		return fmt.Errorf("name is required (or set AZURE_AKS_CLUSTER or AKS_CLUSTER_NAME)")
+	}
+
+	execEnv, err := parseExecEnv(kubeloginEnv)
+	if err != nil {
+		return err
+	}
+	if aksLoginMethod == "" {
+		aksLoginMethod = aks.LoginMethodAzureLogin
+	}
+	if err := aks.ValidateLoginMethod(aksLoginMethod); err != nil {
+		return err
+	}
+	if aksAdmin && aksLoginMethod != aks.LoginMethodAzureLogin {
+		return fmt.Errorf("--login-method does not apply to --admin credentials, which use a client certificate")
+	}
+
+	// --login exchanges a single OIDC token and caches it for the credentials call below
+	if aksLogin {
+		if err := loginBeforeCredentials(cmd, args); err != nil {
+			return fmt.Errorf("login failed: %w", err)
+		}
+	}
+
+	// Load authentication token
 	token, err := cfg.LoadToken()
 	if err != nil {
 		return fmt.Errorf("not authenticated. Run 'azure-login login' first")
 	}
 
 	// Check if subscription ID is available
-	if token.SubscriptionID == "" {
+	subscription := token.SubscriptionID
+	if lastSubscriptionID != "" {
+		// kubectl-credential would reject every call of a kubeconfig for another subscription
+		if err := checkCredentialSubscription(token.SubscriptionID, lastSubscriptionID); err != nil {
+			return fmt.Errorf("cannot reuse the last cluster: %w", err)
+		}
+		subscription = lastSubscriptionID
+	}
+	if subscription == "" {
 		return fmt.Errorf("no subscription configured. Run 'azure-login login' with --subscription-id")
 	}
 
-	// Create AKS client
-	aksClient := aks.NewClient(token.SubscriptionID, token.AccessToken)
-
 	// Get cluster credentials
 	_, _ = fmt.Fprintf(os.Stderr, "Retrieving credentials for cluster %s in resource group %s...\n", clusterName, resourceGroup)
 
-	ctx := context.Background()
-	credentials, err := aksClient.GetClusterCredentials(ctx, resourceGroup, clusterName)
+	credentials, err := fetchClusterCredentials(commandContext(cmd), token, subscription, resourceGroup, clusterName, aksAdmin)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster credentials: %w", err)
 	}
+	if aksShowRateLimits {
+		if len(credentials.RateLimits) == 0 {
+			_, _ = fmt.Fprintf(os.Stderr, "Rate limits: not reported by Azure\n")
+		} else {
+			_, _ = fmt.Fprintf(os.Stderr, "Rate limits remaining: %s\n", credentials.RateLimits)
+		}
+	}
+	if aksPrivate {
+		if err := credentials.UsePrivateFQDN(); err != nil {
+			return err
+		}
+	}
+
+	// The exec user presents an Azure AD token, which only AAD-enabled clusters accept
+	if !aksAdmin && !credentials.AzureAD {
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: cluster %s does not appear to use Azure AD authentication; kubectl may reject the %s credentials\n", clusterName, aksLoginMethod)
+	}
+
+	// kubelogin's spn and workloadidentity methods authenticate as the logged-in identity
+	if credentials.TenantID == "" {
+		credentials.TenantID = token.TenantID
+	}
+	if credentials.ClientID == "" {
+		credentials.ClientID = token.ClientID
+	}
 
 	// Load kubeconfig
-	kubeconfigPath := aks.GetKubeconfigPath()
+	kubeconfigPath := aksKubeconfigFile
+	if kubeconfigPath == "" {
+		kubeconfigPath = aks.GetKubeconfigPath()
+	}
 	kubeconfig, err := aks.LoadKubeconfig(kubeconfigPath)
 	if err != nil {
 		return fmt.Errorf("failed to load kubeconfig: %w", err)
 	}
 
-	// Get the path to the current azure-login executable
-	execPath, err := os.Executable()
+	// Merge credentials into kubeconfig with the full path to azure-login
+	contextName, err := kubeconfig.MergeClusterCredentials(credentials, azureLoginExecPath(), aks.MergeOptions{
+		Namespace:          aksNamespace,
+		ExtraArgs:          kubeloginArgs,
+		ExtraEnv:           execEnv,
+		OverwriteExisting:  aksOverwriteExisting,
+		KeepCurrentContext: aksNoSetCurrent,
+		LoginMethod:        aksLoginMethod,
+		Profile:            credentialProfile(),
+	})
 	if err != nil {
-		// If we can't determine the executable path, fall back to just "azure-login"
-		// which will work if it's in PATH
-		execPath = "azure-login"
-	} else {
-		// Resolve any symlinks to get the real path
-		execPath, err = filepath.EvalSymlinks(execPath)
+		return err
+	}
+
+	if aksBackup {
+		backupPath, err := aks.BackupKubeconfig(kubeconfigPath)
 		if err != nil {
-			execPath = "azure-login"
+			return err
+		}
+		if backupPath != "" {
+			_, _ = fmt.Fprintf(os.Stderr, "Backed up %s to %s\n", kubeconfigPath, backupPath)
 		}
 	}
 
-	// Merge credentials into kubeconfig with the full path to azure-login
-	kubeconfig.MergeClusterCredentials(credentials, execPath)
-
 	// Save kubeconfig
 	if err := aks.SaveKubeconfig(kubeconfigPath, kubeconfig); err != nil {
 		return fmt.Errorf("failed to save kubeconfig: %w", err)
 	}
 
-	_, _ = fmt.Fprintf(os.Stderr, "Merged \"%s\" as current context in %s\n", clusterName, kubeconfigPath)
+	if aksNoSetCurrent {
+		_, _ = fmt.Fprintf(os.Stderr, "Merged \"%s\" in %s (current context unchanged)\n", contextName, kubeconfigPath)
+	} else {
+		_, _ = fmt.Fprintf(os.Stderr, "Merged \"%s\" as current context in %s\n", contextName, kubeconfigPath)
+	}
+
+	// Remember the target for --last; failing to do so does not fail the command
+	if err := cfg.SaveLastCluster(&config.LastCluster{
+		ResourceGroup:  resourceGroup,
+		Name:           clusterName,
+		SubscriptionID: subscription,
+	}); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	// Machine-readable result so pipelines need not parse the stderr message
+	if aksOutputFormat != "" || aksQueryString != "" {
+		format := aksOutputFormat
+		if format == "" {
+			format = "json"
+		}
+		result := map[string]any{
+			"context":    contextName,
+			"kubeconfig": kubeconfigPath,
+			"cluster":    clusterName,
+		}
+		return output.PrintWithOptions(result, format, aksQueryString, outputOptions())
+	}
+
+	return nil
+}
+
+func runConvertKubeconfig(cmd *cobra.Command, args []string) error {
+	kubeconfigPath := convertKubeconfigPath
+	if kubeconfigPath == "" {
+		kubeconfigPath = aks.GetKubeconfigPath()
+	}
+
+	kubeconfig, err := aks.LoadKubeconfig(kubeconfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	converted, err := kubeconfig.ConvertAzureAuthProviders(convertKubeloginMode, azureLoginExecPath())
+	if err != nil {
+		return err
+	}
+
+	if len(converted) == 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "No azure auth-provider users found in %s\n", kubeconfigPath)
+		return nil
+	}
+
+	if err := aks.SaveKubeconfig(kubeconfigPath, kubeconfig); err != nil {
+		return fmt.Errorf("failed to save kubeconfig: %w", err)
+	}
+
+	for _, name := range converted {
+		_, _ = fmt.Fprintf(os.Stderr, "Converted user \"%s\" to %s exec authentication\n", name, convertKubeloginMode)
+	}
 
 	return nil
 }
+
+// parseExecEnv parses NAME=VALUE pairs from --kubelogin-env
+func parseExecEnv(pairs []string) ([]aks.ExecEnvVar, error) {
+	var env []aks.ExecEnvVar
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --kubelogin-env %q: expected NAME=VALUE", pair)
+		}
+		env = append(env, aks.ExecEnvVar{Name: name, Value: value})
+	}
+	return env, nil
+}
+
+// credentialProfile returns the token profile kubectl-credential must use, or "" for
+// the default profile so its exec args stay unchanged
+func credentialProfile() string {
+	if profile := config.ActiveProfile(); profile != config.DefaultProfile {
+		return profile
+	}
+	return ""
+}
+
+// azureLoginExecPath returns the resolved path of the running azure-login binary
+func azureLoginExecPath() string {
+	execPath, err := os.Executable()
+	if err != nil {
+		// If we can't determine the executable path, fall back to just "azure-login"
+		// which will work if it's in PATH
+		return "azure-login"
+	}
+
+	// Resolve any symlinks to get the real path
+	execPath, err = filepath.EvalSymlinks(execPath)
+	if err != nil {
+		return "azure-login"
+	}
+	return execPath
+}
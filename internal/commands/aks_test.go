@@ -0,0 +1,580 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/aks"
+	"github.com/cogna-public/azure-login/internal/auth"
+	"github.com/cogna-public/azure-login/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+func TestGetCredentials_MissingResourceGroup(t *testing.T) {
+	_ = os.Unsetenv("AZURE_RESOURCE_GROUP")
+	_ = os.Unsetenv("AZURE_AKS_CLUSTER")
+
+	resourceGroup = ""
+	clusterName = "flag-cluster"
+	defer func() { clusterName = "" }()
+
+	err := runGetCredentials(nil, []string{})
+	if err == nil {
+		t.Fatal("Expected error for missing resource group, got none")
+	}
+	if err.Error() != "resource-group is required (or set AZURE_RESOURCE_GROUP)" {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+}
+
+func TestGetCredentials_MissingName(t *testing.T) {
+	_ = os.Unsetenv("AZURE_RESOURCE_GROUP")
+	_ = os.Unsetenv("AZURE_AKS_CLUSTER")
+	_ = os.Unsetenv("AKS_CLUSTER_NAME")
+
+	resourceGroup = "flag-rg"
+	clusterName = ""
+	defer func() { resourceGroup = "" }()
+
+	err := runGetCredentials(nil, []string{})
+	if err == nil {
+		t.Fatal("Expected error for missing cluster name, got none")
+	}
+	if err.Error() != "name is required (or set AZURE_AKS_CLUSTER or AKS_CLUSTER_NAME)" {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+}
+
+func TestGetCredentialsEnvVars_UsedWhenFlagsEmpty(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	_ = os.Setenv("AZURE_RESOURCE_GROUP", "env-rg")
+	_ = os.Setenv("AZURE_AKS_CLUSTER", "env-cluster")
+	defer func() {
+		_ = os.Unsetenv("AZURE_RESOURCE_GROUP")
+		_ = os.Unsetenv("AZURE_AKS_CLUSTER")
+	}()
+
+	resourceGroup = ""
+	clusterName = ""
+	defer func() {
+		resourceGroup = ""
+		clusterName = ""
+	}()
+
+	// Fails later (not authenticated), but values are resolved first
+	err := runGetCredentials(nil, []string{})
+	if err == nil {
+		t.Fatal("Expected error (not authenticated), got none")
+	}
+
+	if resourceGroup != "env-rg" {
+		t.Errorf("Expected resourceGroup 'env-rg', got '%s'", resourceGroup)
+	}
+	if clusterName != "env-cluster" {
+		t.Errorf("Expected clusterName 'env-cluster', got '%s'", clusterName)
+	}
+}
+
+func TestGetCredentialsEnvVars_AKSClusterName(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	t.Setenv("AZURE_RESOURCE_GROUP", "env-rg")
+	t.Setenv("AZURE_AKS_CLUSTER", "")
+	t.Setenv("AKS_CLUSTER_NAME", "alias-cluster")
+
+	resourceGroup = ""
+	clusterName = ""
+	defer func() {
+		resourceGroup = ""
+		clusterName = ""
+	}()
+
+	// Fails later (not authenticated), but values are resolved first
+	_ = runGetCredentials(nil, []string{})
+	if clusterName != "alias-cluster" {
+		t.Errorf("Expected clusterName 'alias-cluster' from AKS_CLUSTER_NAME, got '%s'", clusterName)
+	}
+
+	// AZURE_AKS_CLUSTER takes precedence over the alias
+	t.Setenv("AZURE_AKS_CLUSTER", "env-cluster")
+	clusterName = ""
+	_ = runGetCredentials(nil, []string{})
+	if clusterName != "env-cluster" {
+		t.Errorf("Expected clusterName 'env-cluster' from AZURE_AKS_CLUSTER, got '%s'", clusterName)
+	}
+}
+
+func TestGetCredentialsEnvVars_FlagsOverrideEnv(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	_ = os.Setenv("AZURE_RESOURCE_GROUP", "env-rg")
+	_ = os.Setenv("AZURE_AKS_CLUSTER", "env-cluster")
+	defer func() {
+		_ = os.Unsetenv("AZURE_RESOURCE_GROUP")
+		_ = os.Unsetenv("AZURE_AKS_CLUSTER")
+	}()
+
+	resourceGroup = "flag-rg"
+	clusterName = "flag-cluster"
+	defer func() {
+		resourceGroup = ""
+		clusterName = ""
+	}()
+
+	_ = runGetCredentials(nil, []string{})
+
+	if resourceGroup != "flag-rg" {
+		t.Errorf("Expected resourceGroup 'flag-rg', got '%s'", resourceGroup)
+	}
+	if clusterName != "flag-cluster" {
+		t.Errorf("Expected clusterName 'flag-cluster', got '%s'", clusterName)
+	}
+}
+
+func TestParseExecEnv(t *testing.T) {
+	env, err := parseExecEnv([]string{"AZURE_ENVIRONMENT=AzureChinaCloud", "EMPTY=", "WITH_EQUALS=a=b"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(env) != 3 {
+		t.Fatalf("Expected 3 env vars, got %d", len(env))
+	}
+	if env[0].Name != "AZURE_ENVIRONMENT" || env[0].Value != "AzureChinaCloud" {
+		t.Errorf("Unexpected first env var: %+v", env[0])
+	}
+	if env[1].Value != "" {
+		t.Errorf("Expected empty value, got %q", env[1].Value)
+	}
+	if env[2].Value != "a=b" {
+		t.Errorf("Expected value a=b, got %q", env[2].Value)
+	}
+
+	for _, invalid := range []string{"NO_EQUALS", "=value"} {
+		if _, err := parseExecEnv([]string{invalid}); err == nil {
+			t.Errorf("Expected error for %q, got none", invalid)
+		}
+	}
+}
+
+func TestGetCredentials_LastReusesPreviousTarget(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AZURE_RESOURCE_GROUP", "")
+	t.Setenv("AZURE_AKS_CLUSTER", "")
+
+	if err := config.NewConfig().SaveToken(&auth.TokenResponse{
+		AccessToken:    "test-token",
+		ExpiresOn:      time.Now().Add(time.Hour),
+		SubscriptionID: "login-sub",
+	}); err != nil {
+		t.Fatalf("Failed to save token: %v", err)
+	}
+
+	var requested []string
+	original := fetchClusterCredentials
+	fetchClusterCredentials = func(ctx context.Context, token *config.SavedToken, subscriptionID, rg, name string, admin bool) (*aks.ClusterCredentials, error) {
+		requested = append(requested, subscriptionID+"/"+rg+"/"+name)
+		return &aks.ClusterCredentials{
+			ClusterName:    name,
+			ServerURL:      "https://" + name + ".example.com",
+			CACertificate:  []byte("test-ca"),
+			ResourceGroup:  rg,
+			SubscriptionID: subscriptionID,
+			AzureAD:        true,
+		}, nil
+	}
+	defer func() { fetchClusterCredentials = original }()
+
+	// --last without a previous run fails clearly
+	aksLast = true
+	err := runGetCredentials(nil, []string{})
+	if err == nil || !strings.Contains(err.Error(), "no previous cluster") {
+		t.Fatalf("Expected no previous cluster error, got: %v", err)
+	}
+
+	aksLast = false
+	resourceGroup = "prod-rg"
+	clusterName = "prod-cluster"
+	if err := runGetCredentials(nil, []string{}); err != nil {
+		t.Fatalf("get-credentials failed: %v", err)
+	}
+
+	resourceGroup = ""
+	clusterName = ""
+	aksLast = true
+	defer func() {
+		aksLast = false
+		resourceGroup = ""
+		clusterName = ""
+	}()
+	if err := runGetCredentials(nil, []string{}); err != nil {
+		t.Fatalf("get-credentials --last failed: %v", err)
+	}
+
+	if len(requested) != 2 || requested[1] != "login-sub/prod-rg/prod-cluster" {
+		t.Errorf("Expected --last to re-fetch login-sub/prod-rg/prod-cluster, got %v", requested)
+	}
+
+	// Explicit targets are not silently replaced by the recorded one
+	resourceGroup = ""
+	clusterName = "other-cluster"
+	err = runGetCredentials(nil, []string{})
+	if err == nil || !strings.Contains(err.Error(), "--last cannot be combined") {
+		t.Errorf("Expected --last with --name to be rejected, got: %v", err)
+	}
+	clusterName = ""
+
+	// A login for another subscription would produce a kubeconfig kubectl-credential rejects
+	if err := config.NewConfig().SaveToken(&auth.TokenResponse{
+		AccessToken:    "test-token",
+		ExpiresOn:      time.Now().Add(time.Hour),
+		SubscriptionID: "other-sub",
+	}); err != nil {
+		t.Fatalf("Failed to save token: %v", err)
+	}
+	err = runGetCredentials(nil, []string{})
+	if err == nil || !strings.Contains(err.Error(), "cluster is in subscription login-sub but the cached login is for subscription other-sub") {
+		t.Errorf("Expected a subscription mismatch error, got: %v", err)
+	}
+	if len(requested) != 2 {
+		t.Errorf("Expected no credentials fetch on errors, got %v", requested)
+	}
+}
+
+func TestGetCredentials_Login(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	kubeconfigPath := filepath.Join(t.TempDir(), "config")
+	t.Setenv("KUBECONFIG", kubeconfigPath)
+	t.Setenv("AZURE_RESOURCE_GROUP", "")
+	t.Setenv("AZURE_AKS_CLUSTER", "")
+
+	// The mocked login caches a token as a successful OIDC exchange would
+	logins := 0
+	originalLogin := loginBeforeCredentials
+	loginBeforeCredentials = func(cmd *cobra.Command, args []string) error {
+		logins++
+		return config.NewConfig().SaveToken(&auth.TokenResponse{
+			AccessToken:    "fresh-login-token",
+			ExpiresOn:      time.Now().Add(time.Hour),
+			SubscriptionID: "login-sub",
+		})
+	}
+	defer func() { loginBeforeCredentials = originalLogin }()
+
+	var usedToken string
+	originalFetch := fetchClusterCredentials
+	fetchClusterCredentials = func(ctx context.Context, token *config.SavedToken, subscriptionID, rg, name string, admin bool) (*aks.ClusterCredentials, error) {
+		usedToken = token.AccessToken
+		return &aks.ClusterCredentials{
+			ClusterName:    name,
+			ServerURL:      "https://" + name + ".example.com",
+			CACertificate:  []byte("test-ca"),
+			ResourceGroup:  rg,
+			SubscriptionID: subscriptionID,
+			AzureAD:        true,
+		}, nil
+	}
+	defer func() { fetchClusterCredentials = originalFetch }()
+
+	aksLogin = true
+	resourceGroup = "prod-rg"
+	clusterName = "prod-cluster"
+	defer func() {
+		aksLogin = false
+		resourceGroup = ""
+		clusterName = ""
+	}()
+
+	if err := runGetCredentials(nil, []string{}); err != nil {
+		t.Fatalf("get-credentials --login failed: %v", err)
+	}
+	if logins != 1 {
+		t.Errorf("Expected a single login, got %d", logins)
+	}
+	if usedToken != "fresh-login-token" {
+		t.Errorf("Expected the credentials call to use the login token, got %q", usedToken)
+	}
+	kubeconfig, err := aks.LoadKubeconfig(kubeconfigPath)
+	if err != nil || kubeconfig.CurrentContext != "prod-cluster" {
+		t.Errorf("Expected prod-cluster to be merged as current context, got %+v (%v)", kubeconfig, err)
+	}
+
+	// A failed login stops before any credentials are requested
+	usedToken = ""
+	loginBeforeCredentials = func(cmd *cobra.Command, args []string) error {
+		return fmt.Errorf("client-id is required")
+	}
+	err = runGetCredentials(nil, []string{})
+	if err == nil || !strings.Contains(err.Error(), "login failed") {
+		t.Errorf("Expected login failure, got: %v", err)
+	}
+	if usedToken != "" {
+		t.Error("Expected no credentials request after a failed login")
+	}
+}
+
+func TestGetCredentials_Output(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	kubeconfigPath := filepath.Join(t.TempDir(), "config")
+	t.Setenv("KUBECONFIG", kubeconfigPath)
+
+	if err := config.NewConfig().SaveToken(&auth.TokenResponse{
+		AccessToken:    "test-token",
+		ExpiresOn:      time.Now().Add(time.Hour),
+		SubscriptionID: "login-sub",
+	}); err != nil {
+		t.Fatalf("Failed to save token: %v", err)
+	}
+
+	original := fetchClusterCredentials
+	fetchClusterCredentials = func(ctx context.Context, token *config.SavedToken, subscriptionID, rg, name string, admin bool) (*aks.ClusterCredentials, error) {
+		return &aks.ClusterCredentials{
+			ClusterName:       name,
+			ServerURL:         "https://" + name + ".example.com",
+			CACertificate:     []byte("test-ca"),
+			ResourceGroup:     rg,
+			SubscriptionID:    subscriptionID,
+			Admin:             admin,
+			ClientCertificate: []byte("cert"),
+			ClientKey:         []byte("key"),
+		}, nil
+	}
+	defer func() { fetchClusterCredentials = original }()
+
+	resourceGroup = "prod-rg"
+	clusterName = "prod-cluster"
+	aksAdmin = true
+	aksOutputFormat = "json"
+	defer func() {
+		resourceGroup = ""
+		clusterName = ""
+		aksAdmin = false
+		aksOutputFormat = ""
+	}()
+
+	var runErr error
+	out := captureStdout(t, func() { runErr = runGetCredentials(nil, []string{}) })
+	if runErr != nil {
+		t.Fatalf("get-credentials failed: %v", runErr)
+	}
+
+	var result map[string]string
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("Expected JSON on stdout, got %q: %v", out, err)
+	}
+	// The reported context is the one actually merged (admin contexts get a suffix)
+	if result["context"] != "prod-cluster-admin" || result["cluster"] != "prod-cluster" || result["kubeconfig"] != kubeconfigPath {
+		t.Errorf("Unexpected result: %v", result)
+	}
+}
+
+func TestRunAKSList(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	if err := config.NewConfig().SaveToken(&auth.TokenResponse{
+		AccessToken:    "test-token",
+		ExpiresOn:      time.Now().Add(time.Hour),
+		SubscriptionID: "login-sub",
+	}); err != nil {
+		t.Fatalf("Failed to save token: %v", err)
+	}
+
+	original := listClusters
+	listClusters = func(ctx context.Context, token *config.SavedToken, subscriptionID string) ([]aks.ClusterSummary, error) {
+		if subscriptionID != "login-sub" {
+			t.Errorf("Expected the login subscription, got %s", subscriptionID)
+		}
+		return []aks.ClusterSummary{
+			{Name: "prod", ResourceGroup: "prod-rg", Location: "eastus", PowerState: "Running"},
+		}, nil
+	}
+	defer func() { listClusters = original }()
+
+	outputFormat = "json"
+	queryString = ""
+
+	var runErr error
+	out := captureStdout(t, func() { runErr = runAKSList(nil, []string{}) })
+	if runErr != nil {
+		t.Fatalf("aks list failed: %v", runErr)
+	}
+
+	var clusters []map[string]any
+	if err := json.Unmarshal([]byte(out), &clusters); err != nil {
+		t.Fatalf("Failed to parse output %q: %v", out, err)
+	}
+	if len(clusters) != 1 || clusters[0]["name"] != "prod" || clusters[0]["resourceGroup"] != "prod-rg" || clusters[0]["location"] != "eastus" || clusters[0]["powerState"] != "Running" {
+		t.Errorf("Unexpected clusters %v", clusters)
+	}
+}
+
+func TestGetCredentials_LoginMethodValidation(t *testing.T) {
+	resourceGroup = "prod-rg"
+	clusterName = "prod-cluster"
+	defer func() {
+		resourceGroup = ""
+		clusterName = ""
+		aksLoginMethod = aks.LoginMethodAzureLogin
+		aksAdmin = false
+	}()
+
+	aksLoginMethod = "devicecode"
+	err := runGetCredentials(nil, []string{})
+	if err == nil || !strings.Contains(err.Error(), "unsupported login method") {
+		t.Errorf("Expected unsupported login method error, got: %v", err)
+	}
+
+	aksLoginMethod = aks.LoginMethodSPN
+	aksAdmin = true
+	err = runGetCredentials(nil, []string{})
+	if err == nil || !strings.Contains(err.Error(), "--admin") {
+		t.Errorf("Expected --login-method to be rejected with --admin, got: %v", err)
+	}
+}
+
+func TestGetCredentials_File(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	defaultPath := filepath.Join(t.TempDir(), "config")
+	t.Setenv("KUBECONFIG", defaultPath)
+
+	if err := config.NewConfig().SaveToken(&auth.TokenResponse{
+		AccessToken:    "test-token",
+		ExpiresOn:      time.Now().Add(time.Hour),
+		SubscriptionID: "login-sub",
+	}); err != nil {
+		t.Fatalf("Failed to save token: %v", err)
+	}
+
+	original := fetchClusterCredentials
+	fetchClusterCredentials = func(ctx context.Context, token *config.SavedToken, subscriptionID, rg, name string, admin bool) (*aks.ClusterCredentials, error) {
+		return &aks.ClusterCredentials{
+			ClusterName:    name,
+			ServerURL:      "https://" + name + ".example.com",
+			CACertificate:  []byte("test-ca"),
+			ResourceGroup:  rg,
+			SubscriptionID: subscriptionID,
+			AzureAD:        true,
+		}, nil
+	}
+	defer func() { fetchClusterCredentials = original }()
+
+	// The directory of the custom path is created on demand
+	customPath := filepath.Join(t.TempDir(), "nested", "dir", "prod.kubeconfig")
+	resourceGroup = "prod-rg"
+	clusterName = "prod-cluster"
+	aksKubeconfigFile = customPath
+	defer func() {
+		resourceGroup = ""
+		clusterName = ""
+		aksKubeconfigFile = ""
+	}()
+
+	if err := runGetCredentials(nil, []string{}); err != nil {
+		t.Fatalf("get-credentials --file failed: %v", err)
+	}
+
+	kubeconfig, err := aks.LoadKubeconfig(customPath)
+	if err != nil {
+		t.Fatalf("Failed to load custom kubeconfig: %v", err)
+	}
+	if kubeconfig.CurrentContext != "prod-cluster" {
+		t.Errorf("Expected prod-cluster context in %s, got %q", customPath, kubeconfig.CurrentContext)
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(customPath)
+		if err != nil {
+			t.Fatalf("Failed to stat custom kubeconfig: %v", err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("Expected custom kubeconfig mode 0600, got %o", info.Mode().Perm())
+		}
+	}
+	if _, err := os.Stat(defaultPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the default kubeconfig to be left untouched, got: %v", err)
+	}
+}
+
+func TestGetCredentials_ProfileInExecArgs(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	t.Setenv(config.ProfileEnvVar, "")
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "config"))
+
+	original := fetchClusterCredentials
+	fetchClusterCredentials = func(ctx context.Context, token *config.SavedToken, subscriptionID, rg, name string, admin bool) (*aks.ClusterCredentials, error) {
+		return &aks.ClusterCredentials{
+			ClusterName:    name,
+			ServerURL:      "https://" + name + ".example.com",
+			CACertificate:  []byte("test-ca"),
+			ResourceGroup:  rg,
+			SubscriptionID: subscriptionID,
+			AzureAD:        true,
+		}, nil
+	}
+	defer func() { fetchClusterCredentials = original }()
+
+	resourceGroup = "test-rg"
+	defer func() {
+		resourceGroup = ""
+		clusterName = ""
+		config.SetProfile("")
+	}()
+
+	tests := []struct {
+		profile      string
+		subscription string
+		cluster      string
+		args         []string
+	}{
+		{"", "default-sub", "default-cluster", []string{"kubectl-credential", "--subscription-id", "default-sub"}},
+		{"prod", "prod-sub", "prod-cluster", []string{"kubectl-credential", "--profile", "prod", "--subscription-id", "prod-sub"}},
+	}
+	for _, tt := range tests {
+		config.SetProfile(tt.profile)
+		if err := config.NewConfig().SaveToken(&auth.TokenResponse{
+			AccessToken:    "test-token",
+			ExpiresOn:      time.Now().Add(time.Hour),
+			SubscriptionID: tt.subscription,
+		}); err != nil {
+			t.Fatalf("Failed to save token: %v", err)
+		}
+
+		clusterName = tt.cluster
+		if err := runGetCredentials(nil, []string{}); err != nil {
+			t.Fatalf("get-credentials for profile %q failed: %v", tt.profile, err)
+		}
+	}
+
+	kubeconfig, err := aks.LoadKubeconfig(os.Getenv("KUBECONFIG"))
+	if err != nil {
+		t.Fatalf("Failed to load kubeconfig: %v", err)
+	}
+	for _, tt := range tests {
+		var args []string
+		for _, user := range kubeconfig.Users {
+			if strings.Contains(user.Name, tt.cluster) {
+				args = user.User.Exec.Args
+			}
+		}
+		// kubectl must exchange the identity of the profile the credentials were fetched with
+		if strings.Join(args, " ") != strings.Join(tt.args, " ") {
+			t.Errorf("Expected exec args %v for profile %q, got %v", tt.args, tt.profile, args)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// loginConfigFile holds non-secret login defaults loaded from a JSON config file.
+//
+// Precedence (highest first): CLI flags, environment variables, config file.
+type loginConfigFile struct {
+	ClientID       string `json:"clientId"`
+	TenantID       string `json:"tenantId"`
+	SubscriptionID string `json:"subscriptionId"`
+	Scope          string `json:"scope"`
+	Cloud          string `json:"cloud"`
+	// Audience is the OIDC token audience for federated credentials with a custom one
+	Audience string `json:"audience"`
+}
+
+// loadLoginConfigFile reads and parses a login config file
+func loadLoginConfigFile(path string) (*loginConfigFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	// Reject unknown keys so typos (e.g. "client_id") don't silently fall through
+	var cfg loginConfigFile
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return &cfg, nil
+}
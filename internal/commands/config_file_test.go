@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "azure-login.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadLoginConfigFile_Success(t *testing.T) {
+	path := writeTestConfigFile(t, `{
+		"clientId": "file-client",
+		"tenantId": "file-tenant",
+		"subscriptionId": "file-subscription",
+		"cloud": "AzureCloud",
+		"audience": "api://AzureADTokenExchange",
+		"scope": "https://management.azure.com/.default"
+	}`)
+
+	cfg, err := loadLoginConfigFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load config file: %v", err)
+	}
+	if cfg.ClientID != "file-client" {
+		t.Errorf("Expected ClientID 'file-client', got '%s'", cfg.ClientID)
+	}
+	if cfg.TenantID != "file-tenant" {
+		t.Errorf("Expected TenantID 'file-tenant', got '%s'", cfg.TenantID)
+	}
+	if cfg.SubscriptionID != "file-subscription" {
+		t.Errorf("Expected SubscriptionID 'file-subscription', got '%s'", cfg.SubscriptionID)
+	}
+	if cfg.Scope != "https://management.azure.com/.default" {
+		t.Errorf("Expected Scope to be set, got '%s'", cfg.Scope)
+	}
+}
+
+func TestLoadLoginConfigFile_UnknownField(t *testing.T) {
+	path := writeTestConfigFile(t, `{"client_id": "typo"}`)
+
+	_, err := loadLoginConfigFile(path)
+	if err == nil {
+		t.Fatal("Expected error for unknown field, got none")
+	}
+	if !strings.Contains(err.Error(), "failed to parse config file") {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+}
+
+func TestLoadLoginConfigFile_NotFound(t *testing.T) {
+	_, err := loadLoginConfigFile(filepath.Join(t.TempDir(), "missing.json"))
+	if err == nil {
+		t.Fatal("Expected error for missing config file, got none")
+	}
+}
+
+func TestLoginConfigFilePrecedence_FlagOverridesFile(t *testing.T) {
+	_ = os.Unsetenv("AZURE_CLIENT_ID")
+	_ = os.Unsetenv("AZURE_TENANT_ID")
+	_ = os.Unsetenv("AZURE_SUBSCRIPTION_ID")
+
+	loginConfigPath = writeTestConfigFile(t, `{
+		"clientId": "file-client",
+		"tenantId": "file-tenant",
+		"subscriptionId": "file-subscription"
+	}`)
+	clientID = "flag-client"
+	tenantID = ""
+	subscriptionID = ""
+	allowNoSubscription = false
+	defer func() {
+		loginConfigPath = ""
+		clientID = ""
+		tenantID = ""
+		subscriptionID = ""
+	}()
+
+	// Will fail validation, but values are resolved first
+	err := runLogin(nil, []string{})
+	if err == nil {
+		t.Fatal("Expected error (invalid client-id), got none")
+	}
+
+	if clientID != "flag-client" {
+		t.Errorf("Expected clientID 'flag-client' (from flag), got '%s'", clientID)
+	}
+	if tenantID != "file-tenant" {
+		t.Errorf("Expected tenantID 'file-tenant' (from config file), got '%s'", tenantID)
+	}
+	if subscriptionID != "file-subscription" {
+		t.Errorf("Expected subscriptionID 'file-subscription' (from config file), got '%s'", subscriptionID)
+	}
+}
+
+func TestLoginConfigFilePrecedence_EnvOverridesFile(t *testing.T) {
+	_ = os.Unsetenv("AZURE_CLIENT_ID")
+	_ = os.Setenv("AZURE_TENANT_ID", "env-tenant")
+	_ = os.Unsetenv("AZURE_SUBSCRIPTION_ID")
+	defer func() { _ = os.Unsetenv("AZURE_TENANT_ID") }()
+
+	loginConfigPath = writeTestConfigFile(t, `{"clientId": "file-client", "tenantId": "file-tenant"}`)
+	clientID = ""
+	tenantID = ""
+	subscriptionID = ""
+	allowNoSubscription = false
+	defer func() {
+		loginConfigPath = ""
+		clientID = ""
+		tenantID = ""
+	}()
+
+	_ = runLogin(nil, []string{})
+
+	if clientID != "file-client" {
+		t.Errorf("Expected clientID 'file-client' (from config file), got '%s'", clientID)
+	}
+	if tenantID != "env-tenant" {
+		t.Errorf("Expected tenantID 'env-tenant' (from env), got '%s'", tenantID)
+	}
+}
@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/aks"
+	"github.com/cogna-public/azure-login/internal/auth"
+	"github.com/cogna-public/azure-login/internal/output"
+	"github.com/cogna-public/azure-login/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// Doctor check statuses
+const (
+	checkStatusPass = "pass"
+	checkStatusWarn = "warn"
+	checkStatusFail = "fail"
+)
+
+var (
+	doctorOutputFormat string
+	doctorQueryString  string
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the azure-login environment",
+	Long: `Run a series of checks against the current environment (OIDC variables,
+cached token, subscription and kubeconfig) and report the result of each.
+
+By default a human-readable checklist is printed. Use --output json to emit a
+structured report suitable for gating CI pipelines. The command exits with a
+non-zero status if any check fails.`,
+	RunE: runDoctor,
+}
+
+func init() {
+	doctorCmd.Flags().StringVarP(&doctorOutputFormat, "output", "o", "", "Output format: json, ndjson, yaml, tsv, table, detail, value, exitcode (default: human-readable checklist)")
+	doctorCmd.Flags().StringVar(&doctorQueryString, "query", "", "JMESPath query string")
+	doctorCmd.Flags().DurationVar(&expiryThreshold, "expiry-threshold", tokenExpirationBuffer, "Minimum remaining token validity for the token-valid check (e.g. 20m)")
+	doctorCmd.Flags().BoolVar(&strictQuery, "strict-query", false, "Fail if the query returns no result")
+	doctorCmd.Flags().BoolVar(&showSecrets, "show-secrets", false, "Show sensitive fields instead of redacting them")
+}
+
+// doctorCheck is the result of a single diagnostic check
+type doctorCheck struct {
+	Name   string
+	Status string
+	Detail string
+}
+
+// doctorReport is the overall result of all diagnostic checks
+type doctorReport struct {
+	Checks []doctorCheck
+	OK     bool
+}
+
+// toMap converts the report to the structure used for machine-readable output
+func (r *doctorReport) toMap() map[string]any {
+	checks := make([]any, 0, len(r.Checks))
+	for _, check := range r.Checks {
+		checks = append(checks, map[string]any{
+			"name":   check.Name,
+			"status": check.Status,
+			"detail": check.Detail,
+		})
+	}
+	return map[string]any{
+		"checks": checks,
+		"ok":     r.OK,
+	}
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	if expiryThreshold < 0 {
+		return fmt.Errorf("--expiry-threshold must not be negative")
+	}
+
+	report := collectDoctorChecks()
+
+	if doctorOutputFormat == "" {
+		for _, check := range report.Checks {
+			fmt.Printf("[%s] %s: %s\n", check.Status, check.Name, check.Detail)
+		}
+	} else if err := output.PrintWithOptions(report.toMap(), doctorOutputFormat, doctorQueryString, informationalOutputOptions()); err != nil {
+		return err
+	}
+
+	if !report.OK {
+		return fmt.Errorf("one or more doctor checks failed")
+	}
+	return nil
+}
+
+// collectDoctorChecks runs all diagnostic checks and returns the report
+func collectDoctorChecks() *doctorReport {
+	report := &doctorReport{OK: true}
+	add := func(name, status, detail string) {
+		report.Checks = append(report.Checks, doctorCheck{Name: name, Status: status, Detail: detail})
+		if status == checkStatusFail {
+			report.OK = false
+		}
+	}
+
+	// CI OIDC environment (only needed for login and kubectl-credential)
+	switch provider := auth.DetectOIDCProvider().(type) {
+	case auth.GitLabProvider:
+		if os.Getenv(provider.EnvVar) != "" {
+			add("oidc-environment", checkStatusPass, fmt.Sprintf("GitLab CI ID token %s is set", provider.EnvVar))
+		} else {
+			add("oidc-environment", checkStatusWarn, fmt.Sprintf("GitLab CI detected but %s is not set (declare it under id_tokens)", provider.EnvVar))
+		}
+	case auth.AzureDevOpsProvider:
+		var missing []string
+		for _, name := range []string{auth.AzureDevOpsOIDCRequestURIEnvVar, auth.AzureDevOpsAccessTokenEnvVar, auth.AzureDevOpsServiceConnectionEnvVar} {
+			if os.Getenv(name) == "" {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) == 0 {
+			add("oidc-environment", checkStatusPass, "Azure DevOps OIDC variables are set")
+		} else {
+			add("oidc-environment", checkStatusWarn, fmt.Sprintf("Azure Pipelines detected but %s not set (map SYSTEM_ACCESSTOKEN and use a workload identity service connection)", strings.Join(missing, "/")))
+		}
+	default:
+		if requestToken, requestURL := auth.LookupOIDCRequestEnv(); requestToken != "" && requestURL != "" {
+			add("oidc-environment", checkStatusPass, "GitHub Actions OIDC variables are set")
+		} else {
+			add("oidc-environment", checkStatusWarn, "ACTIONS_ID_TOKEN_REQUEST_TOKEN/ACTIONS_ID_TOKEN_REQUEST_URL not set (login will not work outside GitHub Actions)")
+		}
+	}
+
+	// Cached token
+	cfg := config.NewConfig()
+	token, err := cfg.LoadToken()
+	if err != nil {
+		add("token-cache", checkStatusFail, err.Error())
+	} else {
+		add("token-cache", checkStatusPass, "cached token loaded")
+
+		remaining := time.Until(token.ExpiresOn)
+		if tokenExpiresWithin(token.ExpiresOn, expiryThreshold) {
+			add("token-valid", checkStatusFail, fmt.Sprintf("token expires within %s. Run 'azure-login login'", formatDuration(expiryThreshold)))
+		} else {
+			add("token-valid", checkStatusPass, fmt.Sprintf("token valid for %s", formatDuration(remaining.Round(time.Second))))
+		}
+
+		if token.SubscriptionID == "" {
+			add("subscription", checkStatusWarn, "no subscription configured (aks commands will not work)")
+		} else {
+			add("subscription", checkStatusPass, token.SubscriptionID)
+		}
+	}
+
+	// Kubeconfig
+	kubeconfigPath := aks.GetKubeconfigPath()
+	if _, err := aks.LoadKubeconfig(kubeconfigPath); err != nil {
+		add("kubeconfig", checkStatusFail, err.Error())
+	} else {
+		add("kubeconfig", checkStatusPass, kubeconfigPath)
+	}
+
+	return report
+}
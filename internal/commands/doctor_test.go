@@ -0,0 +1,295 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/auth"
+	"github.com/cogna-public/azure-login/internal/output"
+	"github.com/cogna-public/azure-login/pkg/config"
+)
+
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	old := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+
+	f()
+
+	_ = w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+	return buf.String()
+}
+
+func TestDoctor_JSONReportStructure(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	_ = os.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "config"))
+	defer func() { _ = os.Unsetenv("KUBECONFIG") }()
+
+	cfg := config.NewConfig()
+	testToken := &auth.TokenResponse{
+		AccessToken:    "test-token",
+		TokenType:      "Bearer",
+		ExpiresOn:      time.Now().Add(1 * time.Hour),
+		TenantID:       "test-tenant",
+		ClientID:       "test-client",
+		SubscriptionID: "test-subscription",
+	}
+	if err := cfg.SaveToken(testToken); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	doctorOutputFormat = "json"
+	doctorQueryString = ""
+	defer func() { doctorOutputFormat = "" }()
+
+	var runErr error
+	out := captureStdout(t, func() {
+		runErr = doctorCmd.RunE(doctorCmd, []string{})
+	})
+	if runErr != nil {
+		t.Fatalf("Expected doctor to pass, got: %v", runErr)
+	}
+
+	var report struct {
+		Checks []struct {
+			Name   string `json:"name"`
+			Status string `json:"status"`
+			Detail string `json:"detail"`
+		} `json:"checks"`
+		OK bool `json:"ok"`
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("Failed to parse doctor JSON output: %v\n%s", err, out)
+	}
+	if !report.OK {
+		t.Error("Expected ok to be true")
+	}
+	if len(report.Checks) == 0 {
+		t.Fatal("Expected at least one check")
+	}
+	for _, check := range report.Checks {
+		if check.Name == "" || check.Status == "" {
+			t.Errorf("Expected name and status to be set, got %+v", check)
+		}
+	}
+}
+
+func TestDoctor_FailingCheck(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	_ = os.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "config"))
+	defer func() { _ = os.Unsetenv("KUBECONFIG") }()
+
+	// No cached token, so the token-cache check fails
+	doctorOutputFormat = "json"
+	doctorQueryString = ""
+	defer func() { doctorOutputFormat = "" }()
+
+	var runErr error
+	out := captureStdout(t, func() {
+		runErr = doctorCmd.RunE(doctorCmd, []string{})
+	})
+	if runErr == nil {
+		t.Fatal("Expected error (non-zero exit) for failing check, got none")
+	}
+
+	var report struct {
+		Checks []struct {
+			Name   string `json:"name"`
+			Status string `json:"status"`
+		} `json:"checks"`
+		OK bool `json:"ok"`
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("Failed to parse doctor JSON output: %v\n%s", err, out)
+	}
+	if report.OK {
+		t.Error("Expected ok to be false")
+	}
+
+	found := false
+	for _, check := range report.Checks {
+		if check.Name == "token-cache" {
+			found = true
+			if check.Status != checkStatusFail {
+				t.Errorf("Expected token-cache status fail, got %s", check.Status)
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected token-cache check in report")
+	}
+}
+
+func TestDoctor_ExpiryThreshold(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	_ = os.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "config"))
+	defer func() { _ = os.Unsetenv("KUBECONFIG") }()
+
+	expiryThreshold = 20 * time.Minute
+	doctorOutputFormat = "json"
+	doctorQueryString = ""
+	defer func() {
+		expiryThreshold = tokenExpirationBuffer
+		doctorOutputFormat = ""
+	}()
+
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		wantErr   bool
+	}{
+		{name: "Just above threshold", expiresIn: 21 * time.Minute, wantErr: false},
+		{name: "Just below threshold", expiresIn: 19 * time.Minute, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewConfig()
+			testToken := &auth.TokenResponse{
+				AccessToken:    "test-token",
+				TokenType:      "Bearer",
+				ExpiresOn:      time.Now().Add(tt.expiresIn),
+				SubscriptionID: "test-subscription",
+			}
+			if err := cfg.SaveToken(testToken); err != nil {
+				t.Fatalf("Failed to save test token: %v", err)
+			}
+
+			var runErr error
+			_ = captureStdout(t, func() {
+				runErr = doctorCmd.RunE(doctorCmd, []string{})
+			})
+			if (runErr != nil) != tt.wantErr {
+				t.Errorf("Expected error=%v, got %v", tt.wantErr, runErr)
+			}
+		})
+	}
+}
+
+func TestDoctor_NegativeExpiryThreshold(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "config"))
+
+	doctorOutputFormat = "json"
+	doctorQueryString = ""
+	defer func() {
+		expiryThreshold = tokenExpirationBuffer
+		doctorOutputFormat = ""
+	}()
+
+	if err := config.NewConfig().SaveToken(&auth.TokenResponse{
+		AccessToken:    "test-token",
+		ExpiresOn:      time.Now().Add(1 * time.Hour),
+		SubscriptionID: "test-subscription",
+	}); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		wantErr   bool
+	}{
+		{name: "Zero", threshold: 0, wantErr: false},
+		{name: "Negative", threshold: -time.Nanosecond, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expiryThreshold = tt.threshold
+			var runErr error
+			_ = captureStdout(t, func() {
+				runErr = doctorCmd.RunE(doctorCmd, []string{})
+			})
+			if tt.wantErr {
+				if runErr == nil || !strings.Contains(runErr.Error(), "--expiry-threshold must not be negative") {
+					t.Errorf("Expected negative threshold error, got %v", runErr)
+				}
+			} else if runErr != nil {
+				t.Errorf("Expected no error, got %v", runErr)
+			}
+		})
+	}
+}
+
+func TestDoctor_DurationFormatISO8601(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "config"))
+
+	expiryThreshold = 20 * time.Minute
+	durationFormat = output.DurationISO8601
+	doctorOutputFormat = "json"
+	doctorQueryString = "checks[?name=='token-valid'].detail | [0]"
+	defer func() {
+		expiryThreshold = tokenExpirationBuffer
+		durationFormat = output.DurationHuman
+		doctorOutputFormat = ""
+		doctorQueryString = ""
+	}()
+
+	if err := config.NewConfig().SaveToken(&auth.TokenResponse{
+		AccessToken: "test-token",
+		ExpiresOn:   time.Now().Add(10 * time.Minute),
+	}); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	out := captureStdout(t, func() {
+		_ = doctorCmd.RunE(doctorCmd, []string{})
+	})
+	// The failing check uses the selected format too, not Go's 20m0s
+	if !strings.Contains(out, "token expires within PT20M") {
+		t.Errorf("Expected the threshold in ISO 8601 form, got %q", out)
+	}
+}
+
+func TestDoctor_OIDCEnvironmentAzureDevOps(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("GITLAB_CI", "")
+	t.Setenv(auth.AzureDevOpsCollectionURIEnvVar, "https://dev.azure.com/contoso/")
+	t.Setenv(auth.AzureDevOpsOIDCRequestURIEnvVar, "https://dev.azure.com/contoso/_apis/oidc")
+	t.Setenv(auth.AzureDevOpsAccessTokenEnvVar, "job-token")
+	t.Setenv(auth.AzureDevOpsServiceConnectionEnvVar, "connection-id")
+
+	oidcCheck := func() doctorCheck {
+		t.Helper()
+		for _, check := range collectDoctorChecks().Checks {
+			if check.Name == "oidc-environment" {
+				return check
+			}
+		}
+		t.Fatal("Expected an oidc-environment check")
+		return doctorCheck{}
+	}
+
+	if check := oidcCheck(); check.Status != checkStatusPass || !strings.Contains(check.Detail, "Azure DevOps") {
+		t.Errorf("Expected the Azure DevOps variables to pass, got %+v", check)
+	}
+
+	t.Setenv(auth.AzureDevOpsAccessTokenEnvVar, "")
+	check := oidcCheck()
+	if check.Status != checkStatusWarn || !strings.Contains(check.Detail, auth.AzureDevOpsAccessTokenEnvVar) || strings.Contains(check.Detail, "GitHub Actions") {
+		t.Errorf("Expected a warning naming the missing Azure DevOps variable, got %+v", check)
+	}
+}
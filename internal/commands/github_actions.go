@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// githubActionsMode enables GitHub Actions workflow command output (--github-actions)
+var githubActionsMode bool
+
+// emitGitHubActionsCommands masks the access token in the workflow log and, when
+// GITHUB_OUTPUT is set, exposes the token and its expiry as step outputs.
+//
+// Workflow commands are written to w (stderr in practice) so they never mix with
+// data written to stdout; the runner processes commands from both streams.
+func emitGitHubActionsCommands(w io.Writer, accessToken string, expiresOn time.Time) error {
+	_, _ = fmt.Fprintf(w, "::add-mask::%s\n", accessToken)
+
+	outputPath := os.Getenv("GITHUB_OUTPUT")
+	if outputPath == "" {
+		return nil
+	}
+
+	file, err := os.OpenFile(outputPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open GITHUB_OUTPUT file: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	if _, err := fmt.Fprintf(file, "access_token=%s\nexpires_on=%s\n", accessToken, expiresOn.UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to write GITHUB_OUTPUT file: %w", err)
+	}
+
+	return nil
+}
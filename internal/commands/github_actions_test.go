@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/auth"
+	"github.com/cogna-public/azure-login/pkg/config"
+)
+
+func TestEmitGitHubActionsCommands_MaskOnly(t *testing.T) {
+	_ = os.Unsetenv("GITHUB_OUTPUT")
+
+	var buf bytes.Buffer
+	err := emitGitHubActionsCommands(&buf, "secret-token", time.Now().Add(1*time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if buf.String() != "::add-mask::secret-token\n" {
+		t.Errorf("Expected mask command, got %q", buf.String())
+	}
+}
+
+func TestEmitGitHubActionsCommands_WritesOutputFile(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "github_output")
+	// Existing outputs from earlier steps must be preserved
+	if err := os.WriteFile(outputPath, []byte("existing=value\n"), 0600); err != nil {
+		t.Fatalf("Failed to write output file: %v", err)
+	}
+	_ = os.Setenv("GITHUB_OUTPUT", outputPath)
+	defer func() { _ = os.Unsetenv("GITHUB_OUTPUT") }()
+
+	expiresOn := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	var buf bytes.Buffer
+	if err := emitGitHubActionsCommands(&buf, "secret-token", expiresOn); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "::add-mask::secret-token") {
+		t.Errorf("Expected mask command, got %q", buf.String())
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	expected := "existing=value\naccess_token=secret-token\nexpires_on=2030-01-02T03:04:05Z\n"
+	if string(data) != expected {
+		t.Errorf("Expected output file %q, got %q", expected, string(data))
+	}
+}
+
+func TestRunGetAccessToken_GitHubActionsMode(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	outputPath := filepath.Join(t.TempDir(), "github_output")
+	_ = os.Setenv("GITHUB_OUTPUT", outputPath)
+	defer func() { _ = os.Unsetenv("GITHUB_OUTPUT") }()
+
+	cfg := config.NewConfig()
+	testToken := &auth.TokenResponse{
+		AccessToken:    "test-access-token",
+		TokenType:      "Bearer",
+		ExpiresOn:      time.Now().Add(1 * time.Hour),
+		TenantID:       "test-tenant",
+		ClientID:       "test-client",
+		SubscriptionID: "test-subscription",
+	}
+	if err := cfg.SaveToken(testToken); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	outputFormat = "json"
+	queryString = ""
+	githubActionsMode = true
+	defer func() { githubActionsMode = false }()
+
+	if err := accountGetAccessTokenCmd.RunE(accountGetAccessTokenCmd, []string{}); err != nil {
+		t.Fatalf("get-access-token failed: %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	if !strings.Contains(string(data), "access_token=test-access-token\n") {
+		t.Errorf("Expected access_token in GITHUB_OUTPUT, got %q", string(data))
+	}
+	if !strings.Contains(string(data), "expires_on=") {
+		t.Errorf("Expected expires_on in GITHUB_OUTPUT, got %q", string(data))
+	}
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+)
+
+// defaultSecretKey is the data key holding the token in --as-k8s-secret manifests
+const defaultSecretKey = "token"
+
+var (
+	// secretNamePattern matches a Kubernetes object name (RFC 1123 subdomain)
+	secretNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+	// namespacePattern matches a Kubernetes namespace name (RFC 1123 label)
+	namespacePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+	// secretKeyPattern matches a valid key of a Secret's data
+	secretKeyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+)
+
+// validateKubernetesSecret checks the name, namespace and data key of a Secret manifest
+func validateKubernetesSecret(name, namespace, key string) error {
+	if name == "" {
+		return fmt.Errorf("--as-k8s-secret requires --secret-name")
+	}
+	if len(name) > 253 || !secretNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid --secret-name %q: must be lowercase alphanumeric, '-' or '.'", name)
+	}
+	if namespace != "" && (len(namespace) > 63 || !namespacePattern.MatchString(namespace)) {
+		return fmt.Errorf("invalid --namespace %q: must be lowercase alphanumeric or '-'", namespace)
+	}
+	if len(key) > 253 || !secretKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid --secret-key %q: must be alphanumeric, '-', '_' or '.'", key)
+	}
+	return nil
+}
+
+// kubernetesSecret builds an Opaque Secret manifest holding the token base64-encoded
+// under key, for GitOps and CD pipelines that consume tokens as Secrets
+func kubernetesSecret(name, namespace, key, token string) map[string]any {
+	metadata := map[string]any{"name": name}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	return map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   metadata,
+		"type":       "Opaque",
+		"data": map[string]any{
+			key: base64.StdEncoding.EncodeToString([]byte(token)),
+		},
+	}
+}
@@ -5,9 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
-	"github.com/cogna-public/azure-login/internal/auth"
+	"github.com/cogna-public/azure-login/internal/aks"
 	"github.com/cogna-public/azure-login/pkg/config"
 	"github.com/spf13/cobra"
 )
@@ -16,12 +17,32 @@ var kubectlCredentialCmd = &cobra.Command{
 	Use:    "kubectl-credential",
 	Hidden: true, // Hidden from help output
 	Short:  "Output credentials in kubectl ExecCredential format",
-	Long:   `Output Azure credentials in kubectl ExecCredential format for use as an exec credential plugin.`,
-	RunE:   runKubectlCredential,
+	Long: `Output Azure credentials in kubectl ExecCredential format for use as an exec credential plugin.
+
+The AKS-scoped token itself is not tied to a subscription, but the cached login is:
+a login for another subscription typically uses a different identity that lacks
+access to the cluster. When --subscription-id is set (aks get-credentials writes it
+into the kubeconfig), a mismatch with the cached login is reported as an error
+instead of surfacing later as an opaque authorization failure from the API server.
+
+The expiration reported to kubectl is --refresh-skew (default 2m) earlier than the
+token's real expiry, so kubectl re-invokes the plugin before the token can expire
+mid-request.`,
+	RunE: runKubectlCredential,
 }
 
+// defaultRefreshSkew is how much earlier than the real expiry kubectl is told the token expires
+const defaultRefreshSkew = 2 * time.Minute
+
+var (
+	kubectlSubscriptionID string
+	kubectlRefreshSkew    = defaultRefreshSkew
+)
+
 func init() {
 	// This command is for internal use by kubectl
+	kubectlCredentialCmd.Flags().StringVar(&kubectlSubscriptionID, "subscription-id", "", "Subscription ID of the cluster; must match the cached login")
+	kubectlCredentialCmd.Flags().DurationVar(&kubectlRefreshSkew, "refresh-skew", defaultRefreshSkew, "Report the token as expiring this much earlier so kubectl refreshes it in time")
 }
 
 // ExecCredential is the credential format expected by kubectl
@@ -38,6 +59,10 @@ type ExecCredentialStatus struct {
 }
 
 func runKubectlCredential(cmd *cobra.Command, args []string) error {
+	if kubectlRefreshSkew < 0 {
+		return fmt.Errorf("--refresh-skew must not be negative")
+	}
+
 	// Load saved authentication details
 	cfg := config.NewConfig()
 	savedToken, err := cfg.LoadToken()
@@ -45,25 +70,18 @@ func runKubectlCredential(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("not authenticated. Run 'azure-login login' first")
 	}
 
-	// Get OIDC token from GitHub Actions
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	oidcToken, err := auth.GetGitHubOIDCToken(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get OIDC token: %w", err)
+	if err := checkCredentialSubscription(savedToken.SubscriptionID, kubectlSubscriptionID); err != nil {
+		return err
 	}
 
-	// Exchange OIDC token for Kubernetes-scoped access token
-	// Azure Kubernetes Service AAD Server application ID
-	client := auth.NewClientWithScope(
-		savedToken.TenantID,
-		savedToken.ClientID,
-		savedToken.SubscriptionID,
-		"6dae42f8-4368-4678-94ff-3960e28e3630/.default", // AKS server scope
-	)
+	// Re-acquire the token for the AKS server scope with the login's credential
+	// (OIDC or certificate), authority tenant and cloud
+	ctx, cancel := context.WithTimeout(commandContext(cmd), 30*time.Second)
+	defer cancel()
 
-	kubeToken, err := client.ExchangeOIDCToken(ctx, oidcToken)
+	identity := *savedToken
+	identity.Scope = aks.AKSServerID + "/.default"
+	kubeToken, err := refreshAccessToken(ctx, &identity)
 	if err != nil {
 		return fmt.Errorf("failed to exchange token for Kubernetes scope: %w", err)
 	}
@@ -74,7 +92,7 @@ func runKubectlCredential(cmd *cobra.Command, args []string) error {
 		Kind:       "ExecCredential",
 		Status: ExecCredentialStatus{
 			Token:               kubeToken.AccessToken,
-			ExpirationTimestamp: kubeToken.ExpiresOn.Format("2006-01-02T15:04:05Z"),
+			ExpirationTimestamp: execCredentialExpiration(kubeToken.ExpiresOn, kubectlRefreshSkew),
 		},
 	}
 
@@ -86,3 +104,21 @@ func runKubectlCredential(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// execCredentialExpiration returns the expiration timestamp reported to kubectl,
+// skew earlier than the token's actual expiry
+func execCredentialExpiration(expiresOn time.Time, skew time.Duration) string {
+	return expiresOn.Add(-skew).UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// checkCredentialSubscription verifies that the cached login matches the cluster's subscription.
+// An empty cluster subscription (kubeconfigs written before this check existed) is accepted.
+func checkCredentialSubscription(savedSubscriptionID, clusterSubscriptionID string) error {
+	if clusterSubscriptionID == "" || strings.EqualFold(savedSubscriptionID, clusterSubscriptionID) {
+		return nil
+	}
+	if savedSubscriptionID == "" {
+		return fmt.Errorf("cluster is in subscription %s but the cached login has no subscription. Run 'azure-login login --subscription-id %s'", clusterSubscriptionID, clusterSubscriptionID)
+	}
+	return fmt.Errorf("cluster is in subscription %s but the cached login is for subscription %s. Run 'azure-login login --subscription-id %s'", clusterSubscriptionID, savedSubscriptionID, clusterSubscriptionID)
+}
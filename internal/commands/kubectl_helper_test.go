@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/aks"
+	"github.com/cogna-public/azure-login/internal/auth"
+	"github.com/cogna-public/azure-login/pkg/config"
+)
+
+func TestCheckCredentialSubscription(t *testing.T) {
+	tests := []struct {
+		name    string
+		saved   string
+		cluster string
+		wantErr bool
+	}{
+		{name: "No cluster subscription recorded", saved: "sub-a", cluster: "", wantErr: false},
+		{name: "Matching subscription", saved: "sub-a", cluster: "sub-a", wantErr: false},
+		{name: "Matching subscription different case", saved: "SUB-A", cluster: "sub-a", wantErr: false},
+		{name: "Mismatched subscription", saved: "sub-a", cluster: "sub-b", wantErr: true},
+		{name: "Login without subscription", saved: "", cluster: "sub-b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCredentialSubscription(tt.saved, tt.cluster)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestKubectlCredential_SubscriptionMismatch(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	cfg := config.NewConfig()
+	testToken := &auth.TokenResponse{
+		AccessToken:    "test-token",
+		TokenType:      "Bearer",
+		ExpiresOn:      time.Now().Add(1 * time.Hour),
+		TenantID:       "test-tenant",
+		ClientID:       "test-client",
+		SubscriptionID: "subscription-a",
+	}
+	if err := cfg.SaveToken(testToken); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	kubectlSubscriptionID = "subscription-b"
+	defer func() { kubectlSubscriptionID = "" }()
+
+	err := kubectlCredentialCmd.RunE(kubectlCredentialCmd, []string{})
+	if err == nil {
+		t.Fatal("Expected error for subscription mismatch, got none")
+	}
+	if !strings.Contains(err.Error(), "cached login is for subscription subscription-a") {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+}
+
+func TestKubectlCredential_CertificateLogin(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	cfg := config.NewConfig()
+	testToken := &auth.TokenResponse{
+		AccessToken:       "arm-token",
+		TokenType:         "Bearer",
+		ExpiresOn:         time.Now().Add(1 * time.Hour),
+		TenantID:          "test-tenant",
+		ClientID:          "test-client",
+		SubscriptionID:    "test-subscription",
+		AuthorityTenantID: "resource-tenant",
+		CertificatePath:   "/certs/sp.pem",
+	}
+	if err := cfg.SaveToken(testToken); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+
+	var refreshedFor *config.SavedToken
+	original := refreshAccessToken
+	refreshAccessToken = func(ctx context.Context, token *config.SavedToken) (*auth.TokenResponse, error) {
+		refreshedFor = token
+		return &auth.TokenResponse{AccessToken: "aks-token", ExpiresOn: time.Now().Add(1 * time.Hour)}, nil
+	}
+	defer func() { refreshAccessToken = original }()
+
+	var runErr error
+	out := captureStdout(t, func() {
+		runErr = kubectlCredentialCmd.RunE(kubectlCredentialCmd, []string{})
+	})
+	if runErr != nil {
+		t.Fatalf("Expected credential to be issued, got: %v", runErr)
+	}
+	if refreshedFor == nil || refreshedFor.Scope != aks.AKSServerID+"/.default" {
+		t.Fatalf("Expected exchange for the AKS server scope, got: %+v", refreshedFor)
+	}
+	if refreshedFor.CertificatePath != "/certs/sp.pem" || refreshedFor.AuthorityTenantID != "resource-tenant" {
+		t.Errorf("Expected the login's certificate and authority tenant, got: %+v", refreshedFor)
+	}
+	if !strings.Contains(out, `"token":"aks-token"`) {
+		t.Errorf("Expected AKS token in ExecCredential, got: %s", out)
+	}
+}
+
+func TestExecCredentialExpiration_RefreshSkew(t *testing.T) {
+	expiresOn := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		skew     time.Duration
+		expected string
+	}{
+		{name: "Default skew", skew: defaultRefreshSkew, expected: "2025-01-01T11:58:00Z"},
+		{name: "Custom skew", skew: 10 * time.Minute, expected: "2025-01-01T11:50:00Z"},
+		{name: "No skew", skew: 0, expected: "2025-01-01T12:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := execCredentialExpiration(expiresOn, tt.skew)
+			if got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+
+			reported, err := time.Parse(time.RFC3339, got)
+			if err != nil {
+				t.Fatalf("Failed to parse reported expiration: %v", err)
+			}
+			if expiresOn.Sub(reported) != tt.skew {
+				t.Errorf("Expected reported expiration %s before actual expiry, got %s", tt.skew, expiresOn.Sub(reported))
+			}
+		})
+	}
+}
@@ -1,11 +1,18 @@
 package commands
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/cogna-public/azure-login/internal/auth"
+	"github.com/cogna-public/azure-login/internal/cloud"
 	"github.com/cogna-public/azure-login/pkg/config"
 	"github.com/spf13/cobra"
 )
@@ -14,7 +21,16 @@ var (
 	clientID            string
 	tenantID            string
 	subscriptionID      string
+	authorityTenantID   string
 	allowNoSubscription bool
+	loginConfigPath     string
+	loginScopes         []string
+	printAssertion      bool
+	tokenFD             int
+	loginCloudName      string
+	expectedIssuer      string
+	certificatePath     string
+	loginAudience       string
 
 	// uuidPattern matches Azure UUID/GUID format (8-4-4-4-12 hex digits)
 	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
@@ -24,15 +40,30 @@ var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Authenticate to Azure using OIDC",
 	Long: `Authenticate to Azure using OpenID Connect (OIDC) workload identity federation.
-This command is designed for use in GitHub Actions with federated credentials.`,
+This command is designed for use in GitHub Actions or GitLab CI (detected via
+GITLAB_CI) with federated credentials.
+
+Outside CI, a service principal can authenticate with a certificate instead
+(--certificate-path or AZURE_CLIENT_CERTIFICATE_PATH; an encrypted private key
+is decrypted with AZURE_CLIENT_CERTIFICATE_PASSWORD).`,
 	RunE: runLogin,
 }
 
 func init() {
 	loginCmd.Flags().StringVar(&clientID, "client-id", "", "Azure Application (Client) ID")
 	loginCmd.Flags().StringVar(&tenantID, "tenant-id", "", "Azure Active Directory Tenant ID")
-	loginCmd.Flags().StringVar(&subscriptionID, "subscription-id", "", "Azure Subscription ID (optional)")
+	loginCmd.Flags().StringVar(&subscriptionID, "subscription-id", "", "Azure Subscription ID (optional); a comma-separated list also caches each subscription as a profile named after its ID")
+	loginCmd.Flags().StringVar(&authorityTenantID, "authority-tenant", "", "Tenant ID to authenticate against when it differs from the home tenant (optional)")
 	loginCmd.Flags().BoolVar(&allowNoSubscription, "allow-no-subscriptions", false, "Allow authentication without subscription")
+	loginCmd.Flags().BoolVar(&githubActionsMode, "github-actions", false, "Mask the token and write access_token/expires_on to $GITHUB_OUTPUT")
+	loginCmd.Flags().StringArrayVar(&loginScopes, "scope", nil, "OAuth2 scope to acquire a token for; repeat to acquire several concurrently (default: Azure Resource Management)")
+	loginCmd.Flags().BoolVar(&printAssertion, "print-assertion", false, "Print the decoded OIDC token header and claims to stderr (the token itself is never printed)")
+	loginCmd.Flags().IntVar(&tokenFD, "token-fd", -1, "Write the access token to this already-open file descriptor instead of caching it on disk")
+	loginCmd.Flags().StringVar(&expectedIssuer, "issuer", "", "Fail early unless the OIDC token was issued by this issuer (e.g. https://<ghes-host>/_services/token on GitHub Enterprise Server)")
+	loginCmd.Flags().StringVar(&loginAudience, "audience", "", "Audience of the requested GitHub OIDC token, for federated credentials with a custom audience (default: api://AzureADTokenExchange)")
+	loginCmd.Flags().StringVar(&certificatePath, "certificate-path", "", "PEM file with a service principal certificate and private key to sign the client assertion instead of using an OIDC token (default: $AZURE_CLIENT_CERTIFICATE_PATH)")
+	loginCmd.Flags().StringVar(&loginCloudName, "cloud", "", "Azure cloud: AzurePublicCloud, AzureUSGovernment or AzureChinaCloud (default: $AZURE_ENVIRONMENT, else AzurePublicCloud)")
+	loginCmd.Flags().StringVar(&loginConfigPath, "config-file", "", "JSON file with default clientId, tenantId, subscriptionId, scope, cloud and audience (flags and env take precedence)")
 }
 
 func runLogin(cmd *cobra.Command, args []string) error {
@@ -47,6 +78,53 @@ func runLogin(cmd *cobra.Command, args []string) error {
 	if subscriptionID == "" {
 		subscriptionID = os.Getenv("AZURE_SUBSCRIPTION_ID")
 	}
+	if certificatePath == "" {
+		certificatePath = os.Getenv(auth.CertificatePathEnvVar)
+	}
+	cloudName := loginCloudName
+	if cloudName == "" {
+		cloudName = os.Getenv(cloud.EnvironmentVariable)
+	}
+
+	// Config file values are the lowest-precedence defaults
+	scopes := loginScopes
+	audience := loginAudience
+	if loginConfigPath != "" {
+		fileConfig, err := loadLoginConfigFile(loginConfigPath)
+		if err != nil {
+			return err
+		}
+		if clientID == "" {
+			clientID = fileConfig.ClientID
+		}
+		if tenantID == "" {
+			tenantID = fileConfig.TenantID
+		}
+		if subscriptionID == "" {
+			subscriptionID = fileConfig.SubscriptionID
+		}
+		if len(scopes) == 0 && fileConfig.Scope != "" {
+			scopes = []string{fileConfig.Scope}
+		}
+		if cloudName == "" {
+			cloudName = fileConfig.Cloud
+		}
+		if audience == "" {
+			audience = fileConfig.Audience
+		}
+	}
+	// The default audience is not recorded, so only a custom one is reused on refresh
+	if audience == auth.DefaultOIDCAudience {
+		audience = ""
+	}
+
+	azureCloud, err := cloud.Lookup(cloudName)
+	if err != nil {
+		return err
+	}
+	if len(scopes) == 0 {
+		scopes = []string{azureCloud.ManagementScope()}
+	}
 
 	// Validate required parameters
 	if clientID == "" {
@@ -63,40 +141,266 @@ func runLogin(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("tenant-id must be a valid UUID/GUID format (e.g., 12345678-1234-1234-1234-123456789abc)")
 	}
 
+	// A list of subscriptions logs in to the first; all are cached as profiles below
+	subscriptionIDs := splitSubscriptionIDs(subscriptionID)
+	if len(subscriptionIDs) > 0 {
+		subscriptionID = subscriptionIDs[0]
+	} else if subscriptionID != "" {
+		// Only separators and blanks (e.g. ","), which must not be saved as the subscription
+		return fmt.Errorf("subscription-id must be a valid UUID/GUID format (e.g., 12345678-1234-1234-1234-123456789abc)")
+	}
 	if subscriptionID == "" && !allowNoSubscription {
 		return fmt.Errorf("subscription-id is required (or use --allow-no-subscriptions)")
 	}
-	if subscriptionID != "" && !isValidUUID(subscriptionID) {
-		return fmt.Errorf("subscription-id must be a valid UUID/GUID format (e.g., 12345678-1234-1234-1234-123456789abc)")
+	for _, id := range subscriptionIDs {
+		if !isValidUUID(id) {
+			if len(subscriptionIDs) > 1 {
+				return fmt.Errorf("subscription-id %q must be a valid UUID/GUID format (e.g., 12345678-1234-1234-1234-123456789abc)", id)
+			}
+			return fmt.Errorf("subscription-id must be a valid UUID/GUID format (e.g., 12345678-1234-1234-1234-123456789abc)")
+		}
 	}
 
-	// Get OIDC token from GitHub Actions environment
-	oidcToken, err := auth.GetGitHubOIDCToken(cmd.Context())
-	if err != nil {
-		return fmt.Errorf("failed to get OIDC token: %w", err)
+	if authorityTenantID != "" && !isValidUUID(authorityTenantID) {
+		return fmt.Errorf("authority-tenant must be a valid UUID/GUID format (e.g., 12345678-1234-1234-1234-123456789abc)")
 	}
 
-	// Exchange OIDC token for Azure access token
-	authClient := auth.NewClient(tenantID, clientID, subscriptionID)
-	tokenResponse, err := authClient.ExchangeOIDCToken(cmd.Context(), oidcToken)
-	if err != nil {
-		return fmt.Errorf("failed to exchange OIDC token: %w", err)
+	for _, scope := range scopes {
+		if err := validateClientCredentialsScope(scope); err != nil {
+			return err
+		}
+	}
+
+	if tokenFD == 0 || tokenFD < -1 {
+		return fmt.Errorf("token-fd must be a writable file descriptor (1 or higher)")
+	}
+	if tokenFD != -1 && len(subscriptionIDs) > 1 {
+		return fmt.Errorf("--token-fd cannot be used with several subscription IDs; the subscription contexts are cached as profiles")
+	}
+	// stderr is captured in CI logs just like stdout
+	if (tokenFD == 1 || tokenFD == 2) && secretsToFileOnly {
+		return fmt.Errorf("refusing to write the token to stdout or stderr (--token-fd %d) with --secrets-to-file-only", tokenFD)
+	}
+
+	// A certificate credential signs its own assertion, so no OIDC token is needed
+	var certificate *auth.CertificateCredential
+	var certificateFile string
+	var oidcToken string
+	if certificatePath != "" {
+		if printAssertion || expectedIssuer != "" || audience != "" {
+			return fmt.Errorf("--print-assertion, --issuer and --audience apply only to OIDC login, not --certificate-path")
+		}
+		certificate, err = auth.LoadCertificateCredential(certificatePath, os.Getenv(auth.CertificatePasswordEnvVar))
+		if err != nil {
+			return fmt.Errorf("failed to load certificate: %w", err)
+		}
+		// Recorded for refresh, which may run from another working directory
+		certificateFile, err = filepath.Abs(certificatePath)
+		if err != nil {
+			return fmt.Errorf("failed to resolve certificate path: %w", err)
+		}
+	} else {
+		// Get OIDC token from the CI environment (GitHub Actions or GitLab CI)
+		oidcToken, err = auth.GetOIDCTokenForAudience(commandContext(cmd), audience)
+		if err != nil {
+			return fmt.Errorf("failed to get OIDC token: %w", err)
+		}
+
+		// Show the claims Azure AD will match against the federated credential
+		if printAssertion {
+			if err := printDecodedAssertion(os.Stderr, oidcToken); err != nil {
+				return err
+			}
+		}
+
+		// A mismatched issuer would otherwise surface as an opaque AADSTS error
+		if expectedIssuer != "" {
+			if err := checkAssertionIssuer(oidcToken, expectedIssuer); err != nil {
+				return err
+			}
+		}
+
+		// Fail once before the concurrent exchanges if the assertion is stale (e.g. a
+		// pre-fetched GitLab token) or the runner clock is off
+		if err := auth.CheckAssertionLifetime(oidcToken, time.Now()); err != nil {
+			return err
+		}
 	}
 
-	// Save token to cache
-	cfg := config.NewConfig()
-	if err := cfg.SaveToken(tokenResponse); err != nil {
+	// Exchange the single assertion (or certificate) for a token per scope
+	// The token endpoint uses the authority tenant (if set), while the home tenant is stored
+	exchange := func(ctx context.Context, scope string) (*auth.TokenResponse, error) {
+		authClient := auth.NewClientWithScope(tenantID, clientID, subscriptionID, scope)
+		authClient.SetCloud(azureCloud)
+		if authorityTenantID != "" {
+			authClient.SetAuthorityTenant(authorityTenantID)
+		}
+		if certificate != nil {
+			token, err := authClient.ExchangeCertificate(ctx, certificate)
+			if err != nil {
+				return nil, err
+			}
+			token.AuthorityTenantID = authorityTenantID
+			token.CertificatePath = certificateFile
+			return token, nil
+		}
+		token, err := authClient.ExchangeOIDCToken(ctx, oidcToken)
+		if err != nil {
+			return nil, err
+		}
+		token.OIDCAudience = audience
+		token.AuthorityTenantID = authorityTenantID
+		return token, nil
+	}
+
+	// With --token-fd the token never touches the filesystem, so nothing is cached
+	var cfg *config.Config
+	if tokenFD == -1 {
+		cfg = config.NewConfig()
+	}
+	results := exchangeScopes(commandContext(cmd), scopes, exchange, cfg)
+
+	// The first requested scope that succeeded becomes the primary cached token
+	var tokenResponse *auth.TokenResponse
+	for _, result := range results {
+		if result.Token != nil {
+			tokenResponse = result.Token
+			break
+		}
+	}
+	if tokenResponse == nil {
+		return joinScopeErrors(results)
+	}
+
+	if tokenFD != -1 {
+		if err := writeTokenToFD(tokenFD, tokenResponse.AccessToken); err != nil {
+			return err
+		}
+	} else if err := cfg.SaveToken(tokenResponse); err != nil {
 		return fmt.Errorf("failed to save token: %w", err)
+	} else if len(subscriptionIDs) > 1 {
+		if err := saveSubscriptionProfiles(cfg, tokenResponse, subscriptionIDs); err != nil {
+			return err
+		}
+	}
+
+	if githubActionsMode {
+		for _, result := range results {
+			if result.Token != nil && result.Token != tokenResponse {
+				_, _ = fmt.Fprintf(os.Stderr, "::add-mask::%s\n", result.Token.AccessToken)
+			}
+		}
+		if err := emitGitHubActionsCommands(os.Stderr, tokenResponse.AccessToken, tokenResponse.ExpiresOn); err != nil {
+			return err
+		}
 	}
 
 	// Explicitly ignore errors from stderr writes (nowhere to report if stderr fails)
 	_, _ = fmt.Fprintf(os.Stderr, "Successfully authenticated to Azure\n")
 	_, _ = fmt.Fprintf(os.Stderr, "Tenant: %s\n", tenantID)
+	if authorityTenantID != "" && authorityTenantID != tenantID {
+		_, _ = fmt.Fprintf(os.Stderr, "Authority tenant: %s\n", authorityTenantID)
+	}
 	_, _ = fmt.Fprintf(os.Stderr, "Client: %s\n", clientID)
 	if subscriptionID != "" {
 		_, _ = fmt.Fprintf(os.Stderr, "Subscription: %s\n", subscriptionID)
 	}
+	if len(subscriptionIDs) > 1 {
+		_, _ = fmt.Fprintf(os.Stderr, "Subscription profiles: %s (select with --profile <subscription-id>)\n", strings.Join(subscriptionIDs, ", "))
+	}
+	if len(scopes) > 1 {
+		for _, result := range results {
+			status := "ok"
+			if result.Err != nil {
+				status = "failed"
+			}
+			_, _ = fmt.Fprintf(os.Stderr, "Scope %s: %s\n", result.Scope, status)
+		}
+	}
+
+	return joinScopeErrors(results)
+}
+
+// splitSubscriptionIDs splits a comma-separated list of subscription IDs, dropping
+// empty entries and case-insensitive duplicates while keeping the order
+func splitSubscriptionIDs(value string) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(value, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[strings.ToLower(id)] {
+			continue
+		}
+		seen[strings.ToLower(id)] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// saveSubscriptionProfiles caches the token once per subscription, as a profile named
+// after the subscription ID. The ARM token is not tied to a subscription, so each
+// profile only differs in the subscription context it records.
+func saveSubscriptionProfiles(cfg *config.Config, token *auth.TokenResponse, subscriptionIDs []string) error {
+	for _, id := range subscriptionIDs {
+		subscriptionToken := *token
+		subscriptionToken.SubscriptionID = id
+		if err := cfg.ForProfile(id).SaveToken(&subscriptionToken); err != nil {
+			return fmt.Errorf("failed to save token for subscription %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// printDecodedAssertion writes the decoded header and claims of the OIDC token to w.
+// The raw token and its signature are never written.
+func printDecodedAssertion(w io.Writer, oidcToken string) error {
+	header, claims, err := auth.DecodeJWT(oidcToken)
+	if err != nil {
+		return fmt.Errorf("failed to decode OIDC token: %w", err)
+	}
 
+	data, err := json.MarshalIndent(map[string]any{
+		"header": header,
+		"claims": claims,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode OIDC token claims: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(w, "OIDC assertion (token masked, %d bytes):\n%s\n", len(oidcToken), data)
+	return nil
+}
+
+// checkAssertionIssuer verifies the OIDC token's iss claim matches the expected issuer,
+// ignoring a trailing slash. GitHub Enterprise Server issues tokens from its own host,
+// which the federated credential must be configured with.
+func checkAssertionIssuer(oidcToken, expected string) error {
+	_, claims, err := auth.DecodeJWT(oidcToken)
+	if err != nil {
+		return fmt.Errorf("failed to decode OIDC token: %w", err)
+	}
+
+	issuer, _ := claims["iss"].(string)
+	if strings.TrimSuffix(issuer, "/") != strings.TrimSuffix(expected, "/") {
+		return fmt.Errorf("OIDC token issuer %q does not match --issuer %q; the federated credential's issuer must match the token issuer", issuer, expected)
+	}
+	return nil
+}
+
+// writeTokenToFD writes the access token followed by a newline to an inherited file
+// descriptor (e.g. a pipe set up by wrapper tooling) and closes it
+func writeTokenToFD(fd int, accessToken string) error {
+	file := os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+	if file == nil {
+		return fmt.Errorf("invalid token file descriptor %d", fd)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	if _, err := fmt.Fprintln(file, accessToken); err != nil {
+		return fmt.Errorf("failed to write token to file descriptor %d: %w", fd, err)
+	}
 	return nil
 }
 
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/cogna-public/azure-login/internal/auth"
+	"github.com/cogna-public/azure-login/pkg/config"
+)
+
+// maxConcurrentScopeExchanges bounds parallel token exchanges to avoid AAD throttling
+const maxConcurrentScopeExchanges = 4
+
+// scopeExchangeFunc exchanges the (shared) OIDC assertion for a token with the given scope
+type scopeExchangeFunc func(ctx context.Context, scope string) (*auth.TokenResponse, error)
+
+// scopeResult is the outcome of exchanging and caching a token for a single scope
+type scopeResult struct {
+	Scope string
+	Token *auth.TokenResponse
+	Err   error
+}
+
+// exchangeScopes exchanges tokens for all scopes concurrently with bounded parallelism
+// and caches each successful token under its scope (unless cfg is nil). A failure for
+// one scope does not abort the others; results are returned in the same order as scopes.
+func exchangeScopes(ctx context.Context, scopes []string, exchange scopeExchangeFunc, cfg *config.Config) []scopeResult {
+	results := make([]scopeResult, len(scopes))
+	sem := make(chan struct{}, maxConcurrentScopeExchanges)
+
+	var wg sync.WaitGroup
+	for i, scope := range scopes {
+		wg.Add(1)
+		go func(i int, scope string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result := scopeResult{Scope: scope}
+			token, err := exchange(ctx, scope)
+			if err != nil {
+				result.Err = fmt.Errorf("failed to exchange OIDC token for scope %s: %w", scope, err)
+			} else if cfg == nil {
+				result.Token = token
+			} else if err := cfg.SaveTokenForScope(token); err != nil {
+				result.Err = fmt.Errorf("failed to save token for scope %s: %w", scope, err)
+			} else {
+				result.Token = token
+			}
+			results[i] = result
+		}(i, scope)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// joinScopeErrors aggregates the errors of all failed scopes (nil if all succeeded)
+func joinScopeErrors(results []scopeResult) error {
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateClientCredentialsScope rejects scopes the client credentials grant cannot
+// issue. Both OIDC and certificate login use that grant, which only accepts a single
+// "<resource>/.default" scope; granular delegated scopes such as User.Read need an
+// interactive (device code or on-behalf-of) flow, which azure-login does not provide.
+func validateClientCredentialsScope(scope string) error {
+	fields := strings.Fields(scope)
+	if len(fields) != 1 {
+		return fmt.Errorf("scope %q: the client credentials flow accepts a single <resource>/.default scope; repeat --scope to acquire tokens for several resources", scope)
+	}
+	if !strings.HasSuffix(fields[0], "/.default") {
+		return fmt.Errorf("scope %q is a granular (delegated) scope, which the client credentials flow does not support; use <resource>/.default (e.g. https://graph.microsoft.com/.default) and grant the permission to the app registration", scope)
+	}
+	return nil
+}
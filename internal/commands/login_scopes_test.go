@@ -0,0 +1,172 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/auth"
+	"github.com/cogna-public/azure-login/pkg/config"
+)
+
+func TestExchangeScopes_PartialFailure(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	scopes := []string{"api://a/.default", "api://b/.default", "api://c/.default"}
+	exchange := func(ctx context.Context, scope string) (*auth.TokenResponse, error) {
+		if scope == "api://b/.default" {
+			return nil, fmt.Errorf("authentication failed: invalid_scope")
+		}
+		return &auth.TokenResponse{
+			AccessToken: "token-for-" + scope,
+			TokenType:   "Bearer",
+			ExpiresOn:   time.Now().Add(1 * time.Hour),
+			Scope:       scope,
+		}, nil
+	}
+
+	cfg := config.NewConfig()
+	results := exchangeScopes(context.Background(), scopes, exchange, cfg)
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	for i, result := range results {
+		if result.Scope != scopes[i] {
+			t.Errorf("Expected result %d for scope %s, got %s", i, scopes[i], result.Scope)
+		}
+	}
+	if results[1].Err == nil {
+		t.Error("Expected error for scope b")
+	}
+
+	// The other two scopes must be cached
+	for _, scope := range []string{"api://a/.default", "api://c/.default"} {
+		token, err := cfg.LoadTokenForScope(scope)
+		if err != nil {
+			t.Errorf("Expected token for %s to be cached, got: %v", scope, err)
+			continue
+		}
+		if token.AccessToken != "token-for-"+scope {
+			t.Errorf("Expected cached token for %s, got %s", scope, token.AccessToken)
+		}
+		if token.Scope != scope {
+			t.Errorf("Expected cached scope %s, got %s", scope, token.Scope)
+		}
+	}
+	if _, err := cfg.LoadTokenForScope("api://b/.default"); err == nil {
+		t.Error("Expected no cached token for failed scope")
+	}
+
+	err := joinScopeErrors(results)
+	if err == nil || !strings.Contains(err.Error(), "api://b/.default") {
+		t.Errorf("Expected aggregated error mentioning failed scope, got: %v", err)
+	}
+}
+
+func TestExchangeScopes_BoundedConcurrency(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	var inFlight, maxInFlight int32
+	exchange := func(ctx context.Context, scope string) (*auth.TokenResponse, error) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			observed := atomic.LoadInt32(&maxInFlight)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxInFlight, observed, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return &auth.TokenResponse{AccessToken: "token", Scope: scope}, nil
+	}
+
+	var scopes []string
+	for i := 0; i < 10; i++ {
+		scopes = append(scopes, fmt.Sprintf("api://scope-%d/.default", i))
+	}
+
+	results := exchangeScopes(context.Background(), scopes, exchange, config.NewConfig())
+	if err := joinScopeErrors(results); err != nil {
+		t.Fatalf("Expected no errors, got: %v", err)
+	}
+	if maxInFlight > maxConcurrentScopeExchanges {
+		t.Errorf("Expected at most %d concurrent exchanges, got %d", maxConcurrentScopeExchanges, maxInFlight)
+	}
+}
+
+func TestExchangeScopes_NilConfigSkipsCache(t *testing.T) {
+	tmpDir := setupTestConfig(t)
+	defer cleanupTestConfig()
+
+	exchange := func(ctx context.Context, scope string) (*auth.TokenResponse, error) {
+		return &auth.TokenResponse{AccessToken: "token-" + scope, Scope: scope, ExpiresOn: time.Now().Add(time.Hour)}, nil
+	}
+
+	results := exchangeScopes(context.Background(), []string{"scope-a"}, exchange, nil)
+	if results[0].Err != nil || results[0].Token == nil {
+		t.Fatalf("Expected successful result, got %+v", results[0])
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read config dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no token files to be written, got %d", len(entries))
+	}
+}
+
+func TestValidateClientCredentialsScope(t *testing.T) {
+	tests := []struct {
+		scope   string
+		wantErr string
+	}{
+		{scope: "https://management.azure.com/.default"},
+		{scope: "api://my-app/.default"},
+		{scope: "User.Read", wantErr: "granular"},
+		{scope: "https://graph.microsoft.com/User.Read", wantErr: "granular"},
+		{scope: "https://graph.microsoft.com/.default User.Read", wantErr: "single"},
+		{scope: "", wantErr: "single"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			err := validateClientCredentialsScope(tt.scope)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected scope to be accepted, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoginValidation_GranularScopeRejected(t *testing.T) {
+	clientID = "12345678-1234-1234-1234-123456789abc"
+	tenantID = "12345678-1234-1234-1234-123456789abc"
+	subscriptionID = "12345678-1234-1234-1234-123456789abc"
+	loginScopes = []string{"User.Read"}
+	defer func() {
+		clientID = ""
+		tenantID = ""
+		subscriptionID = ""
+		loginScopes = nil
+	}()
+
+	// Rejected before any OIDC token is requested
+	err := runLogin(nil, []string{})
+	if err == nil || !strings.Contains(err.Error(), "client credentials flow") {
+		t.Errorf("Expected granular scope error, got: %v", err)
+	}
+}
@@ -1,9 +1,18 @@
 package commands
 
 import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/auth"
+	"github.com/cogna-public/azure-login/pkg/config"
 )
 
 func TestLoginValidation_MissingClientID(t *testing.T) {
@@ -264,6 +273,32 @@ func TestLoginValidation_InvalidSubscriptionID(t *testing.T) {
 	}
 }
 
+func TestLoginValidation_InvalidAuthorityTenant(t *testing.T) {
+	_ = os.Unsetenv("AZURE_CLIENT_ID")
+	_ = os.Unsetenv("AZURE_TENANT_ID")
+	_ = os.Unsetenv("AZURE_SUBSCRIPTION_ID")
+
+	clientID = "12345678-1234-1234-1234-123456789abc"
+	tenantID = "12345678-1234-1234-1234-123456789abc"
+	subscriptionID = "12345678-1234-1234-1234-123456789abc"
+	authorityTenantID = "not-a-guid"
+	allowNoSubscription = false
+	defer func() {
+		clientID = ""
+		tenantID = ""
+		subscriptionID = ""
+		authorityTenantID = ""
+	}()
+
+	err := runLogin(nil, []string{})
+	if err == nil {
+		t.Fatal("Expected error for invalid authority-tenant, got none")
+	}
+	if !strings.Contains(err.Error(), "authority-tenant must be a valid UUID") {
+		t.Errorf("Expected 'authority-tenant must be a valid UUID' error, got: %v", err)
+	}
+}
+
 func TestIsValidUUID(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -291,3 +326,283 @@ func TestIsValidUUID(t *testing.T) {
 		})
 	}
 }
+
+func TestPrintDecodedAssertion(t *testing.T) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"repo:org/repo:environment:prod","iss":"https://token.actions.githubusercontent.com","aud":"api://AzureADTokenExchange"}`))
+	token := header + "." + claims + ".c2lnbmF0dXJl"
+
+	var buf bytes.Buffer
+	if err := printDecodedAssertion(&buf, token); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	out := buf.String()
+	for _, expected := range []string{"repo:org/repo:environment:prod", "https://token.actions.githubusercontent.com", "api://AzureADTokenExchange", "RS256"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected output to contain %q, got: %s", expected, out)
+		}
+	}
+	if strings.Contains(out, token) {
+		t.Error("Expected raw token not to be printed")
+	}
+	if strings.Contains(out, "c2lnbmF0dXJl") {
+		t.Error("Expected signature not to be printed")
+	}
+}
+
+func TestPrintDecodedAssertion_InvalidToken(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printDecodedAssertion(&buf, "not-a-jwt"); err == nil {
+		t.Fatal("Expected error for invalid token, got none")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected no output for invalid token, got: %s", buf.String())
+	}
+}
+
+func TestWriteTokenToFD(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+
+	// writeTokenToFD closes the descriptor, which signals EOF to the reader
+	if err := writeTokenToFD(int(w.Fd()), "test-access-token"); err != nil {
+		t.Fatalf("writeTokenToFD failed: %v", err)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to read pipe: %v", err)
+	}
+	if string(data) != "test-access-token\n" {
+		t.Errorf("Expected token on the pipe, got %q", data)
+	}
+}
+
+func TestLoginValidation_InvalidTokenFD(t *testing.T) {
+	clientID = "12345678-1234-1234-1234-123456789abc"
+	tenantID = "12345678-1234-1234-1234-123456789abc"
+	subscriptionID = "12345678-1234-1234-1234-123456789abc"
+	tokenFD = 0
+	defer func() {
+		clientID = ""
+		tenantID = ""
+		subscriptionID = ""
+		tokenFD = -1
+	}()
+
+	err := runLogin(nil, []string{})
+	if err == nil || !strings.Contains(err.Error(), "token-fd") {
+		t.Errorf("Expected token-fd validation error, got: %v", err)
+	}
+}
+
+func TestLoginValidation_TokenFDSecretsToFileOnly(t *testing.T) {
+	clientID = "12345678-1234-1234-1234-123456789abc"
+	tenantID = "12345678-1234-1234-1234-123456789abc"
+	subscriptionID = "12345678-1234-1234-1234-123456789abc"
+	secretsToFileOnly = true
+	defer func() {
+		clientID = ""
+		tenantID = ""
+		subscriptionID = ""
+		secretsToFileOnly = false
+		tokenFD = -1
+	}()
+
+	for _, fd := range []int{1, 2} {
+		tokenFD = fd
+		err := runLogin(nil, []string{})
+		if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("--token-fd %d", fd)) {
+			t.Errorf("Expected --token-fd %d to be refused, got: %v", fd, err)
+		}
+	}
+}
+
+func TestLoginValidation_UnknownCloud(t *testing.T) {
+	clientID = "12345678-1234-1234-1234-123456789abc"
+	tenantID = "12345678-1234-1234-1234-123456789abc"
+	subscriptionID = "12345678-1234-1234-1234-123456789abc"
+	loginCloudName = "AzureGermanCloud"
+	defer func() {
+		clientID = ""
+		tenantID = ""
+		subscriptionID = ""
+		loginCloudName = ""
+	}()
+
+	err := runLogin(nil, []string{})
+	if err == nil || !strings.Contains(err.Error(), "AzureGermanCloud") {
+		t.Errorf("Expected unknown cloud error, got: %v", err)
+	}
+}
+
+func TestLoginValidation_UnreadableCertificate(t *testing.T) {
+	clientID = "12345678-1234-1234-1234-123456789abc"
+	tenantID = "12345678-1234-1234-1234-123456789abc"
+	subscriptionID = "12345678-1234-1234-1234-123456789abc"
+	certificatePath = filepath.Join(t.TempDir(), "missing.pem")
+	defer func() {
+		clientID = ""
+		tenantID = ""
+		subscriptionID = ""
+		certificatePath = ""
+	}()
+
+	err := runLogin(nil, []string{})
+	if err == nil || !strings.Contains(err.Error(), "failed to load certificate") {
+		t.Errorf("Expected certificate load error, got: %v", err)
+	}
+}
+
+func TestTokenCloud(t *testing.T) {
+	tests := []struct {
+		saved    string
+		expected string
+	}{
+		{"", "AzureCloud"},
+		{"AzureUSGovernment", "AzureUSGovernment"},
+		{"AzureChinaCloud", "AzureChinaCloud"},
+		{"not-a-cloud", "AzureCloud"},
+	}
+
+	for _, tt := range tests {
+		got := tokenCloud(&config.SavedToken{Cloud: tt.saved})
+		if got.CLIName != tt.expected {
+			t.Errorf("tokenCloud(%q) = %s, want %s", tt.saved, got.CLIName, tt.expected)
+		}
+	}
+}
+
+func TestCheckAssertionIssuer(t *testing.T) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"https://ghes.example.com/_services/token","aud":"api://AzureADTokenExchange"}`))
+	token := header + "." + claims + ".c2lnbmF0dXJl"
+
+	if err := checkAssertionIssuer(token, "https://ghes.example.com/_services/token/"); err != nil {
+		t.Errorf("Expected matching issuer, got: %v", err)
+	}
+
+	err := checkAssertionIssuer(token, "https://token.actions.githubusercontent.com")
+	if err == nil || !strings.Contains(err.Error(), "ghes.example.com") {
+		t.Errorf("Expected issuer mismatch naming the token issuer, got: %v", err)
+	}
+}
+
+func TestLoginValidation_AudienceWithCertificate(t *testing.T) {
+	clientID = "12345678-1234-1234-1234-123456789abc"
+	tenantID = "12345678-1234-1234-1234-123456789abc"
+	subscriptionID = "12345678-1234-1234-1234-123456789abc"
+	certificatePath = "sp.pem"
+	loginAudience = "custom-audience"
+	defer func() {
+		clientID = ""
+		tenantID = ""
+		subscriptionID = ""
+		certificatePath = ""
+		loginAudience = ""
+	}()
+
+	err := runLogin(nil, []string{})
+	if err == nil || !strings.Contains(err.Error(), "--audience") {
+		t.Errorf("Expected --audience to be rejected with a certificate, got: %v", err)
+	}
+}
+
+func TestSplitSubscriptionIDs(t *testing.T) {
+	ids := splitSubscriptionIDs(" aaaaaaaa-1111-1111-1111-111111111111, ,bbbbbbbb-2222-2222-2222-222222222222,AAAAAAAA-1111-1111-1111-111111111111")
+	if len(ids) != 2 || ids[0] != "aaaaaaaa-1111-1111-1111-111111111111" || ids[1] != "bbbbbbbb-2222-2222-2222-222222222222" {
+		t.Errorf("Expected two trimmed, de-duplicated IDs in order, got %q", ids)
+	}
+}
+
+func TestLoginValidation_InvalidSubscriptionInList(t *testing.T) {
+	_ = os.Unsetenv("AZURE_SUBSCRIPTION_ID")
+	clientID = "12345678-1234-1234-1234-123456789abc"
+	tenantID = "12345678-1234-1234-1234-123456789abc"
+	subscriptionID = "12345678-1234-1234-1234-123456789abc,not-a-uuid"
+	defer func() {
+		clientID = ""
+		tenantID = ""
+		subscriptionID = ""
+	}()
+
+	err := runLogin(nil, []string{})
+	if err == nil || !strings.Contains(err.Error(), `"not-a-uuid"`) {
+		t.Errorf("Expected error naming the invalid subscription, got: %v", err)
+	}
+}
+
+func TestLoginValidation_EmptySubscriptionList(t *testing.T) {
+	_ = os.Unsetenv("AZURE_SUBSCRIPTION_ID")
+	clientID = "12345678-1234-1234-1234-123456789abc"
+	tenantID = "12345678-1234-1234-1234-123456789abc"
+	defer func() {
+		clientID = ""
+		tenantID = ""
+		subscriptionID = ""
+		allowNoSubscription = false
+	}()
+
+	for _, allow := range []bool{false, true} {
+		for _, value := range []string{",", " , ", " "} {
+			subscriptionID = value
+			allowNoSubscription = allow
+			err := runLogin(nil, []string{})
+			if err == nil || !strings.Contains(err.Error(), "subscription-id must be a valid UUID") {
+				t.Errorf("Expected subscription-id %q to be rejected (allow-no-subscriptions=%v), got: %v", value, allow, err)
+			}
+		}
+	}
+}
+
+func TestSaveSubscriptionProfiles(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	t.Setenv(config.ProfileEnvVar, "")
+
+	subscriptions := []string{
+		"aaaaaaaa-1111-1111-1111-111111111111",
+		"bbbbbbbb-2222-2222-2222-222222222222",
+		"cccccccc-3333-3333-3333-333333333333",
+	}
+	token := &auth.TokenResponse{
+		AccessToken:    "arm-token",
+		ExpiresOn:      time.Now().Add(time.Hour),
+		TenantID:       "test-tenant",
+		ClientID:       "test-client",
+		SubscriptionID: subscriptions[0],
+	}
+
+	cfg := config.NewConfig()
+	if err := cfg.SaveToken(token); err != nil {
+		t.Fatalf("Failed to save token: %v", err)
+	}
+	if err := saveSubscriptionProfiles(cfg, token, subscriptions); err != nil {
+		t.Fatalf("Failed to save subscription profiles: %v", err)
+	}
+
+	// The first subscription stays the default context
+	saved, err := cfg.LoadToken()
+	if err != nil || saved.SubscriptionID != subscriptions[0] {
+		t.Fatalf("Expected default profile for %s, got %+v (%v)", subscriptions[0], saved, err)
+	}
+
+	// Each subscription has its own stored context
+	for _, id := range subscriptions {
+		saved, err := cfg.ForProfile(id).LoadToken()
+		if err != nil {
+			t.Fatalf("Expected a profile for subscription %s: %v", id, err)
+		}
+		if saved.SubscriptionID != id || saved.AccessToken != "arm-token" {
+			t.Errorf("Unexpected context for subscription %s: %+v", id, saved)
+		}
+	}
+	profiles, err := cfg.ListProfiles()
+	if err != nil || len(profiles) != 4 {
+		t.Errorf("Expected the default and three subscription profiles, got %v (%v)", profiles, err)
+	}
+}
@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"github.com/cogna-public/azure-login/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// logoutAll removes the cached tokens of every profile (--all)
+var logoutAll bool
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove the cached tokens of the active profile",
+	Long: `Delete the cached tokens of the active profile (--profile or AZURE_LOGIN_PROFILE):
+the token saved at login and the per-scope tokens acquired with it. Other profiles
+are left signed in.
+
+--all removes the cached tokens of every profile.`,
+	RunE: runLogout,
+}
+
+func init() {
+	logoutCmd.Flags().BoolVar(&logoutAll, "all", false, "Remove the cached tokens of every profile, not only the active one")
+}
+
+func runLogout(cmd *cobra.Command, args []string) error {
+	cfg := config.NewConfig()
+
+	var files []config.TokenFile
+	var err error
+	if logoutAll {
+		files, err = cfg.TokenFiles()
+	} else {
+		files, err = cfg.ProfileTokenFiles()
+	}
+	if err != nil {
+		return err
+	}
+	return removeTokenFiles(cfg, files, false)
+}
@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/auth"
+	"github.com/cogna-public/azure-login/pkg/config"
+)
+
+// executeRoot runs the root command with the given arguments and returns its stdout
+func executeRoot(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	rootCmd.SetArgs(args)
+	defer rootCmd.SetArgs(nil)
+
+	var runErr error
+	out := captureStdout(t, func() { runErr = rootCmd.Execute() })
+	return out, runErr
+}
+
+func TestProfiles_EndToEnd(t *testing.T) {
+	tmpDir := setupTestConfig(t)
+	defer cleanupTestConfig()
+	t.Setenv(config.ProfileEnvVar, "")
+	defer func() {
+		profileName = ""
+		logoutAll = false
+		clearAll = false
+		config.SetProfile("")
+	}()
+
+	// Two identities logged in side by side, each with an expired per-scope token
+	for profile, tenantID := range map[string]string{config.DefaultProfile: "tenant-a", "prod": "tenant-b"} {
+		cfg := config.NewConfig().ForProfile(profile)
+		if err := cfg.SaveToken(&auth.TokenResponse{AccessToken: profile + "-token", ExpiresOn: time.Now().Add(time.Hour), TenantID: tenantID}); err != nil {
+			t.Fatalf("Failed to save %s token: %v", profile, err)
+		}
+		if err := cfg.SaveTokenForScope(&auth.TokenResponse{AccessToken: profile + "-vault", Scope: "https://vault.azure.net/.default", ExpiresOn: time.Now().Add(-time.Minute)}); err != nil {
+			t.Fatalf("Failed to save %s scoped token: %v", profile, err)
+		}
+	}
+
+	showTenant := func(args ...string) string {
+		t.Helper()
+		out, err := executeRoot(t, append([]string{"account", "show", "-o", "json", "--query", "tenantId"}, args...)...)
+		if err != nil {
+			t.Fatalf("account show %v failed: %v", args, err)
+		}
+		return strings.TrimSpace(out)
+	}
+	if tenant := showTenant("--profile", "prod"); tenant != `"tenant-b"` {
+		t.Errorf("Expected --profile prod to show tenant-b, got %s", tenant)
+	}
+	t.Setenv(config.ProfileEnvVar, "prod")
+	if tenant := showTenant("--profile="); tenant != `"tenant-b"` {
+		t.Errorf("Expected AZURE_LOGIN_PROFILE=prod to show tenant-b, got %s", tenant)
+	}
+	t.Setenv(config.ProfileEnvVar, "")
+	if tenant := showTenant("--profile="); tenant != `"tenant-a"` {
+		t.Errorf("Expected the default profile to show tenant-a, got %s", tenant)
+	}
+
+	out, err := executeRoot(t, "account", "list-profiles", "--profile", "prod", "-o", "json", "--query", "[?active].name")
+	if err != nil {
+		t.Fatalf("account list-profiles failed: %v", err)
+	}
+	var active []string
+	if err := json.Unmarshal([]byte(out), &active); err != nil || strings.Join(active, ",") != "prod" {
+		t.Errorf("Expected prod to be the only active profile, got %q (%v)", out, err)
+	}
+
+	// account clear prunes only the active profile
+	captureStderr(t, func() {
+		if _, err := executeRoot(t, "account", "clear", "--profile", "prod"); err != nil {
+			t.Fatalf("account clear failed: %v", err)
+		}
+	})
+	if _, err := config.NewConfig().ForProfile(config.DefaultProfile).LoadTokenForScope("https://vault.azure.net/.default"); err != nil {
+		t.Errorf("Expected the default profile's expired token to survive clearing prod: %v", err)
+	}
+
+	// logout signs out only the active profile
+	captureStderr(t, func() {
+		if _, err := executeRoot(t, "logout", "--profile", "prod"); err != nil {
+			t.Fatalf("logout failed: %v", err)
+		}
+	})
+	if _, err := config.NewConfig().ForProfile("prod").LoadToken(); err == nil {
+		t.Error("Expected logout to remove the prod token")
+	}
+	if tenant := showTenant("--profile", config.DefaultProfile); tenant != `"tenant-a"` {
+		t.Errorf("Expected the default profile to stay logged in, got %s", tenant)
+	}
+
+	stderr := captureStderr(t, func() {
+		if _, err := executeRoot(t, "logout", "--all", "--profile", "prod"); err != nil {
+			t.Fatalf("logout --all failed: %v", err)
+		}
+	})
+	if !strings.Contains(stderr, "Removed 2 token file(s)") || !strings.Contains(stderr, filepath.Join(tmpDir, "azure-login-token.json")) {
+		t.Errorf("Expected logout --all to remove the default profile's files, got %q", stderr)
+	}
+	files, err := config.NewConfig().TokenFiles()
+	if err != nil || len(files) != 0 {
+		t.Errorf("Expected no token files left, got %v (%v)", files, err)
+	}
+}
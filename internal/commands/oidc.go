@@ -1,8 +1,8 @@
 package commands
 
 import (
-	"context"
 	"fmt"
+	"os"
 
 	"github.com/cogna-public/azure-login/internal/auth"
 	"github.com/cogna-public/azure-login/internal/output"
@@ -18,39 +18,94 @@ var oidcCmd = &cobra.Command{
 var oidcGetTokenCmd = &cobra.Command{
 	Use:   "get-token",
 	Short: "Get the GitHub Actions OIDC token",
-	Long: `Get the GitHub Actions OIDC token for Azure authentication.
+	Long: `Get the CI OIDC token (GitHub Actions, or GitLab CI when GITLAB_CI=true) for Azure authentication.
 This token can be used with WorkloadIdentityCredential in Azure SDKs.
 
 The token is written to stdout in the specified format (json, tsv, or table).
-For use with Azure Python SDK, write the token to a file and set AZURE_FEDERATED_TOKEN_FILE.`,
+For use with Azure Python SDK, write the token to a file with --output-file and set
+AZURE_FEDERATED_TOKEN_FILE to its path. The file is replaced atomically with 0600
+permissions and nothing is printed to stdout (unless --decode is set, in which case
+the claims are printed with the token redacted).
+
+With --decode, the complete decoded JWT header and claims are added as "header"
+and "claims" for auditing what GitHub asserts; the raw token stays in "value" so
+it can be excluded with --query claims.
+
+--audience requests a GitHub token with another aud claim than
+api://AzureADTokenExchange, for other systems that trust GitHub's OIDC issuer.
+GitLab ID tokens carry the aud declared in .gitlab-ci.yml instead.`,
 	RunE: runOIDCGetToken,
 }
 
 var (
 	oidcOutputFormat string
 	oidcQueryString  string
+	oidcDecode       bool
+	oidcAudience     string
+	oidcOutputFile   string
 )
 
 func init() {
 	oidcCmd.AddCommand(oidcGetTokenCmd)
 
 	// Add flags for output formatting
-	oidcGetTokenCmd.Flags().StringVarP(&oidcOutputFormat, "output", "o", "json", "Output format: json, tsv, table")
+	oidcGetTokenCmd.Flags().StringVarP(&oidcOutputFormat, "output", "o", "json", "Output format: json, ndjson, yaml, tsv, table, detail, value, exitcode")
 	oidcGetTokenCmd.Flags().StringVar(&oidcQueryString, "query", "", "JMESPath query string")
+	oidcGetTokenCmd.Flags().BoolVar(&strictQuery, "strict-query", false, "Fail if the query returns no result")
+	oidcGetTokenCmd.Flags().StringVar(&oidcAudience, "audience", "", "Audience (aud claim) of the GitHub OIDC token, e.g. for systems other than Azure (default: api://AzureADTokenExchange)")
+	oidcGetTokenCmd.Flags().BoolVar(&oidcDecode, "decode", false, "Include the full decoded JWT header and claims")
+	oidcGetTokenCmd.Flags().StringVar(&oidcOutputFile, "output-file", "", "Write the raw token to this file (0600) instead of stdout, e.g. for AZURE_FEDERATED_TOKEN_FILE")
 }
 
 func runOIDCGetToken(cmd *cobra.Command, args []string) error {
-	ctx := context.Background()
+	if oidcOutputFile == "" {
+		if err := checkSecretToStdout(cmd); err != nil {
+			return err
+		}
+	}
 
-	token, err := auth.GetGitHubOIDCToken(ctx)
+	token, err := auth.GetOIDCTokenForAudience(commandContext(cmd), oidcAudience)
 	if err != nil {
 		return fmt.Errorf("failed to get OIDC token: %w", err)
 	}
 
-	// Create response with token info
+	tokenInfo, err := oidcTokenInfo(token, oidcDecode)
+	if err != nil {
+		return err
+	}
+
+	// With --output-file stdout stays empty, so the command is safe in pipes and logs
+	if oidcOutputFile != "" {
+		if err := writeSecretFile(oidcOutputFile, token); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Wrote OIDC token to %s\n", oidcOutputFile)
+		if !oidcDecode {
+			return nil
+		}
+		opts := outputOptions()
+		opts.RedactSecrets = true
+		return output.PrintWithOptions(tokenInfo, oidcOutputFormat, oidcQueryString, opts)
+	}
+
+	return output.PrintWithOptions(tokenInfo, oidcOutputFormat, oidcQueryString, outputOptions())
+}
+
+// oidcTokenInfo builds the get-token output. When decode is set, the complete JWT
+// header and claim set are included alongside the raw token.
+func oidcTokenInfo(token string, decode bool) (map[string]any, error) {
 	tokenInfo := map[string]any{
 		"value": token,
 	}
+	if !decode {
+		return tokenInfo, nil
+	}
 
-	return output.Print(tokenInfo, oidcOutputFormat, oidcQueryString)
+	header, claims, err := auth.DecodeJWT(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode OIDC token: %w", err)
+	}
+	tokenInfo["header"] = header
+	tokenInfo["claims"] = claims
+	return tokenInfo, nil
 }
@@ -1,9 +1,13 @@
 package commands
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -179,3 +183,130 @@ func TestOIDCGetToken_EmptyToken(t *testing.T) {
 		t.Errorf("Expected error message to contain '%s', got: %v", expectedMsg, err)
 	}
 }
+
+func TestOIDCTokenInfo_Decode(t *testing.T) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT","kid":"test-kid"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"repo:org/repo:ref:refs/heads/main","iss":"https://token.actions.githubusercontent.com","aud":"api://AzureADTokenExchange","repository_owner":"org","run_id":"12345","job_workflow_ref":"org/repo/.github/workflows/ci.yml@refs/heads/main","exp":1700000000}`))
+	token := header + "." + claims + ".c2lnbmF0dXJl"
+
+	info, err := oidcTokenInfo(token, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if info["value"] != token {
+		t.Error("Expected raw token to be kept in value")
+	}
+
+	decodedClaims, ok := info["claims"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected claims map, got %T", info["claims"])
+	}
+	for _, name := range []string{"sub", "iss", "aud", "repository_owner", "run_id", "job_workflow_ref", "exp"} {
+		if _, ok := decodedClaims[name]; !ok {
+			t.Errorf("Expected claim %s in output", name)
+		}
+	}
+
+	decodedHeader, ok := info["header"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected header map, got %T", info["header"])
+	}
+	if decodedHeader["kid"] != "test-kid" {
+		t.Errorf("Expected header kid test-kid, got %v", decodedHeader["kid"])
+	}
+
+	// Claims can be selected without the raw token
+	data, err := json.Marshal(info["claims"])
+	if err != nil {
+		t.Fatalf("Failed to marshal claims: %v", err)
+	}
+	if strings.Contains(string(data), "c2lnbmF0dXJl") {
+		t.Error("Expected signature not to appear in claims")
+	}
+}
+
+func TestOIDCTokenInfo_DecodeInvalidToken(t *testing.T) {
+	if _, err := oidcTokenInfo("not-a-jwt", true); err == nil {
+		t.Error("Expected error decoding invalid token, got none")
+	}
+	if _, err := oidcTokenInfo("not-a-jwt", false); err != nil {
+		t.Errorf("Expected no error without --decode, got: %v", err)
+	}
+}
+
+func TestOIDCGetToken_Audience(t *testing.T) {
+	var audience string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		audience = r.URL.Query().Get("audience")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"value": "mock-oidc-token"}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("GITLAB_CI", "")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "mock-request-token")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", server.URL)
+
+	oidcOutputFormat = "json"
+	oidcQueryString = ""
+	oidcAudience = "sts.example.com"
+	defer func() { oidcAudience = "" }()
+
+	captureStdout(t, func() {
+		if err := oidcGetTokenCmd.RunE(oidcGetTokenCmd, []string{}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	})
+	if audience != "sts.example.com" {
+		t.Errorf("Expected the token to be requested for sts.example.com, got %q", audience)
+	}
+}
+
+func TestOIDCGetToken_OutputFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"value": "header.claims.signature"}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("GITLAB_CI", "")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "mock-request-token")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", server.URL)
+
+	path := filepath.Join(t.TempDir(), "federated-token")
+	if err := os.WriteFile(path, []byte("stale-token-from-a-previous-step"), 0600); err != nil {
+		t.Fatalf("Failed to write stale token: %v", err)
+	}
+
+	oidcOutputFormat = "json"
+	oidcQueryString = ""
+	oidcOutputFile = path
+	secretsToFileOnly = true
+	defer func() {
+		oidcOutputFile = ""
+		secretsToFileOnly = false
+	}()
+
+	var runErr error
+	out := captureStdout(t, func() {
+		runErr = oidcGetTokenCmd.RunE(oidcGetTokenCmd, []string{})
+	})
+	if runErr != nil {
+		t.Fatalf("Expected no error, got %v", runErr)
+	}
+	if out != "" {
+		t.Errorf("Expected empty stdout, got %q", out)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "header.claims.signature" {
+		t.Errorf("Expected the raw token in the file, got %q (%v)", data, err)
+	}
+	if info, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected 0600 permissions, got %o", info.Mode().Perm())
+	}
+	if matches, _ := filepath.Glob(path + ".*.tmp"); len(matches) != 0 {
+		t.Errorf("Expected no leftover temp files, got %v", matches)
+	}
+}
@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/output"
+)
+
+var (
+	// strictQuery makes --query fail when it yields no result (--strict-query)
+	strictQuery bool
+
+	// showSecrets disables redaction in informational output (--show-secrets)
+	showSecrets bool
+
+	// compactJSON and prettyJSON override the automatic JSON style (--compact, --pretty)
+	compactJSON bool
+	prettyJSON  bool
+
+	// noHeaders omits table header rows (--no-headers)
+	noHeaders bool
+
+	// outputLabels rename table column headers (--label key=Header); tableLabels is
+	// the parsed mapping, set before the command runs
+	outputLabels []string
+	tableLabels  map[string]string
+
+	// annotateOutput wraps output in a provenance envelope (--annotate)
+	annotateOutput bool
+
+	// outputKeys projects top-level keys of an object result in order (--keys)
+	outputKeys []string
+
+	// invokedCommand is the command path recorded in the --annotate envelope
+	invokedCommand string
+
+	// durationFormat selects how durations are printed: human or iso8601 (--duration-format)
+	durationFormat = output.DurationHuman
+)
+
+// formatDuration renders a duration in the format selected with --duration-format
+func formatDuration(d time.Duration) string {
+	return output.FormatDuration(d, durationFormat)
+}
+
+// outputOptions returns the output options selected by the shared output flags
+func outputOptions() output.Options {
+	opts := output.Options{
+		StrictQuery: strictQuery,
+		NoHeaders:   noHeaders,
+		Labels:      tableLabels,
+		Keys:        outputKeys,
+	}
+	if annotateOutput {
+		opts.Annotation = &output.Annotation{Command: invokedCommand, Version: version}
+	}
+	switch {
+	case prettyJSON:
+		opts.JSONStyle = output.JSONPretty
+	case compactJSON:
+		opts.JSONStyle = output.JSONCompact
+	}
+	return opts
+}
+
+// informationalOutputOptions returns the output options for commands whose purpose
+// is not the secret itself; sensitive fields are redacted unless --show-secrets is set
+func informationalOutputOptions() output.Options {
+	opts := outputOptions()
+	opts.RedactSecrets = !showSecrets
+	return opts
+}
@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/auth"
+	"github.com/cogna-public/azure-login/internal/retry"
+	"github.com/cogna-public/azure-login/pkg/config"
+)
+
+// refreshAccessToken re-acquires a token for the identity and scope of a cached token.
+// A login with a certificate signs a new assertion with the recorded certificate (or
+// AZURE_CLIENT_CERTIFICATE_PATH when set); otherwise a fresh OIDC token is exchanged.
+// The login's authority tenant, if any, is used for the token endpoint again. It is a
+// variable so tests can simulate outages.
+var refreshAccessToken = func(ctx context.Context, token *config.SavedToken) (*auth.TokenResponse, error) {
+	azureCloud := tokenCloud(token)
+	scope := token.Scope
+	if scope == "" {
+		scope = azureCloud.ManagementScope()
+	}
+
+	client := auth.NewClientWithScope(token.TenantID, token.ClientID, token.SubscriptionID, scope)
+	client.SetCloud(azureCloud)
+	if token.AuthorityTenantID != "" {
+		client.SetAuthorityTenant(token.AuthorityTenantID)
+	}
+
+	var refreshed *auth.TokenResponse
+	certificatePath := os.Getenv(auth.CertificatePathEnvVar)
+	if certificatePath == "" {
+		certificatePath = token.CertificatePath
+	}
+	if certificatePath != "" {
+		certificate, err := auth.LoadCertificateCredential(certificatePath, os.Getenv(auth.CertificatePasswordEnvVar))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		refreshed, err = client.ExchangeCertificate(ctx, certificate)
+		if err != nil {
+			return nil, err
+		}
+		refreshed.CertificatePath = certificatePath
+	} else {
+		oidcToken, err := auth.GetOIDCTokenForAudience(ctx, token.OIDCAudience)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get OIDC token: %w", err)
+		}
+		refreshed, err = client.ExchangeOIDCToken(ctx, oidcToken)
+		if err != nil {
+			return nil, err
+		}
+		refreshed.OIDCAudience = token.OIDCAudience
+	}
+	refreshed.AuthorityTenantID = token.AuthorityTenantID
+	return refreshed, nil
+}
+
+// checkRefreshTenant refuses to refresh a cached token for another tenant than the one
+// the environment's AZURE_TENANT_ID names, which would otherwise silently refresh the
+// wrong tenant's token. An explicit --tenant (override) takes precedence.
+func checkRefreshTenant(token *config.SavedToken, override string) error {
+	envTenantID := os.Getenv("AZURE_TENANT_ID")
+	if override != "" || envTenantID == "" || strings.EqualFold(envTenantID, token.TenantID) {
+		return nil
+	}
+	return fmt.Errorf("cached token belongs to tenant %s but AZURE_TENANT_ID is %s; run 'azure-login login' for that tenant, or pass --tenant to choose explicitly", token.TenantID, envTenantID)
+}
+
+// refreshOrExtend refreshes an expired cached token. If the refresh fails because AAD
+// is unreachable and the token is still within its extended validity (ext_expires_in),
+// the cached token is served with a warning instead.
+func refreshOrExtend(ctx context.Context, cfg *config.Config, token *config.SavedToken) (*config.SavedToken, error) {
+	refreshed, err := refreshAccessToken(ctx, token)
+	if err == nil {
+		return saveRefreshedToken(cfg, refreshed)
+	}
+
+	// Only network-level failures indicate an outage; auth errors must not be masked
+	if retry.IsRetryable(err) && time.Now().UTC().Before(token.ExtExpiresOn) {
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: token refresh failed (%v); using token within extended validity until %s\n",
+			err, token.ExtExpiresOn.Format(time.RFC3339))
+		return token, nil
+	}
+
+	return nil, fmt.Errorf("token expired and refresh failed: %w", err)
+}
+
+// refreshExpiredToken refreshes an expired cached token and caches the result
+func refreshExpiredToken(ctx context.Context, cfg *config.Config, token *config.SavedToken) (*config.SavedToken, error) {
+	refreshed, err := refreshAccessToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("token expired and refresh failed (re-authenticate with 'azure-login login', or pass --no-refresh to fail fast): %w", err)
+	}
+	return saveRefreshedToken(cfg, refreshed)
+}
+
+// saveRefreshedToken caches a refreshed token and returns it as stored
+func saveRefreshedToken(cfg *config.Config, refreshed *auth.TokenResponse) (*config.SavedToken, error) {
+	if err := cfg.SaveToken(refreshed); err != nil {
+		return nil, fmt.Errorf("failed to save token: %w", err)
+	}
+	return cfg.LoadToken()
+}
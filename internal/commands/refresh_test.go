@@ -0,0 +1,327 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/auth"
+	"github.com/cogna-public/azure-login/internal/httplog"
+	"github.com/cogna-public/azure-login/pkg/config"
+)
+
+func saveExpiredTokenWithExtendedValidity(t *testing.T, extExpiresIn time.Duration) {
+	t.Helper()
+	cfg := config.NewConfig()
+	testToken := &auth.TokenResponse{
+		AccessToken:    "primary-expired-token",
+		TokenType:      "Bearer",
+		ExpiresOn:      time.Now().Add(-1 * time.Minute),
+		ExtExpiresOn:   time.Now().Add(extExpiresIn),
+		TenantID:       "test-tenant",
+		ClientID:       "test-client",
+		SubscriptionID: "test-subscription",
+	}
+	if err := cfg.SaveToken(testToken); err != nil {
+		t.Fatalf("Failed to save test token: %v", err)
+	}
+}
+
+func TestGetAccessToken_ExtendedValidityDuringOutage(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	saveExpiredTokenWithExtendedValidity(t, 1*time.Hour)
+
+	original := refreshAccessToken
+	refreshAccessToken = func(ctx context.Context, token *config.SavedToken) (*auth.TokenResponse, error) {
+		return nil, &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	}
+	defer func() { refreshAccessToken = original }()
+
+	cmd := accountGetAccessTokenCmd
+	outputFormat = "json"
+	queryString = ""
+
+	// Without the flag a failed refresh rejects the expired token
+	if err := cmd.RunE(cmd, []string{}); err == nil {
+		t.Fatal("Expected error without --allow-extended-validity, got none")
+	}
+
+	allowExtendedValidity = true
+	defer func() { allowExtendedValidity = false }()
+
+	var runErr error
+	out := captureStdout(t, func() {
+		runErr = cmd.RunE(cmd, []string{})
+	})
+	if runErr != nil {
+		t.Fatalf("Expected token to be served within extended validity, got: %v", runErr)
+	}
+	if !strings.Contains(out, "primary-expired-token") {
+		t.Errorf("Expected cached token in output, got: %s", out)
+	}
+}
+
+func TestGetAccessToken_ExtendedValidityExpired(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	saveExpiredTokenWithExtendedValidity(t, -1*time.Minute)
+
+	original := refreshAccessToken
+	refreshAccessToken = func(ctx context.Context, token *config.SavedToken) (*auth.TokenResponse, error) {
+		return nil, &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	}
+	defer func() { refreshAccessToken = original }()
+
+	allowExtendedValidity = true
+	defer func() { allowExtendedValidity = false }()
+
+	cmd := accountGetAccessTokenCmd
+	if err := cmd.RunE(cmd, []string{}); err == nil {
+		t.Fatal("Expected error when extended validity has also passed, got none")
+	}
+}
+
+func TestGetAccessToken_ExtendedValidityNotUsedForAuthErrors(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	saveExpiredTokenWithExtendedValidity(t, 1*time.Hour)
+
+	original := refreshAccessToken
+	refreshAccessToken = func(ctx context.Context, token *config.SavedToken) (*auth.TokenResponse, error) {
+		return nil, fmt.Errorf("authentication failed: invalid_client")
+	}
+	defer func() { refreshAccessToken = original }()
+
+	allowExtendedValidity = true
+	defer func() { allowExtendedValidity = false }()
+
+	cmd := accountGetAccessTokenCmd
+	err := cmd.RunE(cmd, []string{})
+	if err == nil {
+		t.Fatal("Expected error for non-network refresh failure, got none")
+	}
+	if !strings.Contains(err.Error(), "refresh failed") {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+}
+
+func TestGetAccessToken_RefreshSucceeds(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	saveExpiredTokenWithExtendedValidity(t, 1*time.Hour)
+
+	original := refreshAccessToken
+	refreshAccessToken = func(ctx context.Context, token *config.SavedToken) (*auth.TokenResponse, error) {
+		return &auth.TokenResponse{
+			AccessToken:    "refreshed-token",
+			TokenType:      "Bearer",
+			ExpiresOn:      time.Now().Add(1 * time.Hour),
+			TenantID:       token.TenantID,
+			ClientID:       token.ClientID,
+			SubscriptionID: token.SubscriptionID,
+		}, nil
+	}
+	defer func() { refreshAccessToken = original }()
+
+	allowExtendedValidity = true
+	defer func() { allowExtendedValidity = false }()
+
+	cmd := accountGetAccessTokenCmd
+	outputFormat = "json"
+	queryString = ""
+
+	var runErr error
+	out := captureStdout(t, func() {
+		runErr = cmd.RunE(cmd, []string{})
+	})
+	if runErr != nil {
+		t.Fatalf("Expected refresh to succeed, got: %v", runErr)
+	}
+	if !strings.Contains(out, "refreshed-token") {
+		t.Errorf("Expected refreshed token in output, got: %s", out)
+	}
+}
+
+func TestGetAccessToken_AutoRefreshesExpiredToken(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	saveExpiredTokenWithExtendedValidity(t, 0)
+
+	var refreshedFor *config.SavedToken
+	original := refreshAccessToken
+	refreshAccessToken = func(ctx context.Context, token *config.SavedToken) (*auth.TokenResponse, error) {
+		refreshedFor = token
+		return &auth.TokenResponse{
+			AccessToken:    "auto-refreshed-token",
+			TokenType:      "Bearer",
+			ExpiresOn:      time.Now().Add(1 * time.Hour),
+			TenantID:       token.TenantID,
+			ClientID:       token.ClientID,
+			SubscriptionID: token.SubscriptionID,
+		}, nil
+	}
+	defer func() { refreshAccessToken = original }()
+
+	cmd := accountGetAccessTokenCmd
+	outputFormat = "json"
+	queryString = ""
+
+	var runErr error
+	out := captureStdout(t, func() {
+		runErr = cmd.RunE(cmd, []string{})
+	})
+	if runErr != nil {
+		t.Fatalf("Expected automatic refresh to succeed, got: %v", runErr)
+	}
+	if refreshedFor == nil || refreshedFor.ClientID != "test-client" || refreshedFor.TenantID != "test-tenant" {
+		t.Errorf("Expected refresh with the saved identity, got: %+v", refreshedFor)
+	}
+	if !strings.Contains(out, "auto-refreshed-token") {
+		t.Errorf("Expected refreshed token in output, got: %s", out)
+	}
+
+	// The refreshed token is persisted for subsequent commands
+	saved, err := config.NewConfig().LoadToken()
+	if err != nil {
+		t.Fatalf("Failed to load token: %v", err)
+	}
+	if saved.AccessToken != "auto-refreshed-token" {
+		t.Errorf("Expected refreshed token to be cached, got %s", saved.AccessToken)
+	}
+}
+
+func TestGetAccessToken_NoRefreshFailsFast(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	saveExpiredTokenWithExtendedValidity(t, 0)
+
+	original := refreshAccessToken
+	refreshAccessToken = func(ctx context.Context, token *config.SavedToken) (*auth.TokenResponse, error) {
+		t.Error("Expected no refresh with --no-refresh")
+		return nil, fmt.Errorf("unexpected refresh")
+	}
+	defer func() { refreshAccessToken = original }()
+
+	noRefresh = true
+	defer func() { noRefresh = false }()
+
+	cmd := accountGetAccessTokenCmd
+	err := cmd.RunE(cmd, []string{})
+	if err == nil || !strings.Contains(err.Error(), "expiring soon") {
+		t.Errorf("Expected fail-fast expiry error, got: %v", err)
+	}
+}
+
+func TestGetAccessToken_RefreshTenantMismatch(t *testing.T) {
+	_ = setupTestConfig(t)
+	defer cleanupTestConfig()
+	saveExpiredTokenWithExtendedValidity(t, 0)
+	t.Setenv("AZURE_TENANT_ID", "other-tenant")
+
+	refreshed := false
+	original := refreshAccessToken
+	refreshAccessToken = func(ctx context.Context, token *config.SavedToken) (*auth.TokenResponse, error) {
+		refreshed = true
+		return nil, fmt.Errorf("unexpected refresh")
+	}
+	defer func() { refreshAccessToken = original }()
+
+	cmd := accountGetAccessTokenCmd
+	outputFormat = "json"
+	queryString = ""
+
+	err := cmd.RunE(cmd, []string{})
+	if err == nil || !strings.Contains(err.Error(), "tenant test-tenant but AZURE_TENANT_ID is other-tenant") {
+		t.Errorf("Expected tenant mismatch error, got: %v", err)
+	}
+	if refreshed {
+		t.Error("Expected no refresh for a mismatched tenant")
+	}
+
+	// A matching environment (compared case-insensitively) or an explicit --tenant is accepted
+	token := &config.SavedToken{TenantID: "Test-Tenant"}
+	if err := checkRefreshTenant(token, ""); err == nil {
+		t.Error("Expected mismatch with AZURE_TENANT_ID=other-tenant")
+	}
+	if err := checkRefreshTenant(token, "test-tenant"); err != nil {
+		t.Errorf("Expected --tenant to override the check, got: %v", err)
+	}
+	t.Setenv("AZURE_TENANT_ID", "test-tenant")
+	if err := checkRefreshTenant(token, ""); err != nil {
+		t.Errorf("Expected matching tenant to pass, got: %v", err)
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestRefreshAccessToken_UsesAuthorityTenant(t *testing.T) {
+	t.Setenv("GITLAB_CI", "")
+	t.Setenv(auth.AzureDevOpsCollectionURIEnvVar, "")
+	t.Setenv(auth.CertificatePathEnvVar, "")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "request-token")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", "https://token.actions.example/oidc")
+
+	var tokenPaths []string
+	httplog.SetDefault(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := `{"value":"github-oidc-token"}`
+		if req.URL.Host == "login.microsoftonline.com" {
+			tokenPaths = append(tokenPaths, req.URL.Path)
+			body = `{"access_token":"refreshed-token","token_type":"Bearer","expires_in":3600}`
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	}))
+	defer httplog.SetDefault(nil)
+
+	refreshed, err := refreshAccessToken(context.Background(), &config.SavedToken{
+		TenantID:          "home-tenant",
+		AuthorityTenantID: "resource-tenant",
+		ClientID:          "test-client",
+		SubscriptionID:    "test-subscription",
+	})
+	if err != nil {
+		t.Fatalf("Expected refresh to succeed, got: %v", err)
+	}
+
+	if len(tokenPaths) != 1 || tokenPaths[0] != "/resource-tenant/oauth2/v2.0/token" {
+		t.Errorf("Expected the authority tenant's token endpoint, got: %v", tokenPaths)
+	}
+	if refreshed.TenantID != "home-tenant" || refreshed.AuthorityTenantID != "resource-tenant" {
+		t.Errorf("Expected home tenant home-tenant and authority resource-tenant, got %s and %s", refreshed.TenantID, refreshed.AuthorityTenantID)
+	}
+}
+
+func TestRefreshAccessToken_UsesRecordedCertificate(t *testing.T) {
+	t.Setenv(auth.CertificatePathEnvVar, "")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", "")
+
+	missing := filepath.Join(t.TempDir(), "sp.pem")
+	_, err := refreshAccessToken(context.Background(), &config.SavedToken{
+		TenantID:        "test-tenant",
+		ClientID:        "test-client",
+		CertificatePath: missing,
+	})
+	if err == nil {
+		t.Fatal("Expected error for a missing certificate")
+	}
+	// The recorded certificate is used rather than falling back to an OIDC token
+	if !strings.Contains(err.Error(), "failed to load certificate") || !strings.Contains(err.Error(), "sp.pem") {
+		t.Errorf("Expected certificate load error, got: %v", err)
+	}
+}
@@ -1,12 +1,22 @@
 // Package commands implements the CLI command structure for azure-login.
 //
-// This package provides commands for authentication (login), account management
+// This package provides commands for authentication (login, logout), account management
 // (show, get-access-token), and version information using the Cobra CLI framework.
 package commands
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
 
+	"github.com/cogna-public/azure-login/internal/httplog"
+	"github.com/cogna-public/azure-login/internal/output"
+	"github.com/cogna-public/azure-login/internal/retry"
+	"github.com/cogna-public/azure-login/internal/useragent"
+	"github.com/cogna-public/azure-login/pkg/config"
 	"github.com/spf13/cobra"
 )
 
@@ -25,6 +35,115 @@ It provides a drop-in replacement for Azure CLI authentication commands
 in CI/CD environments, particularly GitHub Actions.`,
 	SilenceErrors: true,
 	SilenceUsage:  true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		invokedCommand = cmd.CommandPath()
+		config.SetAllowInsecureDir(allowInsecureDir)
+		if secretsToFileOnly && showSecrets {
+			return fmt.Errorf("--show-secrets cannot be used with --secrets-to-file-only")
+		}
+		labels, err := output.ParseLabels(outputLabels)
+		if err != nil {
+			return err
+		}
+		tableLabels = labels
+		if err := output.ValidateDurationFormat(durationFormat); err != nil {
+			return err
+		}
+
+		config.SetProfile(profileName)
+		if err := config.ValidateProfile(config.ActiveProfile()); err != nil {
+			return err
+		}
+
+		// Share a single retry budget across all network calls of this invocation
+		if budget := retry.LoadBudget(); budget != nil {
+			cmd.SetContext(retry.WithBudget(commandContext(cmd), budget))
+		}
+
+		cmd.SetContext(withRetryProgress(commandContext(cmd)))
+
+		// Identify azure-login on every request; tracing (if enabled) sits beneath
+		// so the recorded headers include the User-Agent
+		var transport http.RoundTripper = http.DefaultTransport
+		if traceFilePath != "" {
+			file, err := os.OpenFile(traceFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+			if err != nil {
+				return fmt.Errorf("failed to open trace file: %w", err)
+			}
+			traceFile = file
+			transport = httplog.NewTransport(nil, traceFile)
+		}
+		if debugHTTPEnabled() {
+			transport = httplog.NewDebugTransport(transport, os.Stderr)
+		}
+		httplog.SetDefault(useragent.NewTransport(transport, useragent.String(version)))
+		return nil
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if traceFile != nil {
+			_ = traceFile.Close()
+		}
+	},
+}
+
+var (
+	// traceFilePath records HTTP requests as JSON lines for support bundles (--trace-file)
+	traceFilePath string
+	traceFile     *os.File
+
+	// allowInsecureDir permits saving tokens in a config directory other users can access
+	allowInsecureDir bool
+
+	// profileName selects a named token profile (--profile)
+	profileName string
+
+	// onlyShowErrors suppresses retry progress on stderr (--only-show-errors)
+	onlyShowErrors bool
+
+	// verbose prints retry progress even when stderr is not a terminal (--verbose)
+	verbose bool
+
+	// debugHTTP logs a redacted summary of every HTTP request to stderr (--debug-http)
+	debugHTTP bool
+)
+
+// debugHTTPEnabled reports whether --debug-http or AZURE_LOGIN_DEBUG_HTTP is set
+func debugHTTPEnabled() bool {
+	if debugHTTP {
+		return true
+	}
+	enabled, err := strconv.ParseBool(os.Getenv(httplog.DebugEnvVar))
+	return err == nil && enabled
+}
+
+// stderrIsTerminal reports whether stderr is attached to a terminal.
+// It is a variable so tests can simulate interactive and non-interactive sessions.
+var stderrIsTerminal = func() bool {
+	info, err := os.Stderr.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// withRetryProgress attaches a hook printing a progress line to stderr on each retry,
+// so interactive users see why a command is slow. CI logs only get it with --verbose.
+func withRetryProgress(ctx context.Context) context.Context {
+	if onlyShowErrors || (!verbose && !stderrIsTerminal()) {
+		return ctx
+	}
+	return retry.WithOnRetry(ctx, func(attempt, maxAttempts int, err error, wait time.Duration) {
+		_, _ = fmt.Fprintf(os.Stderr, "attempt %d/%d failed: %v; retrying in %s\n", attempt, maxAttempts, err, formatDuration(wait.Round(time.Millisecond)))
+	})
+}
+
+// commandContext returns the command's context, falling back to context.Background()
+// when the command was not started through Execute (e.g. RunE called directly)
+func commandContext(cmd *cobra.Command) context.Context {
+	if cmd == nil || cmd.Context() == nil {
+		return context.Background()
+	}
+	return cmd.Context()
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -36,12 +155,30 @@ func Execute(v, c, d string) error {
 }
 
 func init() {
+	rootCmd.PersistentFlags().BoolVar(&debugHTTP, "debug-http", false, "Log method, URL, status and error codes of all HTTP requests to stderr, with secrets redacted (or set AZURE_LOGIN_DEBUG_HTTP=1)")
+	rootCmd.PersistentFlags().StringVar(&traceFilePath, "trace-file", "", "Append a redacted JSON-lines trace of all HTTP requests to this file")
+	rootCmd.PersistentFlags().BoolVar(&allowInsecureDir, "allow-insecure-dir", false, "Save tokens even if the config directory is accessible to other users (it is otherwise restricted to 0700 or the save is refused)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Named token profile, cached as azure-login-token-<profile>.json so several identities can be used side by side (default: $AZURE_LOGIN_PROFILE)")
+	rootCmd.PersistentFlags().BoolVar(&secretsToFileOnly, "secrets-to-file-only", false, "Refuse to print access or OIDC tokens to stdout; commands that output a token require --output-file")
+	rootCmd.PersistentFlags().BoolVar(&onlyShowErrors, "only-show-errors", false, "Suppress retry progress messages on stderr")
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Print retry progress to stderr even when it is not a terminal")
+	rootCmd.PersistentFlags().BoolVar(&compactJSON, "compact", false, "Print JSON on a single line (default in CI or when stdout is not a terminal)")
+	rootCmd.PersistentFlags().BoolVar(&prettyJSON, "pretty", false, "Print indented JSON (default in interactive terminals)")
+	rootCmd.MarkFlagsMutuallyExclusive("compact", "pretty")
+	rootCmd.PersistentFlags().BoolVar(&noHeaders, "no-headers", false, "Omit header rows from table output")
+	rootCmd.PersistentFlags().StringArrayVar(&outputLabels, "label", nil, "Rename a table column header, as key=Header (repeatable); applied after --query")
+	rootCmd.PersistentFlags().StringVar(&durationFormat, "duration-format", output.DurationHuman, "How durations in messages are printed: human (1h2m) or iso8601 (PT1H2M)")
+	rootCmd.PersistentFlags().StringSliceVar(&outputKeys, "keys", nil, "Comma-separated top-level keys to select from an object result, in order (a simpler alternative to --query)")
+	rootCmd.PersistentFlags().BoolVar(&annotateOutput, "annotate", false, "Wrap output in an envelope with the command, a UTC timestamp and the version (for audit logs)")
+
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
 	rootCmd.AddCommand(accountCmd)
 	rootCmd.AddCommand(aksCmd)
 	rootCmd.AddCommand(kubectlCredentialCmd)
 	rootCmd.AddCommand(oidcCmd)
+	rootCmd.AddCommand(doctorCmd)
 }
 
 var versionCmd = &cobra.Command{
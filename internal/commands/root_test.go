@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/output"
+	"github.com/cogna-public/azure-login/internal/retry"
+)
+
+func captureStderr(t *testing.T, f func()) string {
+	t.Helper()
+	old := os.Stderr
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	os.Stderr = w
+
+	f()
+
+	_ = w.Close()
+	os.Stderr = old
+
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+	return buf.String()
+}
+
+func TestWithRetryProgress(t *testing.T) {
+	originalIsTerminal := stderrIsTerminal
+	defer func() {
+		stderrIsTerminal = originalIsTerminal
+		onlyShowErrors = false
+		verbose = false
+	}()
+
+	cfg := &retry.Config{
+		MaxAttempts:       3,
+		InitialDelay:      2 * time.Millisecond,
+		MaxDelay:          10 * time.Millisecond,
+		BackoffMultiplier: 2.0,
+	}
+	runFailing := func() string {
+		return captureStderr(t, func() {
+			_ = cfg.Do(withRetryProgress(context.Background()), func() error {
+				return &net.OpError{Op: "dial", Err: syscall.ECONNRESET}
+			})
+		})
+	}
+
+	stderrIsTerminal = func() bool { return true }
+	lines := strings.Split(strings.TrimSpace(runFailing()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 progress lines, got %d: %q", len(lines), lines)
+	}
+	if !strings.HasPrefix(lines[0], "attempt 1/3 failed: dial: connection reset by peer; retrying in 2ms") {
+		t.Errorf("Unexpected first progress line: %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "attempt 2/3 failed: ") || !strings.HasSuffix(lines[1], "retrying in 4ms") {
+		t.Errorf("Unexpected second progress line: %q", lines[1])
+	}
+
+	onlyShowErrors = true
+	if out := runFailing(); out != "" {
+		t.Errorf("Expected no progress with --only-show-errors, got %q", out)
+	}
+
+	onlyShowErrors = false
+	stderrIsTerminal = func() bool { return false }
+	if out := runFailing(); out != "" {
+		t.Errorf("Expected no progress when stderr is not a terminal, got %q", out)
+	}
+
+	verbose = true
+	if out := runFailing(); !strings.Contains(out, "attempt 1/3 failed") {
+		t.Errorf("Expected progress with --verbose, got %q", out)
+	}
+
+	durationFormat = output.DurationISO8601
+	defer func() { durationFormat = output.DurationHuman }()
+	if out := runFailing(); !strings.Contains(out, "retrying in PT0.002S") {
+		t.Errorf("Expected ISO 8601 retry delay with --duration-format iso8601, got %q", out)
+	}
+}
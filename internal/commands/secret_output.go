@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// secretsToFileOnly is a compliance policy that refuses to print tokens to stdout,
+// where CI systems capture them in logs (--secrets-to-file-only)
+var secretsToFileOnly bool
+
+// checkSecretToStdout fails when --secrets-to-file-only forbids printing a token
+// to stdout. Commands that write secrets call it before doing any work.
+func checkSecretToStdout(cmd *cobra.Command) error {
+	if !secretsToFileOnly {
+		return nil
+	}
+	if cmd != nil && cmd.Flags().Lookup("output-file") != nil {
+		return fmt.Errorf("refusing to print a token to stdout with --secrets-to-file-only; write it to a file with --output-file")
+	}
+	return fmt.Errorf("refusing to print a token to stdout with --secrets-to-file-only")
+}
+
+// writeSecretFile writes secret to path with 0600 permissions. A temp file in the same
+// directory is renamed into place, so readers never observe a partial token.
+func writeSecretFile(path, secret string) error {
+	// os.CreateTemp creates the file with 0600 permissions
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	tmpPath := tmpFile.Name()
+	_, writeErr := tmpFile.WriteString(secret)
+	closeErr := tmpFile.Close()
+	if writeErr != nil || closeErr != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to write %s: %w", path, errors.Join(writeErr, closeErr))
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath) // Clean up temp file on error
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
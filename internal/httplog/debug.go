@@ -0,0 +1,122 @@
+package httplog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DebugEnvVar enables --debug-http when set to a true value
+const DebugEnvVar = "AZURE_LOGIN_DEBUG_HTTP"
+
+// DebugTransport writes one human-readable line per round trip (method, URL, status
+// and the error codes of failed responses) to W, for --debug-http. Bodies and headers
+// are never printed; sensitive query parameters are redacted from the URL.
+type DebugTransport struct {
+	// Base is the underlying transport (http.DefaultTransport if nil)
+	Base http.RoundTripper
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewDebugTransport creates a debug transport writing to w
+func NewDebugTransport(base http.RoundTripper, w io.Writer) *DebugTransport {
+	return &DebugTransport{Base: base, w: w}
+}
+
+// RoundTrip executes the request via the base transport and logs a summary line
+func (t *DebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	prefix := fmt.Sprintf("HTTP %s %s", req.Method, redactURL(req.URL))
+	if err != nil {
+		t.printf("%s -> error after %s: %v\n", prefix, elapsed, err)
+		return nil, err
+	}
+
+	line := fmt.Sprintf("%s -> %d (%s)", prefix, resp.StatusCode, elapsed)
+	if resp.StatusCode >= 400 && resp.Body != nil {
+		body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxBodyCapture))
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		if readErr == nil {
+			if codes := responseErrorCodes(body); len(codes) > 0 {
+				line += " " + strings.Join(codes, " ")
+			}
+		}
+	}
+	t.printf("%s\n", line)
+	return resp, nil
+}
+
+// printf writes a debug line; logging failures never fail the request
+func (t *DebugTransport) printf(format string, args ...any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, _ = fmt.Fprintf(t.w, format, args...)
+}
+
+// readCloser replays a captured body prefix while closing the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// redactURL returns the URL with credentials and sensitive query parameters redacted
+func redactURL(u *url.URL) string {
+	redacted := *u
+	if redacted.User != nil {
+		redacted.User = url.User(redactedValue)
+	}
+	if redacted.RawQuery != "" {
+		query := redacted.Query()
+		for key := range query {
+			if sensitiveFields[key] {
+				query.Set(key, redactedValue)
+			}
+		}
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.String()
+}
+
+// responseErrorCodes extracts error codes from an Entra ID ("error", "error_codes")
+// or ARM ("error": {"code": ...}) error response. Descriptions are not included.
+func responseErrorCodes(body []byte) []string {
+	var response struct {
+		Error      json.RawMessage `json:"error"`
+		ErrorCodes []int           `json:"error_codes"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil
+	}
+
+	var codes []string
+	var code string
+	var armError struct {
+		Code string `json:"code"`
+	}
+	if json.Unmarshal(response.Error, &code) == nil && code != "" {
+		codes = append(codes, code)
+	} else if json.Unmarshal(response.Error, &armError) == nil && armError.Code != "" {
+		codes = append(codes, armError.Code)
+	}
+	for _, errorCode := range response.ErrorCodes {
+		codes = append(codes, "AADSTS"+strconv.Itoa(errorCode))
+	}
+	return codes
+}
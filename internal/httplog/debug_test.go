@@ -0,0 +1,78 @@
+package httplog
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDebugTransport_RedactsSecrets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"AADSTS700016: bad assertion","error_codes":[700016],"access_token":"secret-access-token"}`))
+	}))
+	defer server.Close()
+
+	var logged bytes.Buffer
+	client := &http.Client{Transport: NewDebugTransport(nil, &logged)}
+
+	form := url.Values{}
+	form.Set("client_id", "test-client")
+	form.Set("client_assertion", "secret-oidc-assertion")
+	form.Set("client_secret", "secret-client-secret")
+	req, err := http.NewRequest("POST", server.URL+"/token?access_token=secret-query-token&api-version=1", strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer secret-bearer-token")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	// The caller still receives the full response body
+	if !strings.Contains(string(body), "AADSTS700016: bad assertion") {
+		t.Errorf("Expected caller to receive original body, got %s", body)
+	}
+
+	output := logged.String()
+	for _, secret := range []string{"secret-oidc-assertion", "secret-client-secret", "secret-access-token", "secret-query-token", "secret-bearer-token"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("Expected %s to be redacted from debug output, got: %s", secret, output)
+		}
+	}
+	for _, want := range []string{"HTTP POST " + server.URL + "/token?", "api-version=1", "-> 400", "invalid_client AADSTS700016"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected debug output to contain %q, got: %s", want, output)
+		}
+	}
+}
+
+func TestDebugTransport_LogsErrors(t *testing.T) {
+	var logged bytes.Buffer
+	client := &http.Client{Transport: NewDebugTransport(nil, &logged)}
+
+	_, err := client.Get("http://127.0.0.1:1/unreachable")
+	if err == nil {
+		t.Fatal("Expected request to an unreachable address to fail")
+	}
+	if !strings.Contains(logged.String(), "HTTP GET http://127.0.0.1:1/unreachable -> error") {
+		t.Errorf("Expected error line, got: %s", logged.String())
+	}
+}
+
+func TestResponseErrorCodes_ARM(t *testing.T) {
+	codes := responseErrorCodes([]byte(`{"error":{"code":"AuthorizationFailed","message":"no access"}}`))
+	if len(codes) != 1 || codes[0] != "AuthorizationFailed" {
+		t.Errorf("Expected [AuthorizationFailed], got %v", codes)
+	}
+}
@@ -0,0 +1,232 @@
+// Package httplog provides an http.RoundTripper that records HTTP exchanges with
+// secrets redacted, for shareable support bundles (--trace-file).
+//
+// Each request/response pair is written as a single JSON line. Authorization headers,
+// credential form fields and token-bearing JSON fields are always redacted.
+package httplog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxBodyCapture is the maximum number of body bytes recorded per request/response
+	maxBodyCapture = 64 * 1024
+
+	// redactedValue replaces secrets in recorded output
+	redactedValue = "[redacted]"
+)
+
+// sensitiveHeaders are redacted in recorded headers (canonical form)
+var sensitiveHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+}
+
+// sensitiveFields are redacted in form and JSON bodies
+var sensitiveFields = map[string]bool{
+	"access_token":     true,
+	"accessToken":      true,
+	"refresh_token":    true,
+	"id_token":         true,
+	"client_assertion": true,
+	"client_secret":    true,
+	"oidcToken":        true, // Azure DevOps federation token
+	"token":            true,
+	"value":            true,
+}
+
+// Record is a single traced HTTP exchange
+type Record struct {
+	Time            time.Time         `json:"time"`
+	Method          string            `json:"method"`
+	URL             string            `json:"url"`
+	Status          int               `json:"status,omitempty"`
+	DurationMs      int64             `json:"durationMs"`
+	RequestHeaders  map[string]string `json:"requestHeaders,omitempty"`
+	RequestBody     string            `json:"requestBody,omitempty"`
+	ResponseHeaders map[string]string `json:"responseHeaders,omitempty"`
+	ResponseBody    string            `json:"responseBody,omitempty"`
+	Error           string            `json:"error,omitempty"`
+}
+
+// Transport records every round trip to W as a JSON line
+type Transport struct {
+	// Base is the underlying transport (http.DefaultTransport if nil)
+	Base http.RoundTripper
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewTransport creates a recording transport writing to w
+func NewTransport(base http.RoundTripper, w io.Writer) *Transport {
+	return &Transport{Base: base, w: w}
+}
+
+// RoundTrip executes the request via the base transport and records the exchange
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	record := Record{
+		Time:           time.Now().UTC(),
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		RequestHeaders: redactHeaders(req.Header),
+	}
+
+	// Capture the request body, restoring it for the base transport
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		record.RequestBody = redactBody(body, req.Header.Get("Content-Type"))
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	record.DurationMs = time.Since(start).Milliseconds()
+
+	if err != nil {
+		record.Error = err.Error()
+		t.write(record)
+		return nil, err
+	}
+
+	record.Status = resp.StatusCode
+	record.ResponseHeaders = redactHeaders(resp.Header)
+
+	// Capture the response body, restoring it for the caller
+	if resp.Body != nil {
+		body, readErr := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		if readErr != nil {
+			record.Error = readErr.Error()
+		}
+		record.ResponseBody = redactBody(body, resp.Header.Get("Content-Type"))
+	}
+
+	t.write(record)
+	return resp, nil
+}
+
+// write serializes a record as a JSON line; trace failures never fail the request
+func (t *Transport) write(record Record) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, _ = t.w.Write(append(data, '\n'))
+}
+
+// redactHeaders flattens headers, redacting sensitive values
+func redactHeaders(header http.Header) map[string]string {
+	if len(header) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(header))
+	for name, values := range header {
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			result[name] = redactedValue
+			continue
+		}
+		result[name] = strings.Join(values, ", ")
+	}
+	return result
+}
+
+// redactBody returns a printable body with sensitive form and JSON fields redacted.
+// Bodies that cannot be parsed are omitted entirely rather than risk leaking secrets.
+func redactBody(body []byte, contentType string) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if len(body) > maxBodyCapture {
+		return "[body omitted: too large]"
+	}
+
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "[body omitted: unparseable form]"
+		}
+		for key := range values {
+			if sensitiveFields[key] {
+				values.Set(key, redactedValue)
+			}
+		}
+		return values.Encode()
+	}
+
+	var data any
+	if err := json.Unmarshal(body, &data); err != nil {
+		return "[body omitted: not JSON]"
+	}
+	redacted, err := json.Marshal(redactJSON(data))
+	if err != nil {
+		return "[body omitted]"
+	}
+	return string(redacted)
+}
+
+// redactJSON replaces sensitive fields in decoded JSON
+func redactJSON(data any) any {
+	switch v := data.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if sensitiveFields[key] {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactJSON(value)
+			}
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = redactJSON(value)
+		}
+		return v
+	default:
+		return data
+	}
+}
+
+var (
+	defaultMu        sync.RWMutex
+	defaultTransport http.RoundTripper
+)
+
+// SetDefault sets the transport returned by Default (nil restores http.DefaultTransport)
+func SetDefault(rt http.RoundTripper) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultTransport = rt
+}
+
+// Default returns the transport HTTP clients in this module should use
+func Default() http.RoundTripper {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	if defaultTransport == nil {
+		return http.DefaultTransport
+	}
+	return defaultTransport
+}
@@ -0,0 +1,157 @@
+package httplog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTransport_WritesRedactedTraceFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"access_token":"secret-access-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	tracePath := filepath.Join(t.TempDir(), "trace.jsonl")
+	traceFile, err := os.OpenFile(tracePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("Failed to open trace file: %v", err)
+	}
+
+	client := &http.Client{Transport: NewTransport(nil, traceFile)}
+
+	form := url.Values{}
+	form.Set("client_id", "test-client")
+	form.Set("client_assertion", "secret-oidc-assertion")
+	req, err := http.NewRequest("POST", server.URL+"/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer secret-bearer-token")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	_ = traceFile.Close()
+
+	// The caller still receives the full, unredacted response
+	if !strings.Contains(string(body), "secret-access-token") {
+		t.Errorf("Expected caller to receive original body, got %s", body)
+	}
+
+	data, err := os.ReadFile(tracePath)
+	if err != nil {
+		t.Fatalf("Failed to read trace file: %v", err)
+	}
+	for _, secret := range []string{"secret-access-token", "secret-oidc-assertion", "secret-bearer-token"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Expected %s to be redacted from trace, got: %s", secret, data)
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	var records []Record
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Failed to parse trace line: %v", err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 trace record, got %d", len(records))
+	}
+
+	record := records[0]
+	if record.Method != "POST" {
+		t.Errorf("Expected method POST, got %s", record.Method)
+	}
+	if record.Status != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", record.Status)
+	}
+	if !strings.HasSuffix(record.URL, "/token") {
+		t.Errorf("Expected URL to end with /token, got %s", record.URL)
+	}
+	if record.RequestHeaders["Authorization"] != redactedValue {
+		t.Errorf("Expected Authorization header to be redacted, got %s", record.RequestHeaders["Authorization"])
+	}
+	if !strings.Contains(record.RequestBody, "client_id=test-client") {
+		t.Errorf("Expected non-sensitive form fields to be kept, got %s", record.RequestBody)
+	}
+	if !strings.Contains(record.ResponseBody, "Bearer") {
+		t.Errorf("Expected non-sensitive response fields to be kept, got %s", record.ResponseBody)
+	}
+}
+
+func TestTransport_RedactsAzureDevOpsOIDCToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"oidcToken":"secret-federated-assertion"}`))
+	}))
+	defer server.Close()
+
+	var buf strings.Builder
+	client := &http.Client{Transport: NewTransport(nil, &buf)}
+
+	resp, err := client.Post(server.URL+"/_apis/distributedtask/hubs/build/plans/plan/jobs/job/oidctoken?serviceConnectionId=id", "application/json", nil)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	var record Record
+	if err := json.Unmarshal([]byte(buf.String()), &record); err != nil {
+		t.Fatalf("Failed to parse trace line: %v", err)
+	}
+	if strings.Contains(buf.String(), "secret-federated-assertion") {
+		t.Errorf("Expected the Azure DevOps OIDC token to be redacted, got: %s", buf.String())
+	}
+	if !strings.Contains(record.ResponseBody, `"oidcToken":"`+redactedValue+`"`) {
+		t.Errorf("Expected a redacted oidcToken field, got %s", record.ResponseBody)
+	}
+}
+
+func TestTransport_RecordsErrors(t *testing.T) {
+	var buf strings.Builder
+	client := &http.Client{Transport: NewTransport(nil, &buf)}
+
+	_, err := client.Get("http://127.0.0.1:1/unreachable")
+	if err == nil {
+		t.Fatal("Expected connection error, got none")
+	}
+
+	var record Record
+	if err := json.Unmarshal([]byte(buf.String()), &record); err != nil {
+		t.Fatalf("Failed to parse trace line: %v", err)
+	}
+	if record.Error == "" {
+		t.Error("Expected error to be recorded")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	if Default() != http.DefaultTransport {
+		t.Error("Expected http.DefaultTransport when no default is set")
+	}
+
+	transport := NewTransport(nil, io.Discard)
+	SetDefault(transport)
+	defer SetDefault(nil)
+
+	if Default() != transport {
+		t.Error("Expected configured default transport")
+	}
+}
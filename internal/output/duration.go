@@ -0,0 +1,70 @@
+package output
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration formats selectable with --duration-format
+const (
+	// DurationHuman prints Go-style durations without trailing zero units (1h2m)
+	DurationHuman = "human"
+
+	// DurationISO8601 prints ISO 8601 durations (PT1H2M)
+	DurationISO8601 = "iso8601"
+)
+
+// ValidateDurationFormat checks that format is a supported duration format
+func ValidateDurationFormat(format string) error {
+	switch format {
+	case DurationHuman, DurationISO8601:
+		return nil
+	}
+	return fmt.Errorf("unsupported duration format %q (supported: %s, %s)", format, DurationHuman, DurationISO8601)
+}
+
+// FormatDuration renders d in the given format; an unknown format falls back to
+// DurationHuman
+func FormatDuration(d time.Duration, format string) string {
+	if format == DurationISO8601 {
+		return iso8601Duration(d)
+	}
+
+	human := d.String()
+	if strings.HasSuffix(human, "m0s") {
+		human = strings.TrimSuffix(human, "0s")
+	}
+	if strings.HasSuffix(human, "h0m") {
+		human = strings.TrimSuffix(human, "0m")
+	}
+	return human
+}
+
+// iso8601Duration renders d as an ISO 8601 time duration with hours as the largest
+// unit (days are not fixed-length), e.g. PT36H, PT1M30S or PT0.25S
+func iso8601Duration(d time.Duration) string {
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	b.WriteString("PT")
+
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+	d -= minutes * time.Minute
+
+	if hours > 0 {
+		b.WriteString(strconv.FormatInt(int64(hours), 10) + "H")
+	}
+	if minutes > 0 {
+		b.WriteString(strconv.FormatInt(int64(minutes), 10) + "M")
+	}
+	if d > 0 || (hours == 0 && minutes == 0) {
+		b.WriteString(strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S")
+	}
+	return b.String()
+}
@@ -0,0 +1,43 @@
+package output
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		human    string
+		iso8601  string
+	}{
+		{time.Hour + 2*time.Minute, "1h2m", "PT1H2M"},
+		{time.Hour, "1h", "PT1H"},
+		{90 * time.Second, "1m30s", "PT1M30S"},
+		{36*time.Hour + 5*time.Second, "36h0m5s", "PT36H5S"},
+		{1234 * time.Millisecond, "1.234s", "PT1.234S"},
+		{250 * time.Millisecond, "250ms", "PT0.25S"},
+		{0, "0s", "PT0S"},
+		{-2 * time.Minute, "-2m", "-PT2M"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatDuration(tt.duration, DurationHuman); got != tt.human {
+			t.Errorf("FormatDuration(%v, human) = %q, want %q", tt.duration, got, tt.human)
+		}
+		if got := FormatDuration(tt.duration, DurationISO8601); got != tt.iso8601 {
+			t.Errorf("FormatDuration(%v, iso8601) = %q, want %q", tt.duration, got, tt.iso8601)
+		}
+	}
+}
+
+func TestValidateDurationFormat(t *testing.T) {
+	for _, format := range []string{DurationHuman, DurationISO8601} {
+		if err := ValidateDurationFormat(format); err != nil {
+			t.Errorf("Expected %s to be accepted, got: %v", format, err)
+		}
+	}
+	if err := ValidateDurationFormat("seconds"); err == nil {
+		t.Error("Expected an unsupported format to be rejected")
+	}
+}
@@ -1,54 +1,423 @@
 // Package output provides output formatting functionality for azure-login commands.
 //
-// This package supports multiple output formats (JSON, TSV, table) and JMESPath
+// This package supports multiple output formats (JSON, NDJSON, YAML, TSV, table, detail, value) and JMESPath
 // queries for filtering and transforming command output, compatible with Azure CLI
 // output conventions.
 package output
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jmespath/go-jmespath"
+	"gopkg.in/yaml.v3"
 )
 
+// Options controls optional output behaviour
+type Options struct {
+	// StrictQuery makes a query that yields null or an empty result an error,
+	// so typos in --query fail loudly instead of printing nothing
+	StrictQuery bool
+
+	// RedactSecrets replaces the values of sensitive fields (accessToken, token, value)
+	// with a placeholder. Used for informational output that is often pasted into
+	// logs or tickets; commands whose purpose is the secret leave it disabled.
+	RedactSecrets bool
+
+	// JSONStyle selects pretty or compact JSON; JSONAuto picks based on the environment
+	JSONStyle JSONStyle
+
+	// NoHeaders omits the header rows of table output (like kubectl --no-headers)
+	NoHeaders bool
+
+	// Labels renames table column headers, keyed by the column's key (--label key=Header)
+	Labels map[string]string
+
+	// Annotation, when set, wraps the (queried) output in a provenance envelope
+	Annotation *Annotation
+
+	// Keys projects these top-level keys of an object result, in order (--keys).
+	// It is a simpler alternative to a query and cannot be combined with one.
+	Keys []string
+}
+
+// Annotation describes the invocation that produced an output, for audit logs
+type Annotation struct {
+	// Command is the command path, e.g. "azure-login account show"
+	Command string
+
+	// Version is the azure-login version
+	Version string
+}
+
+// annotatableFormats are the output formats that can print the --annotate envelope
+var annotatableFormats = map[string]bool{"json": true, "ndjson": true, "yaml": true, "detail": true}
+
+// now returns the current time. It is a variable so tests can fix the envelope timestamp.
+var now = time.Now
+
+// annotate wraps data in an envelope with the command, a UTC timestamp and the version
+func annotate(data any, annotation *Annotation) map[string]any {
+	return map[string]any{
+		"command":   annotation.Command,
+		"timestamp": now().UTC().Format(time.RFC3339),
+		"version":   annotation.Version,
+		"data":      data,
+	}
+}
+
+// JSONStyle controls JSON indentation
+type JSONStyle int
+
+const (
+	// JSONAuto emits compact JSON in CI (CI=true) or when stdout is not a terminal,
+	// and pretty JSON otherwise
+	JSONAuto JSONStyle = iota
+
+	// JSONPretty always emits indented JSON
+	JSONPretty
+
+	// JSONCompact always emits single-line JSON
+	JSONCompact
+)
+
+// stdoutIsTerminal reports whether stdout is attached to a terminal.
+// It is a variable so tests can simulate interactive and non-interactive sessions.
+var stdoutIsTerminal = func() bool {
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// prettyJSON resolves a JSONStyle to whether JSON should be indented
+func prettyJSON(style JSONStyle) bool {
+	switch style {
+	case JSONPretty:
+		return true
+	case JSONCompact:
+		return false
+	}
+	if strings.EqualFold(os.Getenv("CI"), "true") {
+		return false
+	}
+	return stdoutIsTerminal()
+}
+
+// sensitiveFields lists the field names redacted when Options.RedactSecrets is set
+var sensitiveFields = []string{"accessToken", "token", "value"}
+
+// RedactedValue replaces sensitive values in redacted output
+const RedactedValue = "[redacted]"
+
+// ErrFalseResult is returned by the exitcode format for a falsey result. The CLI exits
+// with status 1 without printing an error.
+var ErrFalseResult = errors.New("result is false")
+
 // Print outputs data in the specified format
 func Print(data any, format string, query string) error {
+	return PrintWithOptions(data, format, query, Options{})
+}
+
+// PrintWithOptions outputs data in the specified format with additional options
+func PrintWithOptions(data any, format string, query string, opts Options) error {
+	// The envelope is a nested object, which only structured formats can carry
+	if opts.Annotation != nil && !annotatableFormats[strings.ToLower(format)] {
+		return fmt.Errorf("--annotate requires -o json, ndjson, yaml or detail, not %s", format)
+	}
+
+	// Redact before querying so a query cannot select the raw secret
+	if opts.RedactSecrets {
+		data = redact(data)
+	}
+
+	if len(opts.Keys) > 0 {
+		if query != "" {
+			return fmt.Errorf("--keys and --query are mutually exclusive")
+		}
+		projected, err := projectKeys(data, opts.Keys)
+		if err != nil {
+			return err
+		}
+		data = projected
+	}
+
 	// Apply JMESPath query if provided
 	if query != "" {
 		result, err := jmespath.Search(query, data)
 		if err != nil {
 			return fmt.Errorf("invalid query: %w", err)
 		}
+		if opts.StrictQuery && isEmptyResult(result) {
+			return fmt.Errorf("query %q returned no result", query)
+		}
 		data = result
 	}
 
+	// exitcode prints nothing; the (queried) result only decides the exit status
+	if strings.EqualFold(format, "exitcode") {
+		if isFalsey(data) {
+			return ErrFalseResult
+		}
+		return nil
+	}
+
+	// The envelope is added after the query so queries address the bare data
+	if opts.Annotation != nil {
+		data = annotate(data, opts.Annotation)
+	}
+
 	// Output in requested format
 	switch strings.ToLower(format) {
 	case "json":
-		return printJSON(data)
+		return printJSON(data, prettyJSON(opts.JSONStyle))
 	case "tsv":
 		return printTSV(data)
 	case "table":
-		return printTable(data)
+		return printTable(data, opts)
+	case "ndjson":
+		return printNDJSON(data)
+	case "detail":
+		return printDetail(data)
+	case "yaml":
+		return printYAML(data)
+	case "value":
+		return printValue(data)
 	default:
 		return fmt.Errorf("unsupported output format: %s", format)
 	}
 }
 
-func printJSON(data any) error {
+// redact returns a copy of data with sensitive fields replaced by RedactedValue
+func redact(data any) any {
+	switch v := data.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, value := range v {
+			if isSensitiveField(key) {
+				result[key] = RedactedValue
+			} else {
+				result[key] = redact(value)
+			}
+		}
+		return result
+	case map[string]string:
+		result := make(map[string]string, len(v))
+		for key, value := range v {
+			if isSensitiveField(key) {
+				result[key] = RedactedValue
+			} else {
+				result[key] = value
+			}
+		}
+		return result
+	case []any:
+		result := make([]any, len(v))
+		for i, value := range v {
+			result[i] = redact(value)
+		}
+		return result
+	default:
+		return data
+	}
+}
+
+// isSensitiveField reports whether a field name is listed in sensitiveFields
+func isSensitiveField(name string) bool {
+	for _, field := range sensitiveFields {
+		if strings.EqualFold(name, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFalsey reports whether a result is false by JMESPath rules: false, null, or an
+// empty string, list or object. Numbers (including 0) are truthy.
+func isFalsey(data any) bool {
+	if b, ok := data.(bool); ok {
+		return !b
+	}
+	if object, ok := data.(orderedObject); ok {
+		return len(object.keys) == 0
+	}
+	return isEmptyResult(data)
+}
+
+// isEmptyResult reports whether a query result is null or an empty string, slice or map
+func isEmptyResult(data any) bool {
+	if data == nil {
+		return true
+	}
+	val := reflect.ValueOf(data)
+	switch val.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return val.Len() == 0
+	}
+	return false
+}
+
+func printJSON(data any, pretty bool) error {
 	encoder := json.NewEncoder(os.Stdout)
-	encoder.SetIndent("", "  ")
+	if pretty {
+		encoder.SetIndent("", "  ")
+	}
 	if err := encoder.Encode(data); err != nil {
 		return fmt.Errorf("failed to encode JSON: %w", err)
 	}
 	return nil
 }
 
+// printNDJSON prints each element of a slice as a compact JSON document on its own
+// line; any other value is printed as a single line
+func printNDJSON(data any) error {
+	val := reflect.ValueOf(data)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return printJSON(data, false)
+	}
+
+	for i := 0; i < val.Len(); i++ {
+		if err := printJSON(val.Index(i).Interface(), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeJSON converts typed maps, slices and structs into generic JSON values,
+// so field names follow their JSON tags in every format
+func normalizeJSON(data any) (any, error) {
+	// Selected keys are already normalized and must keep their order
+	if object, ok := data.(orderedObject); ok {
+		return object, nil
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var normalized any
+	if err := json.Unmarshal(raw, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// printYAML prints data as a single YAML document with sorted keys
+func printYAML(data any) error {
+	normalized, err := normalizeJSON(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode YAML: %w", err)
+	}
+
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(normalized); err != nil {
+		return fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	return encoder.Close()
+}
+
+// detailIndent is the indentation added per nesting level in detail output
+const detailIndent = "  "
+
+// printDetail prints data vertically as "key: value" lines, one per line, with
+// nested objects and lists indented beneath their key. Keys are sorted.
+func printDetail(data any) error {
+	normalized, err := normalizeJSON(data)
+	if err != nil {
+		return fmt.Errorf("failed to convert to detail view: %w", err)
+	}
+
+	var b strings.Builder
+	writeDetail(&b, normalized, "")
+	fmt.Print(b.String())
+	return nil
+}
+
+// writeDetail appends the detail view of value at the given indentation
+func writeDetail(b *strings.Builder, value any, indent string) {
+	switch v := value.(type) {
+	case orderedObject:
+		for _, key := range v.keys {
+			writeDetailField(b, key, v.values[key], indent)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			writeDetailField(b, key, v[key], indent)
+		}
+	case []any:
+		for _, elem := range v {
+			if isNested(elem) {
+				fmt.Fprintf(b, "%s-\n", indent)
+				writeDetail(b, elem, indent+detailIndent)
+			} else {
+				fmt.Fprintf(b, "%s- %s\n", indent, detailScalar(elem))
+			}
+		}
+	default:
+		fmt.Fprintf(b, "%s%s\n", indent, detailScalar(v))
+	}
+}
+
+// writeDetailField appends one "key: value" entry, nesting objects and lists
+func writeDetailField(b *strings.Builder, key string, value any, indent string) {
+	if isNested(value) {
+		fmt.Fprintf(b, "%s%s:\n", indent, key)
+		writeDetail(b, value, indent+detailIndent)
+	} else {
+		fmt.Fprintf(b, "%s%s: %s\n", indent, key, detailScalar(value))
+	}
+}
+
+// isNested reports whether a value is a non-empty object or list
+func isNested(value any) bool {
+	switch v := value.(type) {
+	case map[string]any:
+		return len(v) > 0
+	case []any:
+		return len(v) > 0
+	}
+	return false
+}
+
+// detailScalar formats a scalar (or empty object/list) for detail output
+func detailScalar(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case map[string]any:
+		return "{}"
+	case []any:
+		return "[]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
 func printTSV(data any) error {
+	// Selected keys print their values on one line, tab-separated in key order; a
+	// single selected key prints its value like any single-key map
+	if object, ok := data.(orderedObject); ok {
+		if len(object.keys) != 1 {
+			return printOrderedTSV(object)
+		}
+		data = object.values
+	}
+
 	// For simple types, just print the value
 	switch v := data.(type) {
 	case string:
@@ -85,8 +454,273 @@ func printTSV(data any) error {
 	return nil
 }
 
-func printTable(data any) error {
-	// For now, table format is the same as JSON
-	// This can be enhanced later with proper table formatting
-	return printJSON(data)
+// printOrderedTSV prints the values of selected keys on one tab-separated line.
+// Nested objects and lists are printed as compact JSON.
+func printOrderedTSV(object orderedObject) error {
+	fields := make([]string, len(object.keys))
+	for i, key := range object.keys {
+		value := object.values[key]
+		switch value.(type) {
+		case map[string]any, []any:
+			raw, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("failed to convert to TSV: %w", err)
+			}
+			fields[i] = string(raw)
+		default:
+			fields[i] = tableCell(value)
+		}
+	}
+	fmt.Println(strings.Join(fields, "\t"))
+	return nil
+}
+
+// printValue prints a scalar, or the scalar value of a single-key map, on its own.
+// Anything with more than one value is an error so scripts never capture the wrong one.
+func printValue(data any) error {
+	normalized, err := normalizeJSON(data)
+	if err != nil {
+		return fmt.Errorf("failed to convert to value: %w", err)
+	}
+	if object, ok := normalized.(orderedObject); ok {
+		normalized = object.values
+	}
+
+	if m, ok := normalized.(map[string]any); ok {
+		if len(m) != 1 {
+			keys := make([]string, 0, len(m))
+			for key := range m {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			return fmt.Errorf("output format value needs a single value but got %d fields (%s); use --query to select one", len(m), strings.Join(keys, ", "))
+		}
+		for _, v := range m {
+			normalized = v
+		}
+	}
+
+	switch v := normalized.(type) {
+	case nil:
+		// Print nothing for nil, like tsv
+	case map[string]any, []any:
+		return fmt.Errorf("output format value needs a single value but got a nested object or list; use --query to select one")
+	case float64:
+		// Avoid exponent notation for large numbers such as Unix timestamps
+		fmt.Println(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		fmt.Println(detailScalar(v))
+	}
+	return nil
+}
+
+// scalarColumnHeader is the header of the single-column table used for lists of scalars
+const scalarColumnHeader = "Value"
+
+func printTable(data any, opts Options) error {
+	// Lists of scalars (e.g. --query items) render as a single-column table
+	if values, ok := scalarSlice(data); ok {
+		printScalarTable(values, columnHeader(scalarColumnHeader, opts.Labels), !opts.NoHeaders)
+		return nil
+	}
+
+	// Selected keys render a single row with their scalar values in key order
+	if object, ok := data.(orderedObject); ok {
+		if columns := orderedColumns(object); len(columns) > 0 {
+			printObjectTable([]map[string]any{object.values}, columns, opts)
+			return nil
+		}
+	}
+
+	// Lists of objects render a column per key with scalar values
+	if rows, ok := objectSlice(data); ok {
+		if columns := scalarColumns(rows); len(columns) > 0 {
+			printObjectTable(rows, columns, opts)
+			return nil
+		}
+	}
+
+	// Other structures are not yet tabulated and fall back to JSON
+	return printJSON(data, prettyJSON(opts.JSONStyle))
+}
+
+// scalarSlice returns the string form of each element if data is a slice (or array)
+// containing only scalars (strings, numbers, booleans or null)
+func scalarSlice(data any) ([]string, bool) {
+	val := reflect.ValueOf(data)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return nil, false
+	}
+
+	values := make([]string, 0, val.Len())
+	for i := 0; i < val.Len(); i++ {
+		elem := val.Index(i).Interface()
+		if elem == nil {
+			values = append(values, "")
+			continue
+		}
+		switch reflect.ValueOf(elem).Kind() {
+		case reflect.String, reflect.Bool,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			values = append(values, fmt.Sprint(elem))
+		default:
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+// printScalarTable prints values as a single-column table, optionally preceded by
+// a header and underline
+func printScalarTable(values []string, header string, headers bool) {
+	if headers {
+		width := len(header)
+		for _, value := range values {
+			if len(value) > width {
+				width = len(value)
+			}
+		}
+
+		fmt.Println(header)
+		fmt.Println(strings.Repeat("-", width))
+	}
+	for _, value := range values {
+		fmt.Println(value)
+	}
+}
+
+// ParseLabels parses --label values of the form key=Header into a header mapping
+func ParseLabels(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(values))
+	for _, value := range values {
+		key, header, ok := strings.Cut(value, "=")
+		if !ok || key == "" || header == "" {
+			return nil, fmt.Errorf("invalid --label %q: expected key=Header", value)
+		}
+		labels[key] = header
+	}
+	return labels, nil
+}
+
+// columnHeader returns the header of a table column, renamed by --label if set
+func columnHeader(key string, labels map[string]string) string {
+	if header, ok := labels[key]; ok {
+		return header
+	}
+	return key
+}
+
+// objectSlice returns the elements of data if it is a non-empty list of objects
+func objectSlice(data any) ([]map[string]any, bool) {
+	normalized, err := normalizeJSON(data)
+	if err != nil {
+		return nil, false
+	}
+	list, ok := normalized.([]any)
+	if !ok || len(list) == 0 {
+		return nil, false
+	}
+
+	rows := make([]map[string]any, len(list))
+	for i, elem := range list {
+		row, ok := elem.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		rows[i] = row
+	}
+	return rows, true
+}
+
+// scalarColumns returns the sorted keys of the rows that hold scalar values. Keys
+// with nested objects or lists are left out, like az's table output.
+func scalarColumns(rows []map[string]any) []string {
+	nested := map[string]bool{}
+	seen := map[string]bool{}
+	for _, row := range rows {
+		for key, value := range row {
+			seen[key] = true
+			switch value.(type) {
+			case map[string]any, []any:
+				nested[key] = true
+			}
+		}
+	}
+
+	columns := make([]string, 0, len(seen))
+	for key := range seen {
+		if !nested[key] {
+			columns = append(columns, key)
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// orderedColumns returns the selected keys that hold scalar values, in key order
+func orderedColumns(object orderedObject) []string {
+	columns := make([]string, 0, len(object.keys))
+	for _, key := range object.keys {
+		switch object.values[key].(type) {
+		case map[string]any, []any:
+			continue
+		}
+		columns = append(columns, key)
+	}
+	return columns
+}
+
+// tableCell formats a scalar table value; numbers avoid exponent notation
+func tableCell(value any) string {
+	if number, ok := value.(float64); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return detailScalar(value)
+}
+
+// printObjectTable prints rows as aligned columns, optionally preceded by a header
+// and underline
+func printObjectTable(rows []map[string]any, columns []string, opts Options) {
+	headers := make([]string, len(columns))
+	widths := make([]int, len(columns))
+	for i, key := range columns {
+		headers[i] = columnHeader(key, opts.Labels)
+		if !opts.NoHeaders {
+			widths[i] = len(headers[i])
+		}
+	}
+
+	cells := make([][]string, len(rows))
+	for r, row := range rows {
+		cells[r] = make([]string, len(columns))
+		for i, key := range columns {
+			cells[r][i] = tableCell(row[key])
+			widths[i] = max(widths[i], len(cells[r][i]))
+		}
+	}
+
+	printRow := func(values []string) {
+		padded := make([]string, len(values))
+		for i, value := range values {
+			padded[i] = value + strings.Repeat(" ", widths[i]-len(value))
+		}
+		fmt.Println(strings.TrimRight(strings.Join(padded, "  "), " "))
+	}
+
+	if !opts.NoHeaders {
+		printRow(headers)
+		underline := make([]string, len(columns))
+		for i := range columns {
+			underline[i] = strings.Repeat("-", widths[i])
+		}
+		printRow(underline)
+	}
+	for _, row := range cells {
+		printRow(row)
+	}
 }
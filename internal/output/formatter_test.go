@@ -2,10 +2,13 @@ package output
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"io"
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func captureOutput(f func()) string {
@@ -25,6 +28,12 @@ func captureOutput(f func()) string {
 }
 
 func TestPrint_JSON(t *testing.T) {
+	// Simulate an interactive session so the default style is pretty
+	originalIsTerminal := stdoutIsTerminal
+	stdoutIsTerminal = func() bool { return true }
+	defer func() { stdoutIsTerminal = originalIsTerminal }()
+	t.Setenv("CI", "")
+
 	tests := []struct {
 		name     string
 		data     any
@@ -280,6 +289,108 @@ func TestPrint_QueryNonExistentField(t *testing.T) {
 	}
 }
 
+func TestPrint_StrictQuery(t *testing.T) {
+	data := map[string]any{
+		"name":  "test",
+		"empty": "",
+		"list":  []any{},
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{name: "Existing field", query: "name", wantErr: false},
+		{name: "Missing field", query: "nonexistent", wantErr: true},
+		{name: "Empty string", query: "empty", wantErr: true},
+		{name: "Empty list", query: "list", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			output := captureOutput(func() {
+				err = PrintWithOptions(data, "tsv", tt.query, Options{StrictQuery: true})
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr && output != "" {
+				t.Errorf("Expected no output on strict query error, got: %s", output)
+			}
+		})
+	}
+}
+
+func TestPrint_RedactSecrets(t *testing.T) {
+	data := map[string]any{
+		"accessToken": "secret-access-token",
+		"tenantId":    "test-tenant",
+		"user": map[string]any{
+			"name":  "test-client",
+			"token": "secret-nested-token",
+		},
+		"items": []any{
+			map[string]any{"value": "secret-list-value"},
+		},
+	}
+
+	output := captureOutput(func() {
+		err := PrintWithOptions(data, "json", "", Options{RedactSecrets: true})
+		if err != nil {
+			t.Errorf("Print failed: %v", err)
+		}
+	})
+
+	for _, secret := range []string{"secret-access-token", "secret-nested-token", "secret-list-value"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("Expected %s to be redacted, got: %s", secret, output)
+		}
+	}
+	if !strings.Contains(output, RedactedValue) {
+		t.Errorf("Expected redaction placeholder in output, got: %s", output)
+	}
+	if !strings.Contains(output, "test-tenant") {
+		t.Errorf("Expected non-sensitive fields to be kept, got: %s", output)
+	}
+
+	// The input must not be modified
+	if data["accessToken"] != "secret-access-token" {
+		t.Error("Expected input data to be left unmodified")
+	}
+}
+
+func TestPrint_RedactSecretsAppliesBeforeQuery(t *testing.T) {
+	data := map[string]any{"accessToken": "secret-access-token"}
+
+	output := captureOutput(func() {
+		err := PrintWithOptions(data, "tsv", "accessToken", Options{RedactSecrets: true})
+		if err != nil {
+			t.Errorf("Print failed: %v", err)
+		}
+	})
+
+	if strings.TrimSpace(output) != RedactedValue {
+		t.Errorf("Expected query result to be redacted, got: %s", output)
+	}
+}
+
+func TestPrint_SecretsShownByDefault(t *testing.T) {
+	data := map[string]any{"accessToken": "secret-access-token"}
+
+	output := captureOutput(func() {
+		err := Print(data, "tsv", "accessToken")
+		if err != nil {
+			t.Errorf("Print failed: %v", err)
+		}
+	})
+
+	if strings.TrimSpace(output) != "secret-access-token" {
+		t.Errorf("Expected secret to be shown without redaction, got: %s", output)
+	}
+}
+
 func TestPrintJSON_WithIndentation(t *testing.T) {
 	data := map[string]any{
 		"a": 1,
@@ -287,7 +398,7 @@ func TestPrintJSON_WithIndentation(t *testing.T) {
 	}
 
 	output := captureOutput(func() {
-		err := printJSON(data)
+		err := printJSON(data, true)
 		if err != nil {
 			t.Errorf("printJSON failed: %v", err)
 		}
@@ -351,3 +462,511 @@ func TestPrint_ArrayOfStrings(t *testing.T) {
 		t.Error("Expected all array items in output")
 	}
 }
+
+func TestPrint_TableScalarSlice(t *testing.T) {
+	data := map[string]any{
+		"items": []any{"a", "bb", "ccccccc"},
+	}
+
+	output := captureOutput(func() {
+		err := Print(data, "table", "items")
+		if err != nil {
+			t.Errorf("Print failed: %v", err)
+		}
+	})
+
+	expected := "Value\n-------\na\nbb\nccccccc\n"
+	if output != expected {
+		t.Errorf("Expected one-column table:\n%q\ngot:\n%q", expected, output)
+	}
+}
+
+func TestPrint_TableMixedSliceFallsBackToJSON(t *testing.T) {
+	data := []any{"a", map[string]any{"name": "b"}}
+
+	output := captureOutput(func() {
+		err := Print(data, "table", "")
+		if err != nil {
+			t.Errorf("Print failed: %v", err)
+		}
+	})
+
+	if !strings.Contains(output, `"name"`) {
+		t.Errorf("Expected JSON fallback for non-scalar elements, got: %s", output)
+	}
+}
+
+func TestPrint_TableObjects(t *testing.T) {
+	data := []any{
+		map[string]any{"name": "dev", "id": "sub-1", "isDefault": true, "user": map[string]any{"name": "client"}},
+		map[string]any{"name": "production", "id": "sub-2", "isDefault": false, "user": map[string]any{"name": "client"}},
+	}
+
+	output := captureOutput(func() {
+		if err := PrintWithOptions(data, "table", "", Options{}); err != nil {
+			t.Errorf("Print failed: %v", err)
+		}
+	})
+
+	// Nested values (user) are left out; columns are sorted by key
+	expected := "id     isDefault  name\n" +
+		"-----  ---------  ----------\n" +
+		"sub-1  true       dev\n" +
+		"sub-2  false      production\n"
+	if output != expected {
+		t.Errorf("Expected table:\n%q\ngot:\n%q", expected, output)
+	}
+}
+
+func TestPrint_TableLabels(t *testing.T) {
+	data := map[string]any{
+		"value": []any{
+			map[string]any{"name": "dev", "id": "sub-1"},
+			map[string]any{"name": "production", "id": "sub-2"},
+		},
+	}
+	labels, err := ParseLabels([]string{"id=Subscription ID", "name=Name"})
+	if err != nil {
+		t.Fatalf("ParseLabels failed: %v", err)
+	}
+
+	output := captureOutput(func() {
+		if err := PrintWithOptions(data, "table", "value", Options{Labels: labels}); err != nil {
+			t.Errorf("Print failed: %v", err)
+		}
+	})
+
+	expected := "Subscription ID  Name\n" +
+		"---------------  ----------\n" +
+		"sub-1            dev\n" +
+		"sub-2            production\n"
+	if output != expected {
+		t.Errorf("Expected relabelled table:\n%q\ngot:\n%q", expected, output)
+	}
+
+	// The single column of a scalar list is relabelled through its Value header
+	output = captureOutput(func() {
+		if err := PrintWithOptions(data, "table", "value[].name", Options{Labels: map[string]string{"Value": "Name"}}); err != nil {
+			t.Errorf("Print failed: %v", err)
+		}
+	})
+	if !strings.HasPrefix(output, "Name\n") {
+		t.Errorf("Expected relabelled scalar column, got %q", output)
+	}
+
+	if _, err := ParseLabels([]string{"no-separator"}); err == nil {
+		t.Error("Expected an error for a label without '='")
+	}
+}
+
+func TestPrint_JSONStyleAutoDetect(t *testing.T) {
+	originalIsTerminal := stdoutIsTerminal
+	defer func() { stdoutIsTerminal = originalIsTerminal }()
+
+	data := map[string]any{"a": 1, "b": 2}
+
+	tests := []struct {
+		name       string
+		terminal   bool
+		ci         string
+		style      JSONStyle
+		wantPretty bool
+	}{
+		{name: "Interactive terminal", terminal: true, style: JSONAuto, wantPretty: true},
+		{name: "Non-TTY stdout", terminal: false, style: JSONAuto, wantPretty: false},
+		{name: "CI on a terminal", terminal: true, ci: "true", style: JSONAuto, wantPretty: false},
+		{name: "Pretty overrides non-TTY", terminal: false, style: JSONPretty, wantPretty: true},
+		{name: "Compact overrides terminal", terminal: true, style: JSONCompact, wantPretty: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdoutIsTerminal = func() bool { return tt.terminal }
+			t.Setenv("CI", tt.ci)
+
+			output := captureOutput(func() {
+				if err := PrintWithOptions(data, "json", "", Options{JSONStyle: tt.style}); err != nil {
+					t.Errorf("Print failed: %v", err)
+				}
+			})
+
+			isPretty := strings.Count(output, "\n") > 1
+			if isPretty != tt.wantPretty {
+				t.Errorf("Expected pretty=%v, got output: %q", tt.wantPretty, output)
+			}
+		})
+	}
+}
+
+func TestPrint_NDJSON(t *testing.T) {
+	data := map[string]any{
+		"items": []any{
+			map[string]any{"name": "a", "value": 1},
+			map[string]any{"name": "b", "value": 2},
+			"c",
+		},
+	}
+
+	output := captureOutput(func() {
+		err := Print(data, "ndjson", "items")
+		if err != nil {
+			t.Errorf("Print failed: %v", err)
+		}
+	})
+
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %d: %q", len(lines), output)
+	}
+	for _, line := range lines {
+		var element any
+		if err := json.Unmarshal([]byte(line), &element); err != nil {
+			t.Errorf("Expected each line to be valid JSON, got %q: %v", line, err)
+		}
+	}
+}
+
+func TestPrint_NDJSONSingleObject(t *testing.T) {
+	data := map[string]any{"name": "test", "nested": map[string]any{"a": 1}}
+
+	output := captureOutput(func() {
+		err := Print(data, "ndjson", "")
+		if err != nil {
+			t.Errorf("Print failed: %v", err)
+		}
+	})
+
+	if strings.Count(output, "\n") != 1 {
+		t.Errorf("Expected a single line, got: %q", output)
+	}
+}
+
+func TestPrint_TableNoHeaders(t *testing.T) {
+	data := []string{"a", "b"}
+
+	withHeaders := captureOutput(func() {
+		if err := PrintWithOptions(data, "table", "", Options{}); err != nil {
+			t.Errorf("Print failed: %v", err)
+		}
+	})
+	if !strings.HasPrefix(withHeaders, scalarColumnHeader+"\n") {
+		t.Errorf("Expected header without --no-headers, got: %q", withHeaders)
+	}
+
+	withoutHeaders := captureOutput(func() {
+		if err := PrintWithOptions(data, "table", "", Options{NoHeaders: true}); err != nil {
+			t.Errorf("Print failed: %v", err)
+		}
+	})
+	if withoutHeaders != "a\nb\n" {
+		t.Errorf("Expected data rows only with --no-headers, got: %q", withoutHeaders)
+	}
+}
+
+func TestPrint_Detail(t *testing.T) {
+	data := map[string]any{
+		"name":     "Azure Subscription",
+		"id":       "test-subscription",
+		"isActive": true,
+		"user": map[string]string{
+			"name": "test-client",
+			"type": "servicePrincipal",
+		},
+		"tags": []any{"a", map[string]any{"key": "value"}},
+	}
+
+	output := captureOutput(func() {
+		if err := Print(data, "detail", ""); err != nil {
+			t.Errorf("Print failed: %v", err)
+		}
+	})
+
+	expected := `id: test-subscription
+isActive: true
+name: Azure Subscription
+tags:
+  - a
+  -
+    key: value
+user:
+  name: test-client
+  type: servicePrincipal
+`
+	if output != expected {
+		t.Errorf("Expected detail output:\n%s\ngot:\n%s", expected, output)
+	}
+}
+
+func TestPrint_Annotate(t *testing.T) {
+	originalNow := now
+	now = func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600)) }
+	defer func() { now = originalNow }()
+
+	data := map[string]any{"tenantId": "test-tenant", "id": "test-subscription"}
+	opts := Options{
+		JSONStyle:  JSONCompact,
+		Annotation: &Annotation{Command: "azure-login account show", Version: "1.2.3"},
+	}
+
+	output := captureOutput(func() {
+		if err := PrintWithOptions(data, "json", "tenantId", opts); err != nil {
+			t.Errorf("Print failed: %v", err)
+		}
+	})
+
+	var envelope map[string]any
+	if err := json.Unmarshal([]byte(output), &envelope); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, output)
+	}
+	expected := map[string]any{
+		"command":   "azure-login account show",
+		"timestamp": "2025-01-02T02:04:05Z",
+		"version":   "1.2.3",
+		"data":      "test-tenant", // the query applies to the bare data
+	}
+	for key, want := range expected {
+		if envelope[key] != want {
+			t.Errorf("Expected %s %v, got %v", key, want, envelope[key])
+		}
+	}
+}
+
+func TestPrint_AnnotateUnstructuredFormats(t *testing.T) {
+	data := map[string]any{"tenantId": "test-tenant", "id": "test-subscription"}
+	opts := Options{Annotation: &Annotation{Command: "azure-login account show", Version: "1.2.3"}}
+
+	for _, format := range []string{"value", "tsv", "table", "exitcode"} {
+		t.Run(format, func(t *testing.T) {
+			var err error
+			output := captureOutput(func() {
+				err = PrintWithOptions(data, format, "tenantId", opts)
+			})
+			if err == nil || !strings.Contains(err.Error(), "--annotate requires -o json, ndjson, yaml or detail") {
+				t.Errorf("Expected --annotate format error, got: %v", err)
+			}
+			if output != "" {
+				t.Errorf("Expected no output, got %q", output)
+			}
+		})
+	}
+
+	for _, format := range []string{"ndjson", "yaml", "detail"} {
+		t.Run(format, func(t *testing.T) {
+			output := captureOutput(func() {
+				if err := PrintWithOptions(data, format, "tenantId", opts); err != nil {
+					t.Errorf("PrintWithOptions failed: %v", err)
+				}
+			})
+			if !strings.Contains(output, "azure-login account show") {
+				t.Errorf("Expected the envelope, got %q", output)
+			}
+		})
+	}
+}
+
+func TestPrint_YAML(t *testing.T) {
+	data := map[string]any{
+		"tenantId": "test-tenant",
+		"user": map[string]any{
+			"name": "test-client",
+		},
+	}
+
+	output := captureOutput(func() {
+		if err := Print(data, "yaml", ""); err != nil {
+			t.Errorf("Print failed: %v", err)
+		}
+	})
+
+	expected := "tenantId: test-tenant\nuser:\n  name: test-client\n"
+	if output != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, output)
+	}
+
+	// Queries apply the same way as for the other formats
+	output = captureOutput(func() {
+		if err := Print(data, "yaml", "user.name"); err != nil {
+			t.Errorf("Print failed: %v", err)
+		}
+	})
+	if output != "test-client\n" {
+		t.Errorf("Expected queried scalar, got: %q", output)
+	}
+}
+
+func TestPrint_Value(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     any
+		query    string
+		expected string
+	}{
+		{"scalar", "just-the-token", "", "just-the-token\n"},
+		{"single-key map", map[string]any{"accessToken": "secret"}, "", "secret\n"},
+		{"large number", map[string]any{"expires_on": 1700000000}, "", "1700000000\n"},
+		{"queried field", map[string]any{"accessToken": "secret", "tenant": "t"}, "tenant", "t\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := captureOutput(func() {
+				if err := Print(tt.data, "value", tt.query); err != nil {
+					t.Errorf("Print failed: %v", err)
+				}
+			})
+			if output != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, output)
+			}
+		})
+	}
+}
+
+func TestPrint_ValueMultipleFields(t *testing.T) {
+	data := map[string]any{"accessToken": "secret", "tenant": "t"}
+
+	var err error
+	output := captureOutput(func() {
+		err = Print(data, "value", "")
+	})
+	if err == nil {
+		t.Fatal("Expected error for multi-field data, got none")
+	}
+	if !strings.Contains(err.Error(), "--query") || !strings.Contains(err.Error(), "accessToken, tenant") {
+		t.Errorf("Expected error naming the fields and suggesting --query, got: %v", err)
+	}
+	if output != "" {
+		t.Errorf("Expected no output on error, got %q", output)
+	}
+
+	if err := Print([]any{"a", "b"}, "value", ""); err == nil {
+		t.Error("Expected error for a list, got none")
+	}
+}
+
+func TestPrint_Keys(t *testing.T) {
+	data := map[string]any{
+		"tenantId":       "test-tenant",
+		"subscriptionId": "test-sub",
+		"user":           map[string]any{"name": "test-client"},
+		"state":          "Enabled",
+	}
+	opts := Options{JSONStyle: JSONCompact, Keys: []string{"user", "tenantId", "missing"}}
+
+	output := captureOutput(func() {
+		if err := PrintWithOptions(data, "json", "", opts); err != nil {
+			t.Errorf("PrintWithOptions failed: %v", err)
+		}
+	})
+	expected := `{"user":{"name":"test-client"},"tenantId":"test-tenant","missing":null}` + "\n"
+	if output != expected {
+		t.Errorf("Expected keys in the requested order:\n%s\ngot:\n%s", expected, output)
+	}
+
+	// YAML and detail keep the requested order too
+	output = captureOutput(func() {
+		if err := PrintWithOptions(data, "yaml", "", Options{Keys: []string{"tenantId", "state"}}); err != nil {
+			t.Errorf("PrintWithOptions failed: %v", err)
+		}
+	})
+	if output != "tenantId: test-tenant\nstate: Enabled\n" {
+		t.Errorf("Unexpected YAML: %q", output)
+	}
+	output = captureOutput(func() {
+		if err := PrintWithOptions(data, "detail", "", Options{Keys: []string{"tenantId", "state"}}); err != nil {
+			t.Errorf("PrintWithOptions failed: %v", err)
+		}
+	})
+	if output != "tenantId: test-tenant\nstate: Enabled\n" {
+		t.Errorf("Unexpected detail output: %q", output)
+	}
+
+	// A single key prints just its value in tsv
+	output = captureOutput(func() {
+		if err := PrintWithOptions(data, "tsv", "", Options{Keys: []string{"state"}}); err != nil {
+			t.Errorf("PrintWithOptions failed: %v", err)
+		}
+	})
+	if output != "Enabled\n" {
+		t.Errorf("Expected single value, got %q", output)
+	}
+
+	// Several keys print their values tab-separated in the requested order
+	output = captureOutput(func() {
+		if err := PrintWithOptions(data, "tsv", "", Options{Keys: []string{"state", "tenantId", "user"}}); err != nil {
+			t.Errorf("PrintWithOptions failed: %v", err)
+		}
+	})
+	if output != "Enabled\ttest-tenant\t{\"name\":\"test-client\"}\n" {
+		t.Errorf("Unexpected TSV: %q", output)
+	}
+
+	// A table has one row with a column per scalar key, in the requested order
+	output = captureOutput(func() {
+		if err := PrintWithOptions(data, "table", "", Options{Keys: []string{"tenantId", "user", "state"}}); err != nil {
+			t.Errorf("PrintWithOptions failed: %v", err)
+		}
+	})
+	expected = "tenantId     state\n-----------  -------\ntest-tenant  Enabled\n"
+	if output != expected {
+		t.Errorf("Expected table:\n%s\ngot:\n%s", expected, output)
+	}
+}
+
+func TestPrint_KeysErrors(t *testing.T) {
+	opts := Options{Keys: []string{"a"}}
+
+	err := PrintWithOptions(map[string]any{"a": 1}, "json", "a", opts)
+	if err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("Expected --keys/--query conflict error, got: %v", err)
+	}
+
+	err = PrintWithOptions([]any{"a", "b"}, "json", "", opts)
+	if err == nil || !strings.Contains(err.Error(), "object result") {
+		t.Errorf("Expected error for a list result, got: %v", err)
+	}
+}
+
+func TestPrint_ExitCode(t *testing.T) {
+	data := map[string]any{
+		"tenantId": "test-tenant",
+		"count":    0,
+		"tags":     []any{},
+		"user":     map[string]any{"name": "ci"},
+	}
+
+	tests := []struct {
+		query string
+		false bool
+	}{
+		{query: "tenantId == `\"test-tenant\"`", false: false},
+		{query: "tenantId == `\"other-tenant\"`", false: true},
+		{query: "missing", false: true},
+		{query: "tags", false: true},
+		{query: "count", false: false},
+		{query: "user", false: false},
+		{query: "", false: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var err error
+			out := captureOutput(func() {
+				err = PrintWithOptions(data, "exitcode", tt.query, Options{})
+			})
+			if out != "" {
+				t.Errorf("Expected no output, got %q", out)
+			}
+			if tt.false && !errors.Is(err, ErrFalseResult) {
+				t.Errorf("Expected ErrFalseResult, got %v", err)
+			}
+			if !tt.false && err != nil {
+				t.Errorf("Expected success, got %v", err)
+			}
+		})
+	}
+
+	// A --keys projection is a non-empty object, which is true
+	if err := PrintWithOptions(data, "exitcode", "", Options{Keys: []string{"missing"}}); err != nil {
+		t.Errorf("Expected a projected object to be true, got %v", err)
+	}
+}
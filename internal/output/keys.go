@@ -0,0 +1,76 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// orderedObject is an object whose keys keep the order they were selected in,
+// unlike map[string]any which encodes with sorted keys
+type orderedObject struct {
+	keys   []string
+	values map[string]any
+}
+
+// projectKeys selects the given top-level keys of an object result, in order.
+// Missing keys are null, matching the JMESPath multiselect hash {a: a, b: b}.
+func projectKeys(data any, keys []string) (orderedObject, error) {
+	normalized, err := normalizeJSON(data)
+	if err != nil {
+		return orderedObject{}, fmt.Errorf("failed to select keys: %w", err)
+	}
+	object, ok := normalized.(map[string]any)
+	if !ok {
+		return orderedObject{}, fmt.Errorf("--keys needs an object result; use --query for lists and values")
+	}
+
+	projected := orderedObject{values: make(map[string]any, len(keys))}
+	for _, key := range keys {
+		if _, seen := projected.values[key]; seen {
+			continue
+		}
+		projected.keys = append(projected.keys, key)
+		projected.values[key] = object[key]
+	}
+	return projected, nil
+}
+
+// MarshalJSON encodes the object with its keys in order
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalYAML encodes the object as a mapping with its keys in order
+func (o orderedObject) MarshalYAML() (any, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range o.keys {
+		var value yaml.Node
+		if err := value.Encode(o.values[key]); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &value)
+	}
+	return node, nil
+}
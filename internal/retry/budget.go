@@ -0,0 +1,84 @@
+package retry
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Budget limits retries across every retried operation in a single command invocation,
+// giving a predictable worst-case latency for commands that make several network calls.
+// The first attempt of each operation is always made; the budget only bounds retries.
+type Budget struct {
+	mu         sync.Mutex
+	maxRetries int
+	used       int
+	deadline   time.Time
+}
+
+// NewBudget creates a budget allowing maxRetries retries in total (0 means unlimited)
+// and no retries that would start after maxElapsed has passed (0 means unlimited).
+func NewBudget(maxRetries int, maxElapsed time.Duration) *Budget {
+	b := &Budget{maxRetries: maxRetries}
+	if maxElapsed > 0 {
+		b.deadline = time.Now().Add(maxElapsed)
+	}
+	return b
+}
+
+// LoadBudget loads a shared retry budget from environment variables.
+// Returns nil (no shared budget) if neither variable is set.
+//
+//   - AZURE_LOGIN_RETRY_BUDGET: total retries per command (1-50)
+//   - AZURE_LOGIN_RETRY_MAX_ELAPSED: total seconds in which retries may start (1-600)
+func LoadBudget() *Budget {
+	var maxRetries int
+	var maxElapsed time.Duration
+
+	if budgetStr := os.Getenv("AZURE_LOGIN_RETRY_BUDGET"); budgetStr != "" {
+		if budget, err := strconv.Atoi(budgetStr); err == nil && budget > 0 && budget <= 50 {
+			maxRetries = budget
+		}
+	}
+
+	if maxElapsedStr := os.Getenv("AZURE_LOGIN_RETRY_MAX_ELAPSED"); maxElapsedStr != "" {
+		if elapsed, err := strconv.Atoi(maxElapsedStr); err == nil && elapsed > 0 && elapsed <= 600 {
+			maxElapsed = time.Duration(elapsed) * time.Second
+		}
+	}
+
+	if maxRetries == 0 && maxElapsed == 0 {
+		return nil
+	}
+	return NewBudget(maxRetries, maxElapsed)
+}
+
+// take reserves one retry that will start after delay, reporting false if the budget is spent
+func (b *Budget) take(delay time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.maxRetries > 0 && b.used >= b.maxRetries {
+		return false
+	}
+	if !b.deadline.IsZero() && time.Now().Add(delay).After(b.deadline) {
+		return false
+	}
+	b.used++
+	return true
+}
+
+type budgetKey struct{}
+
+// WithBudget returns a context carrying the shared retry budget
+func WithBudget(ctx context.Context, budget *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, budget)
+}
+
+// BudgetFromContext returns the shared retry budget, or nil if none is set
+func BudgetFromContext(ctx context.Context) *Budget {
+	budget, _ := ctx.Value(budgetKey{}).(*Budget)
+	return budget
+}
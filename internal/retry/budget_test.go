@@ -0,0 +1,98 @@
+package retry
+
+import (
+	"context"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSharedBudgetAcrossOperations(t *testing.T) {
+	cfg := &Config{
+		MaxAttempts:       3,
+		InitialDelay:      1 * time.Millisecond,
+		MaxDelay:          10 * time.Millisecond,
+		BackoffMultiplier: 2.0,
+	}
+
+	// Two retries shared by the whole "command"
+	ctx := WithBudget(context.Background(), NewBudget(2, 0))
+
+	attempts := 0
+	failing := func() error {
+		attempts++
+		return &net.OpError{Err: syscall.ECONNRESET}
+	}
+
+	// The first operation consumes both retries (3 attempts)
+	if err := cfg.Do(ctx, failing); err == nil {
+		t.Fatal("expected first operation to fail")
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts for first operation, got %d", attempts)
+	}
+
+	// The second operation only gets its initial attempt
+	attempts = 0
+	if err := cfg.Do(ctx, failing); err == nil {
+		t.Fatal("expected second operation to fail")
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt for second operation (budget exhausted), got %d", attempts)
+	}
+}
+
+func TestBudgetMaxElapsed(t *testing.T) {
+	cfg := &Config{
+		MaxAttempts:       5,
+		InitialDelay:      50 * time.Millisecond,
+		MaxDelay:          1 * time.Second,
+		BackoffMultiplier: 1.0,
+	}
+
+	// No retry may start after 20ms, so the 50ms backoff is never taken
+	ctx := WithBudget(context.Background(), NewBudget(0, 20*time.Millisecond))
+
+	attempts := 0
+	err := cfg.Do(ctx, func() error {
+		attempts++
+		return &net.OpError{Err: syscall.ECONNRESET}
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestNoBudgetInContext(t *testing.T) {
+	if BudgetFromContext(context.Background()) != nil {
+		t.Error("expected nil budget for plain context")
+	}
+}
+
+func TestLoadBudget(t *testing.T) {
+	os.Unsetenv("AZURE_LOGIN_RETRY_BUDGET")
+	os.Unsetenv("AZURE_LOGIN_RETRY_MAX_ELAPSED")
+	if LoadBudget() != nil {
+		t.Error("expected nil budget when no env vars set")
+	}
+
+	os.Setenv("AZURE_LOGIN_RETRY_BUDGET", "4")
+	defer os.Unsetenv("AZURE_LOGIN_RETRY_BUDGET")
+	budget := LoadBudget()
+	if budget == nil {
+		t.Fatal("expected budget to be loaded")
+	}
+	if budget.maxRetries != 4 {
+		t.Errorf("expected maxRetries = 4, got %d", budget.maxRetries)
+	}
+
+	os.Setenv("AZURE_LOGIN_RETRY_BUDGET", "invalid")
+	if LoadBudget() != nil {
+		t.Error("expected nil budget for invalid value")
+	}
+}
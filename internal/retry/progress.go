@@ -0,0 +1,23 @@
+package retry
+
+import (
+	"context"
+	"time"
+)
+
+// OnRetry is called before each retry with the number of the attempt that failed,
+// the configured MaxAttempts, the attempt's error and the wait before the next attempt
+type OnRetry func(attempt, maxAttempts int, err error, wait time.Duration)
+
+type onRetryKey struct{}
+
+// WithOnRetry returns a context carrying a hook that observes every retry
+func WithOnRetry(ctx context.Context, hook OnRetry) context.Context {
+	return context.WithValue(ctx, onRetryKey{}, hook)
+}
+
+// OnRetryFromContext returns the retry hook, or nil if none is set
+func OnRetryFromContext(ctx context.Context) OnRetry {
+	hook, _ := ctx.Value(onRetryKey{}).(OnRetry)
+	return hook
+}
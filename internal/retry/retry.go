@@ -1,7 +1,8 @@
 // Package retry provides configurable retry logic for transient errors in CI environments.
 //
 // This package is designed to handle network-related transient failures that can occur
-// in CI/CD environments, such as connection resets, timeouts, and temporary service unavailability.
+// in CI/CD environments, such as connection resets, timeouts, and temporary service unavailability,
+// as well as HTTP throttling (429) and transient server errors (5xx) reported via HTTPStatusError.
 // Configuration is done exclusively through environment variables to avoid breaking the CLI interface.
 package retry
 
@@ -9,14 +10,28 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
 
+// Jitter modes randomize backoff delays so parallel jobs do not retry in lockstep
+const (
+	// JitterNone waits the computed delay exactly
+	JitterNone = "none"
+
+	// JitterEqual waits half the computed delay plus a random part of the other half
+	JitterEqual = "equal"
+
+	// JitterFull waits a random duration between zero and the computed delay
+	JitterFull = "full"
+)
+
 // Config holds retry configuration loaded from environment variables
 type Config struct {
 	// MaxAttempts is the maximum number of retry attempts (including the initial attempt)
@@ -34,6 +49,29 @@ type Config struct {
 	// BackoffMultiplier is the multiplier for exponential backoff
 	// Default: 2.0, configurable via AZURE_LOGIN_RETRY_BACKOFF_MULTIPLIER
 	BackoffMultiplier float64
+
+	// PerAttemptTimeout bounds each individual attempt, independently of the overall
+	// context deadline and any HTTP client timeout. Zero disables the per-attempt bound.
+	// Default: 0, configurable via AZURE_LOGIN_RETRY_ATTEMPT_TIMEOUT (in seconds)
+	PerAttemptTimeout time.Duration
+
+	// RetryOn lists lower-cased substrings; an error whose message contains one is
+	// retried even if IsRetryable rejects it. This is an escape hatch for unusual
+	// transient errors and may retry failures that are not safe to repeat.
+	// Default: none, configurable via AZURE_LOGIN_RETRY_ON (comma-separated)
+	RetryOn []string
+
+	// Jitter randomizes each backoff delay (JitterNone, JitterEqual or JitterFull);
+	// the jittered delay never exceeds the computed one, so stays within MaxDelay.
+	// An empty value means JitterNone.
+	// Default: JitterEqual, configurable via AZURE_LOGIN_RETRY_JITTER
+	Jitter string
+
+	// DNSInitialDelay is the minimum delay before the first retry of a temporary DNS
+	// failure, giving resolvers time to recover; later retries back off from it.
+	// Zero uses InitialDelay like any other transient error.
+	// Default: 0, configurable via AZURE_LOGIN_RETRY_DNS_INITIAL_DELAY (in seconds)
+	DNSInitialDelay time.Duration
 }
 
 // DefaultConfig returns the default retry configuration
@@ -43,6 +81,7 @@ func DefaultConfig() *Config {
 		InitialDelay:      1 * time.Second,
 		MaxDelay:          30 * time.Second,
 		BackoffMultiplier: 2.0,
+		Jitter:            JitterEqual, // spreads out retries of parallel CI jobs
 	}
 }
 
@@ -78,15 +117,77 @@ func LoadConfig() *Config {
 		}
 	}
 
+	// Load DNSInitialDelay
+	if dnsDelayStr := os.Getenv("AZURE_LOGIN_RETRY_DNS_INITIAL_DELAY"); dnsDelayStr != "" {
+		if dnsDelay, err := strconv.Atoi(dnsDelayStr); err == nil && dnsDelay > 0 && dnsDelay <= 60 {
+			cfg.DNSInitialDelay = time.Duration(dnsDelay) * time.Second
+		}
+	}
+
+	// Load PerAttemptTimeout
+	if attemptTimeoutStr := os.Getenv("AZURE_LOGIN_RETRY_ATTEMPT_TIMEOUT"); attemptTimeoutStr != "" {
+		if attemptTimeout, err := strconv.Atoi(attemptTimeoutStr); err == nil && attemptTimeout > 0 && attemptTimeout <= 300 {
+			cfg.PerAttemptTimeout = time.Duration(attemptTimeout) * time.Second
+		}
+	}
+
+	// Load Jitter
+	if jitterStr := os.Getenv("AZURE_LOGIN_RETRY_JITTER"); jitterStr != "" {
+		switch jitter := strings.ToLower(strings.TrimSpace(jitterStr)); jitter {
+		case JitterNone, JitterEqual, JitterFull:
+			cfg.Jitter = jitter
+		}
+	}
+
+	// Load RetryOn
+	if retryOnStr := os.Getenv("AZURE_LOGIN_RETRY_ON"); retryOnStr != "" {
+		for _, substring := range strings.Split(retryOnStr, ",") {
+			if substring = strings.ToLower(strings.TrimSpace(substring)); substring != "" {
+				cfg.RetryOn = append(cfg.RetryOn, substring)
+			}
+		}
+	}
+
 	return cfg
 }
 
+// isRetryable extends IsRetryable with the configured RetryOn substrings.
+// Cancellations and expired contexts are never retried.
+func (c *Config) isRetryable(err error) bool {
+	if IsRetryable(err) {
+		return true
+	}
+	if len(c.RetryOn) == 0 || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, substring := range c.RetryOn {
+		if strings.Contains(message, substring) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsRetryable determines if an error is retryable based on its type
 func IsRetryable(err error) bool {
 	if err == nil {
 		return false
 	}
 
+	// Throttled and transient server responses are retried; other statuses are not
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.StatusCode)
+	}
+
+	// A single attempt exceeding PerAttemptTimeout is always worth retrying
+	var attemptErr *attemptTimeoutError
+	if errors.As(err, &attemptErr) {
+		return true
+	}
+
 	// Check for URL errors first (they often wrap other errors)
 	// This must come before the context.DeadlineExceeded check because
 	// http.Client timeouts wrap context.DeadlineExceeded in a url.Error,
@@ -148,14 +249,29 @@ func IsRetryable(err error) bool {
 	return false
 }
 
+// isTemporaryDNSError reports whether err is a temporary DNS resolution failure
+func isTemporaryDNSError(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.Temporary()
+}
+
 // Do executes the given operation with retries according to the configuration
 func (c *Config) Do(ctx context.Context, operation func() error) error {
+	return c.DoWithContext(ctx, func(context.Context) error {
+		return operation()
+	})
+}
+
+// DoWithContext executes the given operation with retries according to the configuration.
+// Each attempt receives its own context, bounded by PerAttemptTimeout when set.
+func (c *Config) DoWithContext(ctx context.Context, operation func(ctx context.Context) error) error {
 	var lastErr error
 	delay := c.InitialDelay
+	dnsDelayApplied := false
 
 	for attempt := 1; attempt <= c.MaxAttempts; attempt++ {
 		// Execute the operation
-		err := operation()
+		err := c.runAttempt(ctx, operation)
 		if err == nil {
 			return nil
 		}
@@ -163,7 +279,7 @@ func (c *Config) Do(ctx context.Context, operation func() error) error {
 		lastErr = err
 
 		// Don't retry if the error is not retryable
-		if !IsRetryable(err) {
+		if !c.isRetryable(err) {
 			return err
 		}
 
@@ -172,12 +288,34 @@ func (c *Config) Do(ctx context.Context, operation func() error) error {
 			break
 		}
 
+		// The first DNS retry waits at least DNSInitialDelay, and backs off from there
+		if !dnsDelayApplied && c.DNSInitialDelay > delay && isTemporaryDNSError(err) {
+			delay = min(c.DNSInitialDelay, c.MaxDelay)
+			dnsDelayApplied = true
+		}
+
+		// A server-suggested delay (Retry-After) replaces the backoff for this wait
+		wait := c.jitter(delay)
+		var statusErr *HTTPStatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			wait = min(statusErr.RetryAfter, c.MaxDelay)
+		}
+
+		// Don't retry if the command-wide budget (if any) is spent
+		if budget := BudgetFromContext(ctx); budget != nil && !budget.take(wait) {
+			return fmt.Errorf("retry budget exhausted after %d attempts: %w", attempt, lastErr)
+		}
+
+		if onRetry := OnRetryFromContext(ctx); onRetry != nil {
+			onRetry(attempt, c.MaxAttempts, err, wait)
+		}
+
 		// Wait before retrying
 		select {
 		case <-ctx.Done():
 			// Context was cancelled, return the context error
 			return ctx.Err()
-		case <-time.After(delay):
+		case <-time.After(wait):
 			// Calculate next delay with exponential backoff
 			delay = time.Duration(float64(delay) * c.BackoffMultiplier)
 			if delay > c.MaxDelay {
@@ -192,3 +330,50 @@ func (c *Config) Do(ctx context.Context, operation func() error) error {
 	}
 	return lastErr
 }
+
+// jitter randomizes a backoff delay according to the Jitter mode
+func (c *Config) jitter(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return delay
+	}
+	switch c.Jitter {
+	case JitterFull:
+		return rand.N(delay + 1)
+	case JitterEqual:
+		half := delay / 2
+		return delay - half + rand.N(half+1)
+	default:
+		return delay
+	}
+}
+
+// runAttempt executes a single attempt, applying the per-attempt timeout if configured
+func (c *Config) runAttempt(ctx context.Context, operation func(ctx context.Context) error) error {
+	if c.PerAttemptTimeout <= 0 {
+		return operation(ctx)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, c.PerAttemptTimeout)
+	defer cancel()
+
+	err := operation(attemptCtx)
+	// A per-attempt timeout is transient, unlike the caller's own deadline or cancellation
+	if err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		return &attemptTimeoutError{timeout: c.PerAttemptTimeout, err: err}
+	}
+	return err
+}
+
+// attemptTimeoutError indicates that a single attempt exceeded PerAttemptTimeout
+type attemptTimeoutError struct {
+	timeout time.Duration
+	err     error
+}
+
+func (e *attemptTimeoutError) Error() string {
+	return fmt.Sprintf("attempt timed out after %s: %v", e.timeout, e.err)
+}
+
+func (e *attemptTimeoutError) Unwrap() error {
+	return e.err
+}
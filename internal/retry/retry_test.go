@@ -3,7 +3,9 @@ package retry
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"syscall"
@@ -26,6 +28,9 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.BackoffMultiplier != 2.0 {
 		t.Errorf("expected BackoffMultiplier = 2.0, got %f", cfg.BackoffMultiplier)
 	}
+	if cfg.Jitter != JitterEqual {
+		t.Errorf("expected Jitter = equal, got %q", cfg.Jitter)
+	}
 }
 
 func TestLoadConfigFromEnv(t *testing.T) {
@@ -188,6 +193,36 @@ func TestIsRetryable(t *testing.T) {
 			},
 			retryable: true,
 		},
+		{
+			name:      "HTTP 429 throttling",
+			err:       fmt.Errorf("token exchange: %w", &HTTPStatusError{StatusCode: http.StatusTooManyRequests}),
+			retryable: true,
+		},
+		{
+			name:      "HTTP 503 service unavailable",
+			err:       &HTTPStatusError{StatusCode: http.StatusServiceUnavailable},
+			retryable: true,
+		},
+		{
+			name:      "HTTP 500, 502 and 504 server errors",
+			err:       &HTTPStatusError{StatusCode: http.StatusBadGateway},
+			retryable: true,
+		},
+		{
+			name:      "HTTP 401 unauthorized",
+			err:       &HTTPStatusError{StatusCode: http.StatusUnauthorized},
+			retryable: false,
+		},
+		{
+			name:      "HTTP 400 bad request",
+			err:       &HTTPStatusError{StatusCode: http.StatusBadRequest, Err: errors.New("invalid_client")},
+			retryable: false,
+		},
+		{
+			name:      "HTTP 501 not implemented",
+			err:       &HTTPStatusError{StatusCode: http.StatusNotImplemented},
+			retryable: false,
+		},
 		{
 			name:      "generic error",
 			err:       errors.New("some error"),
@@ -367,3 +402,296 @@ func TestExponentialBackoff(t *testing.T) {
 		t.Errorf("expected elapsed time between %v and %v, got %v", minExpected, maxExpected, elapsed)
 	}
 }
+
+func TestLoadConfigPerAttemptTimeout(t *testing.T) {
+	os.Setenv("AZURE_LOGIN_RETRY_ATTEMPT_TIMEOUT", "7")
+	defer os.Unsetenv("AZURE_LOGIN_RETRY_ATTEMPT_TIMEOUT")
+
+	cfg := LoadConfig()
+	if cfg.PerAttemptTimeout != 7*time.Second {
+		t.Errorf("expected PerAttemptTimeout = 7s, got %v", cfg.PerAttemptTimeout)
+	}
+
+	os.Setenv("AZURE_LOGIN_RETRY_ATTEMPT_TIMEOUT", "0")
+	cfg = LoadConfig()
+	if cfg.PerAttemptTimeout != 0 {
+		t.Errorf("expected PerAttemptTimeout = 0 for invalid value, got %v", cfg.PerAttemptTimeout)
+	}
+}
+
+func TestDoWithContextPerAttemptTimeout(t *testing.T) {
+	cfg := &Config{
+		MaxAttempts:       3,
+		InitialDelay:      10 * time.Millisecond,
+		MaxDelay:          1 * time.Second,
+		BackoffMultiplier: 2.0,
+		PerAttemptTimeout: 50 * time.Millisecond,
+	}
+
+	attempts := 0
+	start := time.Now()
+	err := cfg.DoWithContext(context.Background(), func(ctx context.Context) error {
+		attempts++
+		if attempts == 1 {
+			// First attempt hangs until the per-attempt timeout cuts it off
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+	if elapsed > 1*time.Second {
+		t.Errorf("expected first attempt to be cut off quickly, took %v", elapsed)
+	}
+}
+
+func TestDoWithContextParentDeadlineNotRetried(t *testing.T) {
+	cfg := &Config{
+		MaxAttempts:       3,
+		InitialDelay:      10 * time.Millisecond,
+		MaxDelay:          1 * time.Second,
+		BackoffMultiplier: 2.0,
+		PerAttemptTimeout: 1 * time.Second,
+	}
+
+	// The caller's own deadline expires before the per-attempt timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	err := cfg.DoWithContext(ctx, func(ctx context.Context) error {
+		attempts++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestLoadConfigRetryOn(t *testing.T) {
+	t.Setenv("AZURE_LOGIN_RETRY_ON", " Gateway Busy ,,upstream reset")
+
+	cfg := LoadConfig()
+	if len(cfg.RetryOn) != 2 || cfg.RetryOn[0] != "gateway busy" || cfg.RetryOn[1] != "upstream reset" {
+		t.Errorf("expected normalized RetryOn substrings, got %q", cfg.RetryOn)
+	}
+}
+
+func TestDoWithRetryOnSubstring(t *testing.T) {
+	t.Setenv("AZURE_LOGIN_RETRY_ON", "gateway busy")
+	cfg := LoadConfig()
+	cfg.InitialDelay = time.Millisecond
+
+	attempts := 0
+	err := cfg.Do(context.Background(), func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("proxy says: Gateway Busy, try later")
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("expected success after retries, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected otherwise non-retryable error to be retried (3 attempts), got %d", attempts)
+	}
+}
+
+func TestDoWithRetryOnIgnoresCancellation(t *testing.T) {
+	cfg := &Config{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: time.Second, BackoffMultiplier: 2.0, RetryOn: []string{"context"}}
+
+	attempts := 0
+	_ = cfg.Do(context.Background(), func() error {
+		attempts++
+		return context.Canceled
+	})
+
+	if attempts != 1 {
+		t.Errorf("expected cancellation not to be retried, got %d attempts", attempts)
+	}
+}
+
+func TestDoHonorsRetryAfter(t *testing.T) {
+	cfg := &Config{
+		MaxAttempts:       2,
+		InitialDelay:      time.Millisecond,
+		MaxDelay:          time.Second,
+		BackoffMultiplier: 2.0,
+	}
+
+	attempts := 0
+	start := time.Now()
+	err := cfg.Do(context.Background(), func() error {
+		attempts++
+		if attempts == 1 {
+			return &HTTPStatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 200 * time.Millisecond}
+		}
+		return nil
+	})
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.Fatalf("expected success after the throttled attempt, got %v", err)
+	}
+	if elapsed < 200*time.Millisecond {
+		t.Errorf("expected to wait the suggested 200ms, waited %v", elapsed)
+	}
+}
+
+func TestDoCapsRetryAfterAtMaxDelay(t *testing.T) {
+	cfg := &Config{
+		MaxAttempts:       2,
+		InitialDelay:      time.Millisecond,
+		MaxDelay:          20 * time.Millisecond,
+		BackoffMultiplier: 2.0,
+	}
+
+	start := time.Now()
+	_ = cfg.Do(context.Background(), func() error {
+		return &HTTPStatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Retry-After to be capped at MaxDelay, waited %v", elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{value: "", min: 0, max: 0},
+		{value: "5", min: 5 * time.Second, max: 5 * time.Second},
+		{value: " 2 ", min: 2 * time.Second, max: 2 * time.Second},
+		{value: "-1", min: 0, max: 0},
+		{value: "soon", min: 0, max: 0},
+		{value: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), min: 0, max: 0},
+		{value: time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), min: 58 * time.Second, max: time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := ParseRetryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("ParseRetryAfter(%q) = %v, want between %v and %v", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestLoadConfigJitter(t *testing.T) {
+	tests := map[string]string{
+		"":        JitterEqual,
+		"full":    JitterFull,
+		" NONE ":  JitterNone,
+		"equal":   JitterEqual,
+		"invalid": JitterEqual,
+	}
+	for value, expected := range tests {
+		t.Setenv("AZURE_LOGIN_RETRY_JITTER", value)
+		if cfg := LoadConfig(); cfg.Jitter != expected {
+			t.Errorf("AZURE_LOGIN_RETRY_JITTER=%q: expected %q, got %q", value, expected, cfg.Jitter)
+		}
+	}
+}
+
+func TestJitterStaysWithinBounds(t *testing.T) {
+	delays := []time.Duration{0, time.Nanosecond, time.Millisecond, time.Second, 30 * time.Second}
+	tests := []struct {
+		jitter  string
+		minimum func(delay time.Duration) time.Duration
+	}{
+		{jitter: JitterNone, minimum: func(delay time.Duration) time.Duration { return delay }},
+		{jitter: "", minimum: func(delay time.Duration) time.Duration { return delay }},
+		{jitter: JitterEqual, minimum: func(delay time.Duration) time.Duration { return delay - delay/2 }},
+		{jitter: JitterFull, minimum: func(delay time.Duration) time.Duration { return 0 }},
+	}
+
+	for _, tt := range tests {
+		cfg := &Config{Jitter: tt.jitter, MaxDelay: 30 * time.Second}
+		for _, delay := range delays {
+			varied := false
+			for i := 0; i < 1000; i++ {
+				got := cfg.jitter(delay)
+				if got < tt.minimum(delay) || got > delay || got > cfg.MaxDelay {
+					t.Fatalf("jitter %q: delay %v produced %v, outside [%v, %v]", tt.jitter, delay, got, tt.minimum(delay), delay)
+				}
+				if got != delay {
+					varied = true
+				}
+			}
+			if tt.jitter != JitterNone && tt.jitter != "" && delay >= time.Millisecond && !varied {
+				t.Errorf("jitter %q: expected delay %v to vary", tt.jitter, delay)
+			}
+		}
+	}
+}
+
+func TestLoadConfigDNSInitialDelay(t *testing.T) {
+	t.Setenv("AZURE_LOGIN_RETRY_DNS_INITIAL_DELAY", "5")
+	if cfg := LoadConfig(); cfg.DNSInitialDelay != 5*time.Second {
+		t.Errorf("expected DNSInitialDelay = 5s, got %v", cfg.DNSInitialDelay)
+	}
+
+	t.Setenv("AZURE_LOGIN_RETRY_DNS_INITIAL_DELAY", "61")
+	if cfg := LoadConfig(); cfg.DNSInitialDelay != 0 {
+		t.Errorf("expected DNSInitialDelay = 0 for out-of-range value, got %v", cfg.DNSInitialDelay)
+	}
+}
+
+func TestDoUsesDNSInitialDelay(t *testing.T) {
+	cfg := &Config{
+		MaxAttempts:       3,
+		InitialDelay:      time.Millisecond,
+		MaxDelay:          time.Second,
+		BackoffMultiplier: 2.0,
+		DNSInitialDelay:   40 * time.Millisecond,
+	}
+
+	var waits []time.Duration
+	ctx := WithOnRetry(context.Background(), func(attempt, maxAttempts int, err error, wait time.Duration) {
+		waits = append(waits, wait)
+	})
+
+	attempts := 0
+	err := cfg.DoWithContext(ctx, func(context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return &net.DNSError{Err: "server misbehaving", Name: "login.microsoftonline.com", IsTemporary: true}
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("expected success after DNS retries, got %v", err)
+	}
+	if len(waits) != 2 || waits[0] != 40*time.Millisecond || waits[1] != 80*time.Millisecond {
+		t.Errorf("expected waits [40ms 80ms] backing off from the DNS delay, got %v", waits)
+	}
+
+	// Other transient errors keep the regular initial delay
+	waits = nil
+	attempts = 0
+	_ = cfg.DoWithContext(ctx, func(context.Context) error {
+		attempts++
+		if attempts < 2 {
+			return &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+		}
+		return nil
+	})
+	if len(waits) != 1 || waits[0] != time.Millisecond {
+		t.Errorf("expected connection reset to wait the regular 1ms, got %v", waits)
+	}
+}
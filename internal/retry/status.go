@@ -0,0 +1,67 @@
+package retry
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPStatusError reports a non-2xx HTTP response. Callers return it (wrapping their
+// own descriptive error) so IsRetryable can tell throttling and transient server
+// errors apart from permanent failures.
+type HTTPStatusError struct {
+	StatusCode int
+	Err        error
+
+	// RetryAfter is the delay the server asked for (Retry-After header), if any.
+	// Do waits this long instead of its own backoff, capped at MaxDelay.
+	RetryAfter time.Duration
+}
+
+func (e *HTTPStatusError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("unexpected HTTP status %d", e.StatusCode)
+	}
+	return e.Err.Error()
+}
+
+func (e *HTTPStatusError) Unwrap() error {
+	return e.Err
+}
+
+// ParseRetryAfter parses a Retry-After header value, given either in seconds or as an
+// HTTP date. It returns 0 for a missing, invalid or past value.
+func ParseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// retryableStatus reports whether a status code indicates throttling (429) or a
+// transient server error. Other 4xx responses are permanent.
+func retryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+// Package useragent builds the User-Agent sent with all outgoing HTTP requests.
+//
+// A descriptive User-Agent lets Azure support and rate-limiting diagnostics attribute
+// requests to azure-login. A custom suffix can be appended via AZURE_LOGIN_USER_AGENT_SUFFIX.
+package useragent
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// SuffixEnvVar names the environment variable whose value is appended to the User-Agent
+const SuffixEnvVar = "AZURE_LOGIN_USER_AGENT_SUFFIX"
+
+// String returns the User-Agent for the given azure-login version, e.g.
+// "azure-login/1.2.3 (go/1.25.0; linux/amd64)"
+func String(version string) string {
+	if version == "" {
+		version = "dev"
+	}
+	userAgent := fmt.Sprintf("azure-login/%s (go/%s; %s/%s)",
+		version, strings.TrimPrefix(runtime.Version(), "go"), runtime.GOOS, runtime.GOARCH)
+
+	if suffix := strings.TrimSpace(os.Getenv(SuffixEnvVar)); suffix != "" {
+		userAgent += " " + suffix
+	}
+	return userAgent
+}
+
+// Transport sets the User-Agent header on every request before delegating to Base
+type Transport struct {
+	// Base is the underlying transport (http.DefaultTransport if nil)
+	Base http.RoundTripper
+
+	// UserAgent is the header value to send
+	UserAgent string
+}
+
+// NewTransport creates a transport sending userAgent with every request
+func NewTransport(base http.RoundTripper, userAgent string) *Transport {
+	return &Transport{Base: base, UserAgent: userAgent}
+}
+
+// RoundTrip sets the User-Agent header and executes the request via the base transport
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// RoundTrippers must not modify the caller's request
+	clone := req.Clone(req.Context())
+	clone.Header.Set("User-Agent", t.UserAgent)
+	return base.RoundTrip(clone)
+}
@@ -0,0 +1,62 @@
+package useragent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	_ = os.Unsetenv(SuffixEnvVar)
+
+	userAgent := String("1.2.3")
+	if !strings.HasPrefix(userAgent, "azure-login/1.2.3 (") {
+		t.Errorf("Expected User-Agent to start with version, got %s", userAgent)
+	}
+	if !strings.Contains(userAgent, runtime.GOOS+"/"+runtime.GOARCH) {
+		t.Errorf("Expected User-Agent to contain platform, got %s", userAgent)
+	}
+}
+
+func TestString_Suffix(t *testing.T) {
+	_ = os.Setenv(SuffixEnvVar, "my-pipeline/42")
+	defer func() { _ = os.Unsetenv(SuffixEnvVar) }()
+
+	userAgent := String("1.2.3")
+	if !strings.HasSuffix(userAgent, ") my-pipeline/42") {
+		t.Errorf("Expected User-Agent to end with suffix, got %s", userAgent)
+	}
+}
+
+func TestTransport_SetsHeader(t *testing.T) {
+	_ = os.Unsetenv(SuffixEnvVar)
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("User-Agent")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, String("1.2.3"))}
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if !strings.HasPrefix(received, "azure-login/1.2.3") {
+		t.Errorf("Expected User-Agent with version, got %q", received)
+	}
+	if req.Header.Get("User-Agent") != "" {
+		t.Error("Expected original request to be left unmodified")
+	}
+}
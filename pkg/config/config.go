@@ -5,10 +5,18 @@
 package config
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/cogna-public/azure-login/internal/auth"
@@ -17,11 +25,19 @@ import (
 const (
 	defaultConfigDir = ".azure"
 	tokenFile        = "azure-login-token.json"
+	tokenFilePrefix  = "azure-login-token-"
+
+	// ProfileEnvVar selects a named token profile when --profile is not given
+	ProfileEnvVar = "AZURE_LOGIN_PROFILE"
+
+	// DefaultProfile names the unnamed profile cached in azure-login-token.json
+	DefaultProfile = "default"
 )
 
 // Config manages configuration and token storage
 type Config struct {
 	configDir string
+	profile   string
 }
 
 // SavedToken represents the cached token with metadata
@@ -29,9 +45,22 @@ type SavedToken struct {
 	AccessToken    string    `json:"access_token"`
 	TokenType      string    `json:"token_type"`
 	ExpiresOn      time.Time `json:"expires_on"`
+	ExtExpiresOn   time.Time `json:"ext_expires_on,omitzero"`
 	TenantID       string    `json:"tenant_id"`
 	ClientID       string    `json:"client_id"`
 	SubscriptionID string    `json:"subscription_id"`
+	Scope          string    `json:"scope,omitempty"`
+	Cloud          string    `json:"cloud,omitempty"`
+
+	// OIDCAudience is the custom OIDC token audience used at login, reused on refresh
+	OIDCAudience string `json:"oidc_audience,omitempty"`
+
+	// AuthorityTenantID is the --authority-tenant used at login, reused on refresh
+	AuthorityTenantID string `json:"authority_tenant_id,omitempty"`
+
+	// CertificatePath is the certificate used at login (login --certificate-path); when
+	// set, refresh signs a new assertion with it instead of exchanging an OIDC token
+	CertificatePath string `json:"certificate_path,omitempty"`
 }
 
 // NewConfig creates a new configuration manager
@@ -49,14 +78,103 @@ func NewConfig() *Config {
 
 	return &Config{
 		configDir: configDir,
+		profile:   ActiveProfile(),
+	}
+}
+
+// profile is the token profile selected with --profile
+var profile string
+
+// SetProfile selects the named token profile; an empty name falls back to
+// AZURE_LOGIN_PROFILE and then to the default profile
+func SetProfile(name string) {
+	profile = name
+}
+
+// ActiveProfile returns the selected token profile name, or DefaultProfile
+func ActiveProfile() string {
+	if profile != "" {
+		return profile
+	}
+	if name := os.Getenv(ProfileEnvVar); name != "" {
+		return name
+	}
+	return DefaultProfile
+}
+
+// profileNamePattern restricts profile names to characters safe in a file name
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// ValidateProfile checks that a profile name can be used in a token file name
+func ValidateProfile(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use up to 64 letters, digits, '-' or '_', starting with a letter or digit", name)
 	}
+	return nil
+}
+
+// allowInsecureDir disables the config directory permission check (--allow-insecure-dir)
+var allowInsecureDir bool
+
+// ForProfile returns a configuration manager for the named profile in the same
+// config directory, leaving c unchanged
+func (c *Config) ForProfile(name string) *Config {
+	other := *c
+	other.profile = name
+	return &other
+}
+
+// SetAllowInsecureDir controls whether tokens may be written to a config directory
+// that other users can access
+func SetAllowInsecureDir(allow bool) {
+	allowInsecureDir = allow
+}
+
+// saveMu serializes token cache writes within a process (e.g. concurrent scope exchanges)
+var saveMu sync.Mutex
+
+// tokenFileName returns the cache file name of the profile's primary token
+func (c *Config) tokenFileName() string {
+	if c.profile == "" || c.profile == DefaultProfile {
+		return tokenFile
+	}
+	return tokenFilePrefix + c.profile + ".json"
+}
+
+// scopeDigest returns the short hash identifying a scope in cache file names
+func scopeDigest(scope string) string {
+	sum := sha256.Sum256([]byte(scope))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+// scopedTokenFile returns the cache file name for a token acquired for a specific scope
+func (c *Config) scopedTokenFile(scope string) string {
+	if c.profile == "" || c.profile == DefaultProfile {
+		return tokenFilePrefix + scopeDigest(scope) + ".json"
+	}
+	return tokenFilePrefix + c.profile + "-" + scopeDigest(scope) + ".json"
 }
 
 // SaveToken saves the authentication token to disk using atomic writes
 func (c *Config) SaveToken(token *auth.TokenResponse) error {
-	// Ensure config directory exists
-	if err := os.MkdirAll(c.configDir, 0700); err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
+	return c.saveTokenFile(c.tokenFileName(), token)
+}
+
+// SaveTokenForScope saves a token to the cache file keyed by its scope,
+// leaving the primary token file untouched
+func (c *Config) SaveTokenForScope(token *auth.TokenResponse) error {
+	return c.saveTokenFile(c.scopedTokenFile(token.Scope), token)
+}
+
+func (c *Config) saveTokenFile(name string, token *auth.TokenResponse) error {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+
+	if err := c.ensureConfigDir(); err != nil {
+		return err
+	}
+	if err := c.checkConfigDirPermissions(); err != nil {
+		return err
 	}
 
 	// Prepare token for storage
@@ -64,9 +182,16 @@ func (c *Config) SaveToken(token *auth.TokenResponse) error {
 		AccessToken:    token.AccessToken,
 		TokenType:      token.TokenType,
 		ExpiresOn:      token.ExpiresOn,
+		ExtExpiresOn:   token.ExtExpiresOn,
 		TenantID:       token.TenantID,
 		ClientID:       token.ClientID,
 		SubscriptionID: token.SubscriptionID,
+		Scope:          token.Scope,
+		Cloud:          token.Cloud,
+		OIDCAudience:   token.OIDCAudience,
+
+		AuthorityTenantID: token.AuthorityTenantID,
+		CertificatePath:   token.CertificatePath,
 	}
 
 	// Marshal to JSON
@@ -75,27 +200,86 @@ func (c *Config) SaveToken(token *auth.TokenResponse) error {
 		return fmt.Errorf("failed to marshal token: %w", err)
 	}
 
-	// Write to temp file, then rename
-	tokenPath := filepath.Join(c.configDir, tokenFile)
-	tmpPath := tokenPath + ".tmp"
-
-	// Write to temp file with restricted permissions
-	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+	// A uniquely named temp file is renamed into place, so concurrent processes
+	// (e.g. parallel CI steps sharing the config directory) never collide
+	if err := c.writeFileAtomic(name, data); err != nil {
 		return fmt.Errorf("failed to write token file: %w", err)
 	}
 
-	// Atomically replace the token file
-	if err := os.Rename(tmpPath, tokenPath); err != nil {
+	return nil
+}
+
+// writeFileAtomic writes a file in the config directory with 0600 permissions. A
+// uniquely named temp file is renamed into place so concurrent processes never
+// observe a partial write.
+func (c *Config) writeFileAtomic(name string, data []byte) error {
+	// os.CreateTemp creates the file with 0600 permissions
+	tmpFile, err := os.CreateTemp(c.configDir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	_, writeErr := tmpFile.Write(data)
+	closeErr := tmpFile.Close()
+	if writeErr != nil || closeErr != nil {
+		_ = os.Remove(tmpPath)
+		return errors.Join(writeErr, closeErr)
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(c.configDir, name)); err != nil {
 		_ = os.Remove(tmpPath) // Clean up temp file on error
-		return fmt.Errorf("failed to save token file: %w", err)
+		return err
+	}
+	return nil
+}
+
+// ensureConfigDir creates the config directory (0700) if it does not exist
+func (c *Config) ensureConfigDir() error {
+	if info, err := os.Stat(c.configDir); err == nil && !info.IsDir() {
+		return fmt.Errorf("config directory %s exists but is not a directory; remove or rename it, or set AZURE_CONFIG_DIR to another path", c.configDir)
+	}
+	if err := os.MkdirAll(c.configDir, 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	return nil
+}
+
+// checkConfigDirPermissions ensures tokens are only written to a directory other
+// users cannot access. A directory more permissive than 0700 (e.g. a pre-existing
+// ~/.azure created with 0755) is tightened; if that fails, as for a shared temp
+// directory owned by someone else, the write is refused. Windows uses ACLs instead
+// of mode bits, so the check is skipped there.
+func (c *Config) checkConfigDirPermissions() error {
+	if allowInsecureDir || runtime.GOOS == "windows" {
+		return nil
 	}
 
+	info, err := os.Stat(c.configDir)
+	if err != nil {
+		return fmt.Errorf("failed to check config directory: %w", err)
+	}
+	if info.Mode().Perm()&0077 == 0 {
+		return nil
+	}
+
+	if err := os.Chmod(c.configDir, 0700); err != nil {
+		return fmt.Errorf("refusing to save token: config directory %s has permissions %o and could not be restricted to 0700 (%v); set AZURE_CONFIG_DIR to a private directory or pass --allow-insecure-dir", c.configDir, info.Mode().Perm(), err)
+	}
 	return nil
 }
 
 // LoadToken loads the authentication token from disk
 func (c *Config) LoadToken() (*SavedToken, error) {
-	tokenPath := filepath.Join(c.configDir, tokenFile)
+	return c.loadTokenFile(c.tokenFileName())
+}
+
+// LoadTokenForScope loads the token cached for a specific scope
+func (c *Config) LoadTokenForScope(scope string) (*SavedToken, error) {
+	return c.loadTokenFile(c.scopedTokenFile(scope))
+}
+
+func (c *Config) loadTokenFile(name string) (*SavedToken, error) {
+	tokenPath := filepath.Join(c.configDir, name)
 
 	// Read token file
 	data, err := os.ReadFile(tokenPath)
@@ -106,18 +290,31 @@ func (c *Config) LoadToken() (*SavedToken, error) {
 		return nil, fmt.Errorf("failed to read token file: %w", err)
 	}
 
-	// Parse token
+	// An empty file is distinct from a missing one (e.g. an interrupted write by another tool)
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("token file is empty. Run 'azure-login login' again")
+	}
+
+	// Parse token (unknown fields are ignored so newer/older versions can share the cache)
 	var token SavedToken
 	if err := json.Unmarshal(data, &token); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("token file has an invalid value for field %q (expected %s, got %s). Run 'azure-login login' again", typeErr.Field, typeErr.Type, typeErr.Value)
+		}
 		return nil, fmt.Errorf("failed to parse token file: %w", err)
 	}
 
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token file is missing access_token. Run 'azure-login login' again")
+	}
+
 	return &token, nil
 }
 
 // DeleteToken removes the stored authentication token
 func (c *Config) DeleteToken() error {
-	tokenPath := filepath.Join(c.configDir, tokenFile)
+	tokenPath := filepath.Join(c.configDir, c.tokenFileName())
 	if err := os.Remove(tokenPath); err != nil {
 		if os.IsNotExist(err) {
 			return nil // Already deleted
@@ -126,3 +323,136 @@ func (c *Config) DeleteToken() error {
 	}
 	return nil
 }
+
+// TokenFile is a token cache file in the config directory
+type TokenFile struct {
+	Name string
+	Path string
+
+	// Token is the cached token, or nil if the file could not be read
+	Token *SavedToken
+}
+
+// TokenFiles returns every token cache file in the config directory (the primary
+// token of each profile and the per-scope caches), sorted by name
+func (c *Config) TokenFiles() ([]TokenFile, error) {
+	entries, err := os.ReadDir(c.configDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read config directory: %w", err)
+	}
+
+	var files []TokenFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || (name != tokenFile && !(strings.HasPrefix(name, tokenFilePrefix) && strings.HasSuffix(name, ".json"))) {
+			continue
+		}
+		token, _ := c.loadTokenFile(name)
+		files = append(files, TokenFile{
+			Name:  name,
+			Path:  filepath.Join(c.configDir, name),
+			Token: token,
+		})
+	}
+	return files, nil
+}
+
+// ProfileTokenFiles returns the token cache files of the configured profile: its
+// primary token and the per-scope caches acquired with it
+func (c *Config) ProfileTokenFiles() ([]TokenFile, error) {
+	files, err := c.TokenFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var owned []TokenFile
+	for _, file := range files {
+		if c.ownsTokenFile(file) {
+			owned = append(owned, file)
+		}
+	}
+	return owned, nil
+}
+
+// scopeDigestPattern matches the scope digest suffix of a per-scope cache file name
+var scopeDigestPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// ownsTokenFile reports whether a token file belongs to the configured profile. A
+// readable per-scope cache is matched by its scope; an unreadable one by its name.
+func (c *Config) ownsTokenFile(file TokenFile) bool {
+	if file.Name == c.tokenFileName() {
+		return true
+	}
+	if file.Token != nil {
+		return file.Token.Scope != "" && file.Name == c.scopedTokenFile(file.Token.Scope)
+	}
+
+	prefix := tokenFilePrefix
+	if c.profile != "" && c.profile != DefaultProfile {
+		prefix += c.profile + "-"
+	}
+	if !strings.HasPrefix(file.Name, prefix) || !strings.HasSuffix(file.Name, ".json") {
+		return false
+	}
+	return scopeDigestPattern.MatchString(strings.TrimSuffix(strings.TrimPrefix(file.Name, prefix), ".json"))
+}
+
+// RemoveTokenFile deletes a token cache file returned by TokenFiles
+func (c *Config) RemoveTokenFile(file TokenFile) error {
+	if err := os.Remove(filepath.Join(c.configDir, filepath.Base(file.Name))); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete token file: %w", err)
+	}
+	return nil
+}
+
+// Profile describes a token profile cached in the config directory
+type Profile struct {
+	Name string
+	Path string
+
+	// Token is the cached token, or nil if the file could not be read
+	Token *SavedToken
+}
+
+// ListProfiles returns the token profiles cached in the config directory, sorted by
+// name. Per-scope token caches are not profiles and are skipped.
+func (c *Config) ListProfiles() ([]Profile, error) {
+	files, err := c.TokenFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []Profile
+	for _, file := range files {
+		name := DefaultProfile
+		if file.Name != tokenFile {
+			name = strings.TrimSuffix(strings.TrimPrefix(file.Name, tokenFilePrefix), ".json")
+			if ValidateProfile(name) != nil {
+				continue
+			}
+			if file.Token != nil && file.Token.Scope != "" && isScopedTokenName(name, file.Token.Scope) {
+				continue
+			}
+		}
+		profiles = append(profiles, Profile{
+			Name:  name,
+			Path:  file.Path,
+			Token: file.Token,
+		})
+	}
+
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles, nil
+}
+
+// isScopedTokenName reports whether a token file name suffix is that of the per-scope
+// cache for scope, for the default profile or any named one
+func isScopedTokenName(name, scope string) bool {
+	digest := scopeDigest(scope)
+	return name == digest || strings.HasSuffix(name, "-"+digest)
+}
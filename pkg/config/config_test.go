@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -49,6 +51,7 @@ func TestSaveAndLoadToken(t *testing.T) {
 		TenantID:       "test-tenant-id",
 		ClientID:       "test-client-id",
 		SubscriptionID: "test-subscription-id",
+		Cloud:          "AzureUSGovernment",
 	}
 
 	// Test SaveToken
@@ -94,6 +97,9 @@ func TestSaveAndLoadToken(t *testing.T) {
 	if loadedToken.SubscriptionID != testToken.SubscriptionID {
 		t.Errorf("SubscriptionID mismatch: expected %s, got %s", testToken.SubscriptionID, loadedToken.SubscriptionID)
 	}
+	if loadedToken.Cloud != testToken.Cloud {
+		t.Errorf("Cloud mismatch: expected %s, got %s", testToken.Cloud, loadedToken.Cloud)
+	}
 	// Time comparison with small delta for rounding
 	if loadedToken.ExpiresOn.Sub(testToken.ExpiresOn).Abs() > time.Second {
 		t.Errorf("ExpiresOn mismatch: expected %v, got %v", testToken.ExpiresOn, loadedToken.ExpiresOn)
@@ -187,16 +193,25 @@ func TestSaveToken_AtomicWrite(t *testing.T) {
 		SubscriptionID: "subscription",
 	}
 
+	// A temp path at a fixed name (e.g. another process's write in progress) must
+	// not be reused
+	if err := os.Mkdir(filepath.Join(tmpDir, tokenFile+".tmp"), 0700); err != nil {
+		t.Fatalf("Failed to create fixed temp path: %v", err)
+	}
+
 	// Save token
 	err := config.SaveToken(testToken)
 	if err != nil {
 		t.Fatalf("SaveToken failed: %v", err)
 	}
 
-	// Verify temp file is cleaned up
-	tmpPath := filepath.Join(tmpDir, tokenFile+".tmp")
-	if _, err := os.Stat(tmpPath); !os.IsNotExist(err) {
-		t.Error("Temp file should not exist after atomic write")
+	// Verify the uniquely named temp file is cleaned up
+	leftover, err := filepath.Glob(filepath.Join(tmpDir, tokenFile+".*.tmp"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if len(leftover) > 0 {
+		t.Errorf("Temp files should not exist after atomic write: %v", leftover)
 	}
 
 	// Verify actual token file exists
@@ -300,6 +315,69 @@ func TestSaveToken_DirectoryCreation(t *testing.T) {
 	}
 }
 
+func TestSaveToken_TightensInsecureConfigDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not enforced on Windows")
+	}
+
+	configDir := filepath.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(configDir, 0700); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	// Chmod explicitly so the umask does not mask the world-writable bits
+	if err := os.Chmod(configDir, 0777); err != nil {
+		t.Fatalf("Failed to chmod directory: %v", err)
+	}
+
+	config := &Config{configDir: configDir}
+	token := &auth.TokenResponse{AccessToken: "test-token", ExpiresOn: time.Now().Add(time.Hour)}
+
+	// With the override the directory is left alone
+	SetAllowInsecureDir(true)
+	if err := config.SaveToken(token); err != nil {
+		t.Fatalf("SaveToken with --allow-insecure-dir failed: %v", err)
+	}
+	SetAllowInsecureDir(false)
+	if info, _ := os.Stat(configDir); info.Mode().Perm() != 0777 {
+		t.Errorf("Expected 0777 to be kept with the override, got %o", info.Mode().Perm())
+	}
+
+	if err := config.SaveToken(token); err != nil {
+		t.Fatalf("SaveToken failed: %v", err)
+	}
+	info, err := os.Stat(configDir)
+	if err != nil {
+		t.Fatalf("Failed to stat directory: %v", err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("Expected config directory to be tightened to 0700, got %o", info.Mode().Perm())
+	}
+}
+
+func TestSaveToken_ConfigDirIsFile(t *testing.T) {
+	// Create a regular file where the config directory is expected
+	configPath := filepath.Join(t.TempDir(), ".azure")
+	if err := os.WriteFile(configPath, []byte("not a directory"), 0600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	_ = os.Setenv("AZURE_CONFIG_DIR", configPath)
+	defer func() { _ = os.Unsetenv("AZURE_CONFIG_DIR") }()
+
+	config := NewConfig()
+	err := config.SaveToken(&auth.TokenResponse{
+		AccessToken: "test-token",
+		TokenType:   "Bearer",
+		ExpiresOn:   time.Now().Add(1 * time.Hour),
+	})
+	if err == nil {
+		t.Fatal("Expected error when config directory is a file, got none")
+	}
+	if !strings.Contains(err.Error(), "exists but is not a directory") {
+		t.Errorf("Expected helpful error message, got: %v", err)
+	}
+}
+
 func TestLoadToken_CorruptedFile(t *testing.T) {
 	// Create temporary directory for test
 	tmpDir := t.TempDir()
@@ -325,6 +403,257 @@ func TestLoadToken_CorruptedFile(t *testing.T) {
 	}
 }
 
+func TestLoadToken_EmptyFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.Setenv("AZURE_CONFIG_DIR", tmpDir)
+	defer func() { _ = os.Unsetenv("AZURE_CONFIG_DIR") }()
+
+	config := NewConfig()
+
+	tokenPath := filepath.Join(tmpDir, tokenFile)
+	if err := os.WriteFile(tokenPath, []byte("  \n"), 0600); err != nil {
+		t.Fatalf("Failed to write empty file: %v", err)
+	}
+
+	token, err := config.LoadToken()
+	if err == nil {
+		t.Fatal("Expected error for empty token file, got none")
+	}
+	if token != nil {
+		t.Errorf("Expected nil token, got %v", token)
+	}
+	if !strings.Contains(err.Error(), "token file is empty") {
+		t.Errorf("Expected 'token file is empty' error, got: %v", err)
+	}
+}
+
+func TestLoadToken_TypeMismatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.Setenv("AZURE_CONFIG_DIR", tmpDir)
+	defer func() { _ = os.Unsetenv("AZURE_CONFIG_DIR") }()
+
+	config := NewConfig()
+
+	// access_token must be a string
+	tokenPath := filepath.Join(tmpDir, tokenFile)
+	if err := os.WriteFile(tokenPath, []byte(`{"access_token": 12345, "token_type": "Bearer"}`), 0600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+
+	token, err := config.LoadToken()
+	if err == nil {
+		t.Fatal("Expected error for type mismatch, got none")
+	}
+	if token != nil {
+		t.Errorf("Expected nil token, got %v", token)
+	}
+	if !strings.Contains(err.Error(), `invalid value for field "access_token"`) {
+		t.Errorf("Expected field name in error, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "azure-login login") {
+		t.Errorf("Expected re-login suggestion in error, got: %v", err)
+	}
+}
+
+func TestLoadToken_UnknownFields(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.Setenv("AZURE_CONFIG_DIR", tmpDir)
+	defer func() { _ = os.Unsetenv("AZURE_CONFIG_DIR") }()
+
+	config := NewConfig()
+
+	// Fields written by a newer version should be ignored
+	data := `{
+		"access_token": "test-token",
+		"token_type": "Bearer",
+		"expires_on": "2030-01-01T00:00:00Z",
+		"tenant_id": "tenant",
+		"client_id": "client",
+		"subscription_id": "subscription",
+		"future_field": {"nested": true}
+	}`
+	tokenPath := filepath.Join(tmpDir, tokenFile)
+	if err := os.WriteFile(tokenPath, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+
+	token, err := config.LoadToken()
+	if err != nil {
+		t.Fatalf("Expected unknown fields to be tolerated, got: %v", err)
+	}
+	if token.AccessToken != "test-token" {
+		t.Errorf("Expected AccessToken test-token, got %s", token.AccessToken)
+	}
+	if token.TenantID != "tenant" {
+		t.Errorf("Expected TenantID tenant, got %s", token.TenantID)
+	}
+}
+
+func TestSaveAndLoadTokenForScope(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.Setenv("AZURE_CONFIG_DIR", tmpDir)
+	defer func() { _ = os.Unsetenv("AZURE_CONFIG_DIR") }()
+
+	config := NewConfig()
+
+	testToken := &auth.TokenResponse{
+		AccessToken: "scoped-token",
+		TokenType:   "Bearer",
+		ExpiresOn:   time.Now().Add(1 * time.Hour),
+		Scope:       "api://my-app/.default",
+	}
+	if err := config.SaveTokenForScope(testToken); err != nil {
+		t.Fatalf("SaveTokenForScope failed: %v", err)
+	}
+
+	loaded, err := config.LoadTokenForScope("api://my-app/.default")
+	if err != nil {
+		t.Fatalf("LoadTokenForScope failed: %v", err)
+	}
+	if loaded.AccessToken != "scoped-token" {
+		t.Errorf("Expected AccessToken scoped-token, got %s", loaded.AccessToken)
+	}
+	if loaded.Scope != "api://my-app/.default" {
+		t.Errorf("Expected Scope api://my-app/.default, got %s", loaded.Scope)
+	}
+
+	// The primary token file is not written by scoped saves
+	if _, err := config.LoadToken(); err == nil {
+		t.Error("Expected primary token to be absent")
+	}
+	if _, err := config.LoadTokenForScope("api://other/.default"); err == nil {
+		t.Error("Expected error loading token for a different scope")
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("AZURE_CONFIG_DIR", tmpDir)
+	t.Setenv(ProfileEnvVar, "")
+
+	save := func(cfg *Config, accessToken string) {
+		t.Helper()
+		err := cfg.SaveToken(&auth.TokenResponse{
+			AccessToken: accessToken,
+			TokenType:   "Bearer",
+			ExpiresOn:   time.Now().Add(1 * time.Hour),
+			TenantID:    accessToken + "-tenant",
+		})
+		if err != nil {
+			t.Fatalf("SaveToken failed: %v", err)
+		}
+	}
+
+	defaultConfig := NewConfig()
+	save(defaultConfig, "default-token")
+
+	t.Setenv(ProfileEnvVar, "staging")
+	stagingConfig := NewConfig()
+	save(stagingConfig, "staging-token")
+	if _, err := os.Stat(filepath.Join(tmpDir, "azure-login-token-staging.json")); err != nil {
+		t.Fatalf("Expected profile token file: %v", err)
+	}
+
+	// --profile takes precedence over the environment
+	SetProfile("prod")
+	defer SetProfile("")
+	prodConfig := NewConfig()
+	save(prodConfig, "prod-token")
+	if err := prodConfig.SaveTokenForScope(&auth.TokenResponse{AccessToken: "vault-token", Scope: "https://vault.azure.net/.default"}); err != nil {
+		t.Fatalf("SaveTokenForScope failed: %v", err)
+	}
+
+	for cfg, expected := range map[*Config]string{defaultConfig: "default-token", stagingConfig: "staging-token", prodConfig: "prod-token"} {
+		loaded, err := cfg.LoadToken()
+		if err != nil || loaded.AccessToken != expected {
+			t.Errorf("Expected %s for profile %s, got %v (%v)", expected, cfg.profile, loaded, err)
+		}
+	}
+	if _, err := stagingConfig.LoadTokenForScope("https://vault.azure.net/.default"); err == nil {
+		t.Error("Expected scoped tokens to be cached per profile")
+	}
+
+	profiles, err := defaultConfig.ListProfiles()
+	if err != nil {
+		t.Fatalf("ListProfiles failed: %v", err)
+	}
+	var names []string
+	for _, profile := range profiles {
+		names = append(names, profile.Name)
+	}
+	if strings.Join(names, ",") != "default,prod,staging" {
+		t.Errorf("Expected profiles default,prod,staging (scoped caches skipped), got %v", names)
+	}
+
+	if err := stagingConfig.DeleteToken(); err != nil {
+		t.Fatalf("DeleteToken failed: %v", err)
+	}
+	if _, err := stagingConfig.LoadToken(); err == nil {
+		t.Error("Expected staging token to be deleted")
+	}
+	if _, err := defaultConfig.LoadToken(); err != nil {
+		t.Errorf("Expected default token to survive deleting another profile: %v", err)
+	}
+}
+
+func TestProfileTokenFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("AZURE_CONFIG_DIR", tmpDir)
+	t.Setenv(ProfileEnvVar, "")
+
+	defaultConfig := NewConfig()
+	prodConfig := defaultConfig.ForProfile("prod")
+	for _, cfg := range []*Config{defaultConfig, prodConfig} {
+		if err := cfg.SaveToken(&auth.TokenResponse{AccessToken: "primary", Scope: "https://management.azure.com/.default"}); err != nil {
+			t.Fatalf("SaveToken failed: %v", err)
+		}
+		if err := cfg.SaveTokenForScope(&auth.TokenResponse{AccessToken: "vault", Scope: "https://vault.azure.net/.default"}); err != nil {
+			t.Fatalf("SaveTokenForScope failed: %v", err)
+		}
+	}
+	// An unreadable per-scope cache is attributed by its name
+	corrupt := "azure-login-token-prod-0123456789abcdef.json"
+	if err := os.WriteFile(filepath.Join(tmpDir, corrupt), []byte("{"), 0600); err != nil {
+		t.Fatalf("Failed to write corrupt file: %v", err)
+	}
+
+	names := func(cfg *Config) []string {
+		t.Helper()
+		files, err := cfg.ProfileTokenFiles()
+		if err != nil {
+			t.Fatalf("ProfileTokenFiles failed: %v", err)
+		}
+		var names []string
+		for _, file := range files {
+			names = append(names, file.Name)
+		}
+		return names
+	}
+
+	vaultDigest := scopeDigest("https://vault.azure.net/.default")
+	expectedDefault := "azure-login-token-" + vaultDigest + ".json,azure-login-token.json"
+	if got := strings.Join(names(defaultConfig), ","); got != expectedDefault {
+		t.Errorf("Expected default profile files %s, got %s", expectedDefault, got)
+	}
+	expectedProd := corrupt + ",azure-login-token-prod-" + vaultDigest + ".json,azure-login-token-prod.json"
+	if got := strings.Join(names(prodConfig), ","); got != expectedProd {
+		t.Errorf("Expected prod profile files %s, got %s", expectedProd, got)
+	}
+}
+
+func TestValidateProfile(t *testing.T) {
+	for _, name := range []string{"default", "prod", "team_a-01"} {
+		if err := ValidateProfile(name); err != nil {
+			t.Errorf("Expected %q to be valid, got: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "../etc", "a/b", "-leading", "with space", strings.Repeat("a", 65)} {
+		if err := ValidateProfile(name); err == nil {
+			t.Errorf("Expected %q to be rejected", name)
+		}
+	}
+}
+
 func TestSavedTokenFields(t *testing.T) {
 	now := time.Now()
 	token := SavedToken{
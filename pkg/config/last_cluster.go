@@ -0,0 +1,58 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const lastClusterFile = "azure-login-last-cluster.json"
+
+// ErrNoLastCluster is returned by LoadLastCluster when no cluster has been recorded
+var ErrNoLastCluster = errors.New("no previous aks get-credentials target recorded")
+
+// LastCluster is the most recent successful aks get-credentials target
+type LastCluster struct {
+	ResourceGroup  string `json:"resource_group"`
+	Name           string `json:"name"`
+	SubscriptionID string `json:"subscription_id"`
+}
+
+// SaveLastCluster records the target of a successful aks get-credentials
+func (c *Config) SaveLastCluster(cluster *LastCluster) error {
+	if err := c.ensureConfigDir(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(cluster)
+	if err != nil {
+		return fmt.Errorf("failed to marshal last cluster: %w", err)
+	}
+	if err := c.writeFileAtomic(lastClusterFile, data); err != nil {
+		return fmt.Errorf("failed to save last cluster: %w", err)
+	}
+	return nil
+}
+
+// LoadLastCluster returns the recorded aks get-credentials target, or
+// ErrNoLastCluster if there is none
+func (c *Config) LoadLastCluster() (*LastCluster, error) {
+	data, err := os.ReadFile(filepath.Join(c.configDir, lastClusterFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoLastCluster
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read last cluster: %w", err)
+	}
+
+	var cluster LastCluster
+	if err := json.Unmarshal(data, &cluster); err != nil {
+		return nil, fmt.Errorf("failed to parse last cluster: %w", err)
+	}
+	if cluster.ResourceGroup == "" || cluster.Name == "" {
+		return nil, ErrNoLastCluster
+	}
+	return &cluster, nil
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLastCluster_RoundTrip(t *testing.T) {
+	cfg := &Config{configDir: t.TempDir()}
+
+	if _, err := cfg.LoadLastCluster(); !errors.Is(err, ErrNoLastCluster) {
+		t.Fatalf("Expected ErrNoLastCluster before any save, got: %v", err)
+	}
+
+	want := &LastCluster{ResourceGroup: "prod-rg", Name: "prod-cluster", SubscriptionID: "test-sub"}
+	if err := cfg.SaveLastCluster(want); err != nil {
+		t.Fatalf("SaveLastCluster failed: %v", err)
+	}
+
+	got, err := cfg.LoadLastCluster()
+	if err != nil {
+		t.Fatalf("LoadLastCluster failed: %v", err)
+	}
+	if *got != *want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/auth"
+)
+
+const (
+	subscriptionsFile = "azure-login-subscriptions.json"
+
+	// DefaultSubscriptionsCacheTTL is how long a cached subscriptions list is reused
+	DefaultSubscriptionsCacheTTL = 5 * time.Minute
+)
+
+// cachedSubscriptions is the on-disk subscriptions list, keyed by the identity that fetched it
+type cachedSubscriptions struct {
+	FetchedAt     time.Time           `json:"fetched_at"`
+	TenantID      string              `json:"tenant_id"`
+	ClientID      string              `json:"client_id"`
+	Cloud         string              `json:"cloud,omitempty"`
+	Subscriptions []auth.Subscription `json:"subscriptions"`
+}
+
+// matches reports whether the cache was written for the identity of the given token
+func (c *cachedSubscriptions) matches(token *SavedToken) bool {
+	return c.TenantID == token.TenantID && c.ClientID == token.ClientID && c.Cloud == token.Cloud
+}
+
+// LoadSubscriptions returns the cached subscriptions list for the token's identity if
+// it was fetched within ttl. A missing, stale, corrupt or foreign cache is a miss.
+func (c *Config) LoadSubscriptions(token *SavedToken, ttl time.Duration) ([]auth.Subscription, bool) {
+	data, err := os.ReadFile(filepath.Join(c.configDir, subscriptionsFile))
+	if err != nil {
+		return nil, false
+	}
+
+	var cached cachedSubscriptions
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, false
+	}
+	if !cached.matches(token) || time.Since(cached.FetchedAt) > ttl {
+		return nil, false
+	}
+	return cached.Subscriptions, true
+}
+
+// SaveSubscriptions caches the subscriptions list fetched with the given token
+func (c *Config) SaveSubscriptions(token *SavedToken, subscriptions []auth.Subscription) error {
+	if err := c.ensureConfigDir(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(cachedSubscriptions{
+		FetchedAt:     time.Now().UTC(),
+		TenantID:      token.TenantID,
+		ClientID:      token.ClientID,
+		Cloud:         token.Cloud,
+		Subscriptions: subscriptions,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal subscriptions: %w", err)
+	}
+
+	if err := c.writeFileAtomic(subscriptionsFile, data); err != nil {
+		return fmt.Errorf("failed to save subscriptions cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cogna-public/azure-login/internal/auth"
+)
+
+func TestSaveAndLoadSubscriptions(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("AZURE_CONFIG_DIR", tmpDir)
+
+	config := NewConfig()
+	token := &SavedToken{TenantID: "tenant", ClientID: "client"}
+	subscriptions := []auth.Subscription{{SubscriptionID: "sub-1", DisplayName: "One", State: "Enabled", TenantID: "tenant"}}
+
+	if err := config.SaveSubscriptions(token, subscriptions); err != nil {
+		t.Fatalf("SaveSubscriptions failed: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(tmpDir, subscriptionsFile))
+	if err != nil {
+		t.Fatalf("Failed to stat subscriptions cache: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected file permissions 0600, got %o", info.Mode().Perm())
+	}
+
+	loaded, ok := config.LoadSubscriptions(token, time.Minute)
+	if !ok {
+		t.Fatal("Expected a cache hit within TTL")
+	}
+	if len(loaded) != 1 || loaded[0].DisplayName != "One" {
+		t.Errorf("Unexpected cached subscriptions: %+v", loaded)
+	}
+
+	// Leftover temp files would accumulate in the config dir
+	matches, _ := filepath.Glob(filepath.Join(tmpDir, "*.tmp"))
+	if len(matches) != 0 {
+		t.Errorf("Expected no temp files, got %v", matches)
+	}
+}
+
+func TestLoadSubscriptions_Misses(t *testing.T) {
+	t.Setenv("AZURE_CONFIG_DIR", t.TempDir())
+
+	config := NewConfig()
+	token := &SavedToken{TenantID: "tenant", ClientID: "client"}
+
+	if _, ok := config.LoadSubscriptions(token, time.Minute); ok {
+		t.Error("Expected a miss without a cache file")
+	}
+
+	if err := config.SaveSubscriptions(token, []auth.Subscription{{SubscriptionID: "sub-1"}}); err != nil {
+		t.Fatalf("SaveSubscriptions failed: %v", err)
+	}
+
+	if _, ok := config.LoadSubscriptions(token, 0); ok {
+		t.Error("Expected a miss once the TTL has elapsed")
+	}
+	if _, ok := config.LoadSubscriptions(&SavedToken{TenantID: "tenant", ClientID: "other-client"}, time.Minute); ok {
+		t.Error("Expected a miss for a different identity")
+	}
+	if _, ok := config.LoadSubscriptions(&SavedToken{TenantID: "tenant", ClientID: "client", Cloud: "AzureUSGovernment"}, time.Minute); ok {
+		t.Error("Expected a miss for a different cloud")
+	}
+}